@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// loadChecklist returns the list of required shots of the profile and the button to export it
+func (a *App) loadChecklist() fyne.CanvasObject {
+	a.checklistBox = container.NewVBox()
+	exportBtn := widget.NewButton("Export Report", a.exportChecklistDialog)
+	a.refreshChecklist()
+	return container.NewVBox(
+		widget.NewLabelWithStyle("Required Shots:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		a.checklistBox,
+		exportBtn,
+	)
+}
+
+// refreshTagViews refreshes the checklist and the folder tree if the images or their
+// tags changed since they were shown. Both read the tags of every image.
+func (a *App) refreshTagViews() {
+	if a.session == a.shownSession && (a.session == nil || a.session.Version() == a.shownVersion) {
+		return
+	}
+	a.shownSession = a.session
+	if a.session != nil {
+		a.shownVersion = a.session.Version()
+	}
+	a.refreshChecklist()
+	a.refreshFolderTree()
+}
+
+// refreshChecklist shows which required tags the images of the folder have.
+// It is called whenever tags, the folder or the profile change.
+func (a *App) refreshChecklist() {
+	if a.checklistBox == nil {
+		return
+	}
+	a.checklistBox.Objects = nil
+	required := a.profiles[a.profile].Required
+	if len(required) == 0 {
+		a.checklistBox.Add(widget.NewLabel("No required tags. Mark tags as required in Edit Tags."))
+	} else if a.session == nil {
+		a.checklistBox.Add(widget.NewLabel(fmt.Sprintf("%d required tags, open a folder to check them", len(required))))
+	} else {
+		report := a.session.Checklist(a.profiles[a.profile].Name, required)
+		for _, item := range report.Items {
+			label := widget.NewLabel(fmt.Sprintf("✓ %s (%d)", item.Tag, len(item.Images)))
+			if !item.Done() {
+				label.SetText(fmt.Sprintf("✗ %s missing", item.Tag))
+				label.Importance = widget.DangerImportance
+			}
+			a.checklistBox.Add(label)
+		}
+		if report.Complete() {
+			a.checklistBox.Add(widget.NewLabelWithStyle("Complete", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		}
+	}
+	a.checklistBox.Refresh()
+}
+
+// exportChecklistDialog writes the completeness report of the folder as text, or as CSV for a .csv file
+func (a *App) exportChecklistDialog() {
+	if a.session == nil {
+		dialog.ShowError(fmt.Errorf("no folder opened"), a.mainWin)
+		return
+	}
+	report := a.session.Checklist(a.profiles[a.profile].Name, a.profiles[a.profile].Required)
+	d := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.mainWin)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		writer.Close()
+		if err := report.WriteFile(writer.URI().Path()); err != nil {
+			dialog.ShowError(err, a.mainWin)
+		}
+	}, a.mainWin)
+	d.SetFileName("checklist.txt")
+	d.Show()
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/export"
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+)
+
+// command is a subcommand of the command line. Commands work on folders from
+// scripts, with the same config, profiles and rename rules as the window.
+type command struct {
+	name  string
+	usage string
+	// changes is set for commands that rename, they get a --dry-run flag
+	changes bool
+	run     func(a *App, o *commandOptions, args []string) error
+}
+
+// commands are the subcommands, the first argument selects one instead of opening an image
+var commands = []command{
+	{"tag", "tag [--dry-run] [--json] [--profile NAME] --add TAG [--add TAG]... FILE|DIR...", true, (*App).tagCommand},
+	{"untag", "untag [--dry-run] [--json] [--profile NAME] --remove TAG [--remove TAG]... FILE|DIR...", true, (*App).untagCommand},
+	{"list-tags", "list-tags [--json] [--recursive] [--profile NAME] DIR...", false, (*App).listTagsCommand},
+	{"rename", "rename [--dry-run] [--json] [--profile NAME] --template TEMPLATE [--var NAME=VALUE]... FILE|DIR...", true, (*App).renameCommand},
+	{"gallery", "gallery [--json] [--profile NAME] [--recursive] [--title TITLE] [--size PIXELS] [--quality 1-100] --out DIR DIR", false, (*App).galleryCommand},
+}
+
+// findCommand returns the command called name
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// errUsage reports wrong arguments, the usage was printed already
+var errUsage = fmt.Errorf("invalid arguments")
+
+// runCommand runs the command with args and returns the exit code
+func (a *App) runCommand(c command, args []string) int {
+	a.loadProfiles()
+	a.openJournal()
+	err := c.run(a, newCommandOptions(c), args)
+	a.reportErrors()
+	switch {
+	case err == nil || err == flag.ErrHelp:
+		return 0
+	case err == errUsage:
+		return 2
+	default:
+		fmt.Fprintf(os.Stderr, "imagetagger %s: %v\n", c.name, err)
+		return 1
+	}
+}
+
+// printUsage lists the commands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: imagetagger [IMAGE]")
+	for _, c := range commands {
+		fmt.Fprintf(w, "       imagetagger %s\n", c.usage)
+	}
+}
+
+// stringList collects a repeated flag, e.g. --add ATTIC --add FRONT
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// commandOptions are the flags all commands share
+type commandOptions struct {
+	flags   *flag.FlagSet
+	dryRun  bool
+	json    bool
+	profile string
+}
+
+// newCommandOptions returns the shared flags of c
+func newCommandOptions(c command) *commandOptions {
+	o := &commandOptions{flags: flag.NewFlagSet(c.name, flag.ContinueOnError)}
+	if c.changes {
+		o.flags.BoolVar(&o.dryRun, "dry-run", false, "show the new names without renaming")
+	}
+	o.flags.BoolVar(&o.json, "json", false, "print the result as JSON")
+	o.flags.StringVar(&o.profile, "profile", "", "tag profile, by default the profile of the folder or the selected one")
+	o.flags.Usage = func() {
+		fmt.Fprintf(o.flags.Output(), "Usage: imagetagger %s\n", c.usage)
+		o.flags.PrintDefaults()
+	}
+	return o
+}
+
+// parse parses args and returns the remaining paths, of which there must be at least one
+func (o *commandOptions) parse(args []string) ([]string, error) {
+	if err := o.flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil, err
+		}
+		return nil, errUsage
+	}
+	if o.flags.NArg() == 0 {
+		fmt.Fprintln(o.flags.Output(), "no files given")
+		o.flags.Usage()
+		return nil, errUsage
+	}
+	return o.flags.Args(), nil
+}
+
+// vocabulary returns the tags of the profile for dir, followed by extra tags the profile doesn't have
+func (a *App) vocabulary(profile, dir string, extra []string) ([]string, error) {
+	i := a.profile
+	if profile != "" {
+		if i = tagger.FindProfile(a.profiles, profile); i < 0 {
+			return nil, fmt.Errorf("no profile %q", profile)
+		}
+	} else if match := tagger.MatchProfile(a.profiles, dir); match >= 0 {
+		i = match
+	}
+	vocabulary := []string{}
+	if i >= 0 && i < len(a.profiles) {
+		vocabulary = a.profiles[i].Vocabulary()
+	}
+	for _, tag := range extra {
+		if !tagger.HasTag(vocabulary, tag) {
+			vocabulary = append(vocabulary, tag)
+		}
+	}
+	return vocabulary, nil
+}
+
+// commandSession opens dir with the rename rules of the window. extra tags are
+// added to the vocabulary, so they are recognized in names.
+func (a *App) commandSession(o *commandOptions, dir string, recursive bool, extra []string) (*tagger.Session, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	vocabulary, err := a.vocabulary(o.profile, abs, extra)
+	if err != nil {
+		return nil, err
+	}
+	var session *tagger.Session
+	if recursive {
+		session, err = tagger.OpenFolder(dir)
+	} else {
+		session, err = tagger.NewSession(dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	session.SetCollisionPolicy(a.collisionPolicy())
+	session.SetJournal(a.journal)
+	session.SetSortOrder(a.sortOrder())
+	session.SetVocabulary(vocabulary)
+	session.SetTemplate(a.nameTemplate())
+	session.SetTagStorage(a.tagStorage())
+	session.SetEmbedTags(a.config.GetBool("embedtags"))
+	a.commandSessions = append(a.commandSessions, session)
+	return session, nil
+}
+
+// commandFolder is a folder given to a command, with the images to work on
+type commandFolder struct {
+	dir     string
+	session *tagger.Session
+	names   []string
+}
+
+// commandFolders groups paths by folder. A folder stands for all its images.
+func (a *App) commandFolders(o *commandOptions, paths []string, extra []string) ([]*commandFolder, error) {
+	folders := []*commandFolder{}
+	byDir := map[string]*commandFolder{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		dir, name := path, ""
+		if !info.IsDir() {
+			dir, name = filepath.Dir(path), filepath.Base(path)
+		}
+		f, ok := byDir[filepath.Clean(dir)]
+		if !ok {
+			session, err := a.commandSession(o, dir, false, extra)
+			if err != nil {
+				return nil, err
+			}
+			f = &commandFolder{dir: dir, session: session}
+			byDir[filepath.Clean(dir)] = f
+			folders = append(folders, f)
+		}
+		if name == "" {
+			f.names = append(f.names, f.session.Images()...)
+			continue
+		}
+		if !f.session.Seek(name) {
+			return nil, fmt.Errorf("%s is not an image", path)
+		}
+		f.names = append(f.names, name)
+	}
+	return folders, nil
+}
+
+// imageChange is the planned or done change of an image, as printed by the commands
+type imageChange struct {
+	Path     string   `json:"path"`
+	Target   string   `json:"target"`
+	Tags     []string `json:"tags"`
+	Renamed  bool     `json:"renamed"`
+	Conflict bool     `json:"conflict,omitempty"`
+}
+
+// applyPlans plans the change of every folder, applies it unless it is a dry run, and prints it
+func (a *App) applyPlans(o *commandOptions, folders []*commandFolder, plan func(f *commandFolder) []tagger.BatchItem) error {
+	changes := []imageChange{}
+	failed := []string{}
+	for _, f := range folders {
+		items := plan(f)
+		for _, item := range items {
+			changes = append(changes, imageChange{
+				Path:     filepath.Join(f.dir, item.Name),
+				Target:   filepath.Join(f.dir, filepath.Dir(item.Name), item.Target),
+				Tags:     item.Tags,
+				Renamed:  item.Changed() && !item.Conflict,
+				Conflict: item.Conflict,
+			})
+		}
+		if o.dryRun {
+			continue
+		}
+		if err := f.session.ApplyBatch(items); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if o.json {
+		out := struct {
+			DryRun bool          `json:"dry_run"`
+			Images []imageChange `json:"images"`
+		}{o.dryRun, changes}
+		if err := writeJSON(os.Stdout, out); err != nil {
+			return err
+		}
+	} else {
+		for _, c := range changes {
+			switch {
+			case c.Conflict:
+				fmt.Printf("%s: %s is taken, skipped\n", c.Path, filepath.Base(c.Target))
+			case c.Renamed:
+				fmt.Printf("%s -> %s\n", c.Path, filepath.Base(c.Target))
+			case len(c.Tags) == 0:
+				fmt.Printf("%s: no tags\n", c.Path)
+			default:
+				fmt.Printf("%s: tags %s\n", c.Path, strings.Join(c.Tags, ", "))
+			}
+		}
+		if o.dryRun {
+			fmt.Fprintln(os.Stderr, "dry run, nothing was changed")
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// tagCommand adds tags to images
+func (a *App) tagCommand(o *commandOptions, args []string) error {
+	var add stringList
+	o.flags.Var(&add, "add", "tag to add, can be repeated")
+	paths, err := o.parse(args)
+	if err != nil {
+		return err
+	}
+	if len(add) == 0 {
+		fmt.Fprintln(o.flags.Output(), "no tag given")
+		o.flags.Usage()
+		return errUsage
+	}
+	folders, err := a.commandFolders(o, paths, add)
+	if err != nil {
+		return err
+	}
+	return a.applyPlans(o, folders, func(f *commandFolder) []tagger.BatchItem {
+		return f.session.PlanTags(f.names, func(tags []string) []string {
+			for _, tag := range add {
+				if !tagger.HasTag(tags, tag) {
+					tags = append(tags, tag)
+				}
+			}
+			return tags
+		})
+	})
+}
+
+// untagCommand removes tags from images
+func (a *App) untagCommand(o *commandOptions, args []string) error {
+	var remove stringList
+	o.flags.Var(&remove, "remove", "tag to remove, can be repeated")
+	paths, err := o.parse(args)
+	if err != nil {
+		return err
+	}
+	if len(remove) == 0 {
+		fmt.Fprintln(o.flags.Output(), "no tag given")
+		o.flags.Usage()
+		return errUsage
+	}
+	folders, err := a.commandFolders(o, paths, remove)
+	if err != nil {
+		return err
+	}
+	return a.applyPlans(o, folders, func(f *commandFolder) []tagger.BatchItem {
+		return f.session.PlanTags(f.names, func(tags []string) []string {
+			kept := []string{}
+			for _, tag := range tags {
+				if !tagger.HasTag(remove, tag) {
+					kept = append(kept, tag)
+				}
+			}
+			return kept
+		})
+	})
+}
+
+// renameCommand renames images to another name template, keeping their tags
+func (a *App) renameCommand(o *commandOptions, args []string) error {
+	var text string
+	var vars stringList
+	o.flags.StringVar(&text, "template", "", "name template, e.g. \"{folder}_{tags}_{seq}\"")
+	o.flags.Var(&vars, "var", "job variable of the template, can be repeated")
+	paths, err := o.parse(args)
+	if err != nil {
+		return err
+	}
+	if text == "" {
+		fmt.Fprintln(o.flags.Output(), "no template given")
+		o.flags.Usage()
+		return errUsage
+	}
+	t, templateVars, err := parseNameTemplate(text, vars)
+	if err != nil {
+		return err
+	}
+	folders, err := a.commandFolders(o, paths, nil)
+	if err != nil {
+		return err
+	}
+	return a.applyPlans(o, folders, func(f *commandFolder) []tagger.BatchItem {
+		return f.session.PlanTemplate(f.names, t, templateVars)
+	})
+}
+
+// imageTags are the tags of an image, as printed by list-tags
+type imageTags struct {
+	Path string   `json:"path"`
+	Tags []string `json:"tags"`
+}
+
+// listTagsCommand prints the tags of every image in folders
+func (a *App) listTagsCommand(o *commandOptions, args []string) error {
+	var recursive bool
+	o.flags.BoolVar(&recursive, "recursive", false, "include subfolders")
+	dirs, err := o.parse(args)
+	if err != nil {
+		return err
+	}
+	images := []imageTags{}
+	for _, dir := range dirs {
+		session, err := a.commandSession(o, dir, recursive, nil)
+		if err != nil {
+			return err
+		}
+		for _, name := range session.Images() {
+			images = append(images, imageTags{Path: filepath.Join(dir, name), Tags: session.ImageTags(name)})
+		}
+	}
+
+	if o.json {
+		return writeJSON(os.Stdout, images)
+	}
+	for _, image := range images {
+		fmt.Printf("%s: %s\n", image.Path, strings.Join(image.Tags, ", "))
+	}
+	return nil
+}
+
+// galleryCommand writes an HTML gallery of a folder
+func (a *App) galleryCommand(o *commandOptions, args []string) error {
+	var out, title string
+	var recursive bool
+	var size, quality int
+	o.flags.StringVar(&out, "out", "", "folder the gallery is written to")
+	o.flags.StringVar(&title, "title", "", "title of the gallery, by default the folder name")
+	o.flags.BoolVar(&recursive, "recursive", false, "include subfolders")
+	o.flags.IntVar(&size, "size", a.config.GetInt("gallerysize"), "longest side of the images in pixels")
+	o.flags.IntVar(&quality, "quality", a.config.GetInt("galleryquality"), "JPEG quality from 1 to 100")
+	dirs, err := o.parse(args)
+	if err != nil {
+		return err
+	}
+	if out == "" || len(dirs) != 1 || size < 1 || quality < 1 || quality > 100 {
+		o.flags.Usage()
+		return errUsage
+	}
+	dir := dirs[0]
+	session, err := a.commandSession(o, dir, recursive, nil)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	vocabulary, err := a.vocabulary(o.profile, abs, nil)
+	if err != nil {
+		return err
+	}
+	if title == "" {
+		title = filepath.Base(abs)
+	}
+
+	items := export.Items(session, vocabulary)
+	err = export.WriteGallery(out, items, vocabulary, export.GalleryOptions{
+		Title:   title,
+		Info:    a.jobVariables(),
+		Size:    size,
+		Quality: quality,
+	})
+	if err != nil {
+		return err
+	}
+	index := filepath.Join(out, "index.html")
+	if o.json {
+		return writeJSON(os.Stdout, struct {
+			Index  string `json:"index"`
+			Images int    `json:"images"`
+		}{index, len(items)})
+	}
+	fmt.Printf("%s: %d images\n", index, len(items))
+	return nil
+}
+
+// writeJSON writes v indented to w
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+	"github.com/spf13/viper"
+)
+
+// runCaptured runs a command and returns what it wrote to stdout and stderr
+func runCaptured(t *testing.T, a *App, name string, args ...string) (code int, stdout, stderr []byte) {
+	c, ok := findCommand(name)
+	if !ok {
+		t.Fatalf("no command %s", name)
+	}
+	outFile, err := ioutil.TempFile(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errFile, err := ioutil.TempFile(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outFile.Close()
+	defer errFile.Close()
+
+	oldOut, oldErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outFile, errFile
+	code = a.runCommand(c, args)
+	os.Stdout, os.Stderr = oldOut, oldErr
+
+	if stdout, err = ioutil.ReadFile(outFile.Name()); err != nil {
+		t.Fatal(err)
+	}
+	if stderr, err = ioutil.ReadFile(errFile.Name()); err != nil {
+		t.Fatal(err)
+	}
+	return code, stdout, stderr
+}
+
+func TestListTagsJSONWithCorruptSidecar(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"IMG_1 ATTIC.jpg":     "",
+		"IMG_1 ATTIC.jpg.xmp": "<x:xmpmeta",
+		"IMG_2.jpg":           "",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := viper.New()
+	config.SetDefault("ButtonTags", []string{"ATTIC"})
+	config.SetDefault("TagStorage", string(tagger.StorageBoth))
+	config.SetDefault("NameTemplate", tagger.DefaultTemplate)
+	config.SetDefault("SortOrder", string(tagger.SortNatural))
+
+	code, stdout, stderr := runCaptured(t, &App{config: config}, "list-tags", "--json", dir)
+	if code != 0 {
+		t.Fatalf("exit code %d, stderr %s", code, stderr)
+	}
+	var images []imageTags
+	if err := json.Unmarshal(stdout, &images); err != nil {
+		t.Fatalf("stdout isn't JSON: %v\n%s", err, stdout)
+	}
+	want := []imageTags{
+		{Path: filepath.Join(dir, "IMG_1 ATTIC.jpg"), Tags: []string{"ATTIC"}},
+		{Path: filepath.Join(dir, "IMG_2.jpg"), Tags: []string{}},
+	}
+	if !reflect.DeepEqual(images, want) {
+		t.Errorf("list-tags = %+v, want %+v", images, want)
+	}
+	if !bytes.Contains(stderr, []byte("IMG_1 ATTIC.jpg.xmp")) {
+		t.Error("the unreadable sidecar wasn't reported on stderr")
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/export"
+)
+
+// exportManifestDialog saves a manifest of the images of the folder, as JSON for a .json name and as CSV otherwise
+func (a *App) exportManifestDialog() {
+	if a.session == nil {
+		dialog.ShowError(fmt.Errorf("no folder opened"), a.mainWin)
+		return
+	}
+	d := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.mainWin)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		writer.Close()
+		m := export.SessionManifest(a.session)
+		a.runExport("Writing Manifest", func(progress func(done, total int)) error {
+			m.Read(progress)
+			return m.WriteFile(writer.URI().Path())
+		})
+	}, a.mainWin)
+	d.SetFilter(storage.NewExtensionFileFilter([]string{".csv", ".json"}))
+	d.SetFileName("manifest.csv")
+	d.Show()
+}
+
+// photoReportDialog asks for the layout of the photo report and saves it as PDF
+func (a *App) photoReportDialog() {
+	if a.session == nil {
+		dialog.ShowError(fmt.Errorf("no folder opened"), a.mainWin)
+		return
+	}
+	title := widget.NewEntry()
+	title.SetText(filepath.Base(a.session.Dir()))
+	perPage := widget.NewSelect([]string{"1", "2", "4", "6"}, nil)
+	perPage.SetSelected(strconv.Itoa(a.config.GetInt("reportperpage")))
+	pageSize := widget.NewSelect([]string{"Letter", "A4"}, nil)
+	pageSize.SetSelected(a.config.GetString("reportpagesize"))
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Title", title),
+		widget.NewFormItem("Images per page", perPage),
+		widget.NewFormItem("Page size", pageSize),
+	}
+	dialog.ShowForm("Photo Report", "Save", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		n, _ := strconv.Atoi(perPage.Selected)
+		a.config.Set("reportperpage", n)
+		a.config.Set("reportpagesize", pageSize.Selected)
+		a.WriteConfig()
+		a.saveReportDialog(export.ReportOptions{
+			Title:    title.Text,
+			Info:     a.reportInfo(),
+			PerPage:  n,
+			PageSize: pageSize.Selected,
+		})
+	}, a.mainWin)
+}
+
+// reportInfo returns the header lines of a report: the folder, the profile and the job variables
+func (a *App) reportInfo() []string {
+	info := []string{
+		"Folder: " + a.session.Dir(),
+		"Profile: " + a.profiles[a.profile].Name,
+	}
+	info = append(info, a.jobVariables()...)
+	return append(info, "Generated: "+time.Now().Format("2006-01-02 15:04"))
+}
+
+// jobVariables returns a line per job variable of the name template, sorted by name
+func (a *App) jobVariables() []string {
+	_, vars := a.nameTemplate()
+	names := []string{}
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := []string{}
+	for _, name := range names {
+		lines = append(lines, name+": "+vars[name])
+	}
+	return lines
+}
+
+// saveReportDialog asks where to save the photo report and writes it in the background
+func (a *App) saveReportDialog(o export.ReportOptions) {
+	items := export.ReportItems(a.session, a.buttonTags)
+	d := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.mainWin)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		writer.Close()
+		o.Decode = a.exportDecode
+		a.runExport("Writing Photo Report", func(progress func(done, total int)) error {
+			o.Progress = progress
+			return export.WriteReportFile(writer.URI().Path(), items, o)
+		})
+	}, a.mainWin)
+	d.SetFilter(storage.NewExtensionFileFilter([]string{".pdf"}))
+	d.SetFileName("photo report.pdf")
+	d.Show()
+}
+
+// galleryDialog asks for the size of the images and the folder to write an HTML gallery to
+func (a *App) galleryDialog() {
+	if a.session == nil {
+		dialog.ShowError(fmt.Errorf("no folder opened"), a.mainWin)
+		return
+	}
+	title := widget.NewEntry()
+	title.SetText(filepath.Base(a.session.Dir()))
+	size := sizeEntry(a.config.GetInt("gallerysize"))
+	quality := qualityEntry(a.config.GetInt("galleryquality"))
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Title", title),
+		widget.NewFormItem("Image size", size),
+		widget.NewFormItem("JPEG quality", quality),
+	}
+	items[1].HintText = "Longest side in pixels"
+	dialog.ShowForm("HTML Gallery", "Choose Folder", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		o := export.GalleryOptions{Title: title.Text, Info: a.jobVariables()}
+		o.Decode = a.exportDecode
+		o.Size, _ = strconv.Atoi(size.Text)
+		o.Quality, _ = strconv.Atoi(quality.Text)
+		a.config.Set("gallerysize", o.Size)
+		a.config.Set("galleryquality", o.Quality)
+		a.WriteConfig()
+		a.saveGalleryDialog(o)
+	}, a.mainWin)
+}
+
+// saveGalleryDialog asks for the folder the gallery is created in and writes it in the background
+func (a *App) saveGalleryDialog(o export.GalleryOptions) {
+	items := export.Items(a.session, a.buttonTags)
+	vocabulary := a.buttonTags
+	d := dialog.NewFolderOpen(func(uri fyne.ListableURI, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.mainWin)
+			return
+		}
+		if uri == nil {
+			return
+		}
+		out := filepath.Join(uri.Path(), galleryFolder(o.Title))
+		a.runExport("Writing Gallery", func(progress func(done, total int)) error {
+			o.Progress = progress
+			return export.WriteGallery(out, items, vocabulary, o)
+		})
+	}, a.mainWin)
+	d.Show()
+}
+
+// galleryFolder returns the name of the folder a gallery with title is written to
+func galleryFolder(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if name == "" {
+		name = "Gallery"
+	}
+	return name + " gallery"
+}
+
+// packageDialog asks whether to resize the copies of a job package and where to save its zip
+func (a *App) packageDialog() {
+	if a.session == nil {
+		dialog.ShowError(fmt.Errorf("no folder opened"), a.mainWin)
+		return
+	}
+	size := sizeEntry(a.config.GetInt("packagesize"))
+	quality := qualityEntry(a.config.GetInt("packagequality"))
+	resize := widget.NewCheck("Resize and recompress as JPEG", func(on bool) {
+		if on {
+			size.Enable()
+			quality.Enable()
+		} else {
+			size.Disable()
+			quality.Disable()
+		}
+	})
+	resize.SetChecked(a.config.GetBool("packageresize"))
+	resize.OnChanged(resize.Checked)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Copies", resize),
+		widget.NewFormItem("Image size", size),
+		widget.NewFormItem("JPEG quality", quality),
+	}
+	items[0].HintText = "Unchecked copies the originals unchanged"
+	items[1].HintText = "Longest side in pixels"
+	dialog.ShowForm("Package", "Save", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		o := export.PackageOptions{Name: filepath.Base(a.session.Dir())}
+		o.Decode = a.exportDecode
+		if resize.Checked {
+			o.Size, _ = strconv.Atoi(size.Text)
+			o.Quality, _ = strconv.Atoi(quality.Text)
+			a.config.Set("packagesize", o.Size)
+			a.config.Set("packagequality", o.Quality)
+		}
+		a.config.Set("packageresize", resize.Checked)
+		a.WriteConfig()
+		a.savePackageDialog(o)
+	}, a.mainWin)
+}
+
+// savePackageDialog asks where to save the zip of the job package and writes it in the background
+func (a *App) savePackageDialog(o export.PackageOptions) {
+	items := export.PackageItems(a.session, a.buttonTags)
+	d := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.mainWin)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		writer.Close()
+		a.runExport("Writing Package", func(progress func(done, total int)) error {
+			o.Progress = progress
+			return export.WritePackageFile(writer.URI().Path(), items, o)
+		})
+	}, a.mainWin)
+	d.SetFilter(storage.NewExtensionFileFilter([]string{".zip"}))
+	d.SetFileName(o.Name + ".zip")
+	d.Show()
+}
+
+// sizeEntry returns an entry for the longest side of exported images in pixels
+func sizeEntry(pixels int) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetText(strconv.Itoa(pixels))
+	entry.Validator = func(s string) error {
+		if n, err := strconv.Atoi(s); err != nil || n < 1 {
+			return fmt.Errorf("input is not a valid number of pixels")
+		}
+		return nil
+	}
+	return entry
+}
+
+// qualityEntry returns an entry for the JPEG quality of exported images
+func qualityEntry(quality int) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetText(strconv.Itoa(quality))
+	entry.Validator = func(s string) error {
+		if n, err := strconv.Atoi(s); err != nil || n < 1 || n > 100 {
+			return fmt.Errorf("quality must be between 1 and 100")
+		}
+		return nil
+	}
+	return entry
+}
+
+// exportDecode decodes an image for an export. Images shown recently are decoded
+// already; the others aren't added to the cache, so it keeps the images around the current one.
+func (a *App) exportDecode(path string) (image.Image, error) {
+	if a.imageCache.Cached(path) {
+		return a.imageCache.Get(path)
+	}
+	return a.imageCache.Decode(path)
+}
+
+// runExport runs write in the background while a dialog shows its progress.
+// write must not use the session, the dialogs prepare what it needs.
+func (a *App) runExport(title string, write func(progress func(done, total int)) error) {
+	bar := widget.NewProgressBar()
+	d := dialog.NewCustomWithoutButtons(title, bar, a.mainWin)
+	d.Show()
+	go func() {
+		err := write(func(done, total int) {
+			a.runOnUI(func() {
+				bar.SetValue(float64(done) / float64(total))
+			})
+		})
+		a.runOnUI(func() {
+			d.Hide()
+			if err != nil {
+				dialog.ShowError(err, a.mainWin)
+			}
+		})
+	}()
+}
@@ -0,0 +1,276 @@
+package export
+
+import (
+	"fmt"
+	"html/template"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/thumbs"
+)
+
+// gallery folders, inside the output folder
+const (
+	galleryImages = "images"
+	galleryThumbs = "thumbs"
+)
+
+// GalleryOptions configure an HTML gallery
+type GalleryOptions struct {
+	Title string
+	Info  []string
+	// Size is the longest side of the full size images in pixels, 2048 if zero
+	Size int
+	// Quality is the JPEG quality from 1 to 100, 85 if zero
+	Quality int
+	// ThumbSize is the longest side of the thumbnails in pixels, 240 if zero
+	ThumbSize int
+	Hooks
+}
+
+// galleryImage is an image as shown in the gallery
+type galleryImage struct {
+	File    string
+	Caption string
+	// Width and Height are the size of the thumbnail
+	Width, Height int
+}
+
+// galleryGroup is a section of the gallery, a tag or the untagged images
+type galleryGroup struct {
+	Tag    string
+	Images []galleryImage
+}
+
+// WriteGallery writes a self-contained gallery of items to the folder out: an
+// index.html with the thumbnails grouped by tag and a lightbox, and the images
+// downscaled to JPEGs. Groups follow the order of vocabulary, an image with
+// several tags is shown in each of their groups. Images that can't be read are
+// left out; the gallery is finished and the error lists them.
+func WriteGallery(out string, items []Item, vocabulary []string, o GalleryOptions) error {
+	if o.Size <= 0 {
+		o.Size = 2048
+	}
+	if o.Quality <= 0 || o.Quality > 100 {
+		o.Quality = 85
+	}
+	if o.ThumbSize <= 0 {
+		o.ThumbSize = 240
+	}
+	if o.ThumbSize > o.Size {
+		o.ThumbSize = o.Size
+	}
+	for _, dir := range []string{galleryImages, galleryThumbs} {
+		if err := os.MkdirAll(filepath.Join(out, dir), 0755); err != nil {
+			return err
+		}
+	}
+
+	images := map[string]galleryImage{}
+	shown := []Item{}
+	skipped := []string{}
+	taken := map[string]bool{}
+	for i, item := range items {
+		img, err := o.decode(item.Path)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", item.Name, err))
+			o.progress(i+1, len(items))
+			continue
+		}
+		g := galleryImage{File: galleryFile(item.Name, taken), Caption: galleryCaption(item)}
+		if err := writeJPEG(filepath.Join(out, galleryImages, g.File), thumbs.Fit(img, o.Size), o.Quality); err != nil {
+			return err
+		}
+		thumb := thumbs.Fit(img, o.ThumbSize)
+		g.Width, g.Height = thumb.Bounds().Dx(), thumb.Bounds().Dy()
+		if err := writeJPEG(filepath.Join(out, galleryThumbs, g.File), thumb, o.Quality); err != nil {
+			return err
+		}
+		images[item.Path] = g
+		shown = append(shown, item)
+		o.progress(i+1, len(items))
+	}
+
+	f, err := os.Create(filepath.Join(out, "index.html"))
+	if err != nil {
+		return err
+	}
+	err = galleryTemplate.Execute(f, struct {
+		GalleryOptions
+		Groups []galleryGroup
+	}{o, galleryGroups(shown, vocabulary, images)})
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if len(skipped) > 0 {
+		return fmt.Errorf("%d images can't be read and are left out of the gallery:\n%s", len(skipped), strings.Join(skipped, "\n"))
+	}
+	return nil
+}
+
+// galleryGroups sorts the images into a group per tag, in the order of vocabulary
+// followed by other tags, and a last group of untagged images
+func galleryGroups(items []Item, vocabulary []string, images map[string]galleryImage) []galleryGroup {
+	tags := []string{}
+	add := func(tag string) {
+		if tag != "" && !contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	for _, tag := range vocabulary {
+		add(tag)
+	}
+	for _, item := range items {
+		for _, tag := range item.Tags {
+			add(tag)
+		}
+	}
+
+	groups := []galleryGroup{}
+	untagged := galleryGroup{Tag: "Untagged"}
+	for _, tag := range tags {
+		group := galleryGroup{Tag: tag}
+		for _, item := range items {
+			if contains(item.Tags, tag) {
+				group.Images = append(group.Images, images[item.Path])
+			}
+		}
+		if len(group.Images) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	for _, item := range items {
+		if len(item.Tags) == 0 {
+			untagged.Images = append(untagged.Images, images[item.Path])
+		}
+	}
+	if len(untagged.Images) > 0 {
+		groups = append(groups, untagged)
+	}
+	return groups
+}
+
+// galleryFile returns a unique JPEG file name for the image name, a path relative to the exported folder
+func galleryFile(name string, taken map[string]bool) string {
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	stem = strings.ReplaceAll(filepath.ToSlash(stem), "/", " - ")
+	file := stem + ".jpg"
+	for n := 2; taken[strings.ToLower(file)]; n++ {
+		file = fmt.Sprintf("%s %d.jpg", stem, n)
+	}
+	taken[strings.ToLower(file)] = true
+	return file
+}
+
+// galleryCaption returns the tags of the image, or its name if it has none
+func galleryCaption(item Item) string {
+	if len(item.Tags) == 0 {
+		return filepath.Base(item.Name)
+	}
+	return strings.Join(item.Tags, ", ")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJPEG encodes img as JPEG file at path
+func writeJPEG(path string, img image.Image, quality int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: quality}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// galleryTemplate is the index.html of a gallery. It needs no other files than the images.
+var galleryTemplate = template.Must(template.New("index.html").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; color: #222; max-width: 1200px; margin: 0 auto; padding: 1em; }
+h1 { font-weight: normal; margin-bottom: .2em; }
+.info { color: #666; margin: 0; }
+h2 { border-bottom: 1px solid #ccc; padding-bottom: .2em; margin-top: 1.5em; }
+.grid { display: flex; flex-wrap: wrap; gap: 10px; }
+.grid a { display: block; color: inherit; text-decoration: none; }
+.grid img { display: block; border-radius: 3px; }
+.grid span { display: block; font-size: .8em; margin-top: .2em; }
+#lightbox { display: none; position: fixed; top: 0; left: 0; right: 0; bottom: 0; background: rgba(0, 0, 0, .92);
+	flex-direction: column; align-items: center; justify-content: center; }
+#lightbox.open { display: flex; }
+#lightbox img { max-width: 92vw; max-height: 85vh; }
+#lightbox p { color: #eee; }
+#lightbox button { position: absolute; background: none; border: none; color: #fff; font-size: 3em; cursor: pointer; }
+#prev { left: .2em; top: 45%; }
+#next { right: .2em; top: 45%; }
+#close { right: .3em; top: .1em; font-size: 2em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Info}}<p class="info">{{.}}</p>
+{{end}}
+{{range .Groups}}<h2>{{.Tag}}</h2>
+<div class="grid">
+{{range .Images}}<a href="images/{{.File}}" data-caption="{{.Caption}}"><img src="thumbs/{{.File}}" width="{{.Width}}" height="{{.Height}}" alt="{{.Caption}}" loading="lazy"><span>{{.Caption}}</span></a>
+{{end}}</div>
+{{end}}
+<div id="lightbox">
+<img alt="">
+<p></p>
+<button id="prev" title="Previous">&lsaquo;</button>
+<button id="next" title="Next">&rsaquo;</button>
+<button id="close" title="Close">&times;</button>
+</div>
+<script>
+var links = document.querySelectorAll(".grid a");
+var box = document.getElementById("lightbox");
+var current = 0;
+function show(i) {
+	current = (i + links.length) % links.length;
+	box.querySelector("img").src = links[current].href;
+	box.querySelector("p").textContent = links[current].getAttribute("data-caption");
+	box.className = "open";
+}
+function hide() {
+	box.className = "";
+}
+for (var i = 0; i < links.length; i++) {
+	links[i].onclick = (function (i) {
+		return function (e) { e.preventDefault(); show(i); };
+	})(i);
+}
+document.getElementById("prev").onclick = function () { show(current - 1); };
+document.getElementById("next").onclick = function () { show(current + 1); };
+document.getElementById("close").onclick = hide;
+box.onclick = function (e) { if (e.target === box) hide(); };
+document.onkeydown = function (e) {
+	if (box.className !== "open") return;
+	if (e.key === "Escape") hide();
+	if (e.key === "ArrowLeft") show(current - 1);
+	if (e.key === "ArrowRight") show(current + 1);
+};
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,103 @@
+package export
+
+import (
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteGallery(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.png", "b.png", "c.png"} {
+		writePNG(t, filepath.Join(dir, name), 600, 300)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "attic"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writePNG(t, filepath.Join(dir, "attic", "a.png"), 60, 30)
+	items := []Item{
+		{Path: filepath.Join(dir, "a.png"), Name: "a.png", Tags: []string{"FRONT", "REAR"}},
+		{Path: filepath.Join(dir, "b.png"), Name: "b.png", Tags: []string{"REAR"}},
+		{Path: filepath.Join(dir, "c.png"), Name: "c.png"},
+		{Path: filepath.Join(dir, "attic", "a.png"), Name: filepath.Join("attic", "a.png"), Tags: []string{"ATTIC <1>"}},
+	}
+
+	out := filepath.Join(t.TempDir(), "gallery")
+	done := 0
+	err := WriteGallery(out, items, []string{"REAR", "", "FRONT"}, GalleryOptions{
+		Title:     "Smith & Sons",
+		Size:      200,
+		ThumbSize: 50,
+		Hooks:     Hooks{Progress: func(n, total int) { done = n }},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done != len(items) {
+		t.Errorf("progress = %d, want %d", done, len(items))
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(out, galleryImages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	if want := []string{"a.jpg", "attic - a.jpg", "b.jpg", "c.jpg"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("images = %v, want %v", names, want)
+	}
+	f, err := os.Open(filepath.Join(out, galleryImages, "a.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	config, err := jpeg.DecodeConfig(f)
+	if err != nil || config.Width != 200 || config.Height != 100 {
+		t.Errorf("full size image is %dx%d, %v, want 200x100", config.Width, config.Height, err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(out, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(data)
+	rear, front, attic, untagged := strings.Index(html, "<h2>REAR</h2>"), strings.Index(html, "<h2>FRONT</h2>"), strings.Index(html, "<h2>ATTIC &lt;1&gt;</h2>"), strings.Index(html, "<h2>Untagged</h2>")
+	if rear < 0 || front < rear || attic < front || untagged < attic {
+		t.Errorf("groups out of order: REAR %d, FRONT %d, ATTIC %d, Untagged %d", rear, front, attic, untagged)
+	}
+	if strings.Count(html, `src="thumbs/a.jpg"`) != 2 {
+		t.Error("an image with two tags isn't in both groups")
+	}
+	if !strings.Contains(html, "Smith &amp; Sons") || !strings.Contains(html, `width="50" height="25"`) {
+		t.Errorf("index.html =\n%s", html)
+	}
+}
+
+func TestWriteGallerySkipsUnreadable(t *testing.T) {
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "a.png"), 60, 30)
+	items := []Item{
+		{Path: filepath.Join(dir, "missing.png"), Name: "missing.png", Tags: []string{"REAR"}},
+		{Path: filepath.Join(dir, "a.png"), Name: "a.png", Tags: []string{"FRONT"}},
+	}
+
+	out := filepath.Join(t.TempDir(), "gallery")
+	err := WriteGallery(out, items, nil, GalleryOptions{Title: "Smith"})
+	if err == nil || !strings.Contains(err.Error(), "missing.png") {
+		t.Errorf("error = %v, want one naming missing.png", err)
+	}
+	data, readErr := ioutil.ReadFile(filepath.Join(out, "index.html"))
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	html := string(data)
+	if !strings.Contains(html, "a.jpg") || strings.Contains(html, "missing") || strings.Contains(html, "REAR") {
+		t.Errorf("index.html doesn't show just the readable image:\n%s", html)
+	}
+}
@@ -0,0 +1,50 @@
+package export
+
+import (
+	"image"
+	"path/filepath"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/imgcache"
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+)
+
+// Item is an image of a report or gallery
+type Item struct {
+	Path string
+	// Name is the path relative to the exported folder
+	Name string
+	Tags []string
+}
+
+// Hooks are the callbacks every export takes
+type Hooks struct {
+	// Decode returns the image at path turned upright, imageorient if nil
+	Decode func(path string) (image.Image, error)
+	// Progress is called after each image, if set
+	Progress func(done, total int)
+}
+
+// decode decodes the image at path with Decode
+func (h Hooks) decode(path string) (image.Image, error) {
+	if h.Decode == nil {
+		return imgcache.DecodeFile(path)
+	}
+	return h.Decode(path)
+}
+
+// progress reports that done of total images are exported
+func (h Hooks) progress(done, total int) {
+	if h.Progress != nil {
+		h.Progress(done, total)
+	}
+}
+
+// Items returns the images of the session with their tags in the order of vocabulary
+func Items(s *tagger.Session, vocabulary []string) []Item {
+	items := []Item{}
+	for _, name := range s.Images() {
+		tags := tagger.OrderTags(s.ImageTags(name), vocabulary)
+		items = append(items, Item{Path: filepath.Join(s.Dir(), name), Name: name, Tags: tags})
+	}
+	return items
+}
@@ -0,0 +1,175 @@
+// Package export writes the result of tagging a job folder in forms other
+// software can read, like a manifest of the images and their tags.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	// decoders of the supported image formats
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/metadata"
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+)
+
+// dateLayout is the format of dates in CSV manifests
+const dateLayout = "2006-01-02 15:04:05"
+
+// Entry describes one image of a manifest
+type Entry struct {
+	// Original is the file name before it was renamed by the tagger
+	Original string `json:"original"`
+	// Name is the current path relative to the folder of the manifest
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+	// Width and Height are the size as shown, after the EXIF orientation; zero if unknown
+	Width  int   `json:"width"`
+	Height int   `json:"height"`
+	Size   int64 `json:"size"`
+	// Date is the EXIF capture date, nil if the image has none
+	Date *time.Time `json:"date,omitempty"`
+	// Latitude and Longitude are the GPS position in decimal degrees, nil if the image has none
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+}
+
+// Manifest lists the images of a folder with their tags and metadata
+type Manifest struct {
+	Dir       string    `json:"dir"`
+	Generated time.Time `json:"generated"`
+	Images    []Entry   `json:"images"`
+}
+
+// NewManifest describes every image of the session. Images that can't be read
+// are listed with what is known about them.
+func NewManifest(s *tagger.Session) *Manifest {
+	m := SessionManifest(s)
+	m.Read(nil)
+	return m
+}
+
+// SessionManifest returns the manifest with what the session knows, the names
+// and tags of the images. Read adds the rest from the files without the session,
+// so it can run in the background while the session changes.
+func SessionManifest(s *tagger.Session) *Manifest {
+	m := &Manifest{Dir: s.Dir(), Generated: time.Now(), Images: []Entry{}}
+	for _, name := range s.Images() {
+		m.Images = append(m.Images, sessionEntry(s, name))
+	}
+	return m
+}
+
+// Read reads the size, date and position of the images from their files.
+// progress is called after each image, if set.
+func (m *Manifest) Read(progress func(done, total int)) {
+	h := Hooks{Progress: progress}
+	for i := range m.Images {
+		m.Images[i].read(filepath.Join(m.Dir, m.Images[i].Name))
+		h.progress(i+1, len(m.Images))
+	}
+}
+
+func newEntry(s *tagger.Session, name string) Entry {
+	e := sessionEntry(s, name)
+	e.read(filepath.Join(s.Dir(), name))
+	return e
+}
+
+// sessionEntry returns the entry of an image with its names and tags
+func sessionEntry(s *tagger.Session, name string) Entry {
+	return Entry{Original: s.OriginalName(name), Name: name, Tags: s.ImageTags(name)}
+}
+
+// read adds what the image file at path tells
+func (e *Entry) read(path string) {
+	if info, err := os.Stat(path); err == nil {
+		e.Size = info.Size()
+	}
+	e.Width, e.Height = imageSize(path)
+	if exif, err := metadata.ReadEXIFFile(path); err == nil {
+		if date := exif.Date(); !date.IsZero() {
+			e.Date = &date
+		}
+		if exif.HasGPS {
+			lat, lon := exif.Latitude, exif.Longitude
+			e.Latitude, e.Longitude = &lat, &lon
+		}
+		if exif.Rotated() {
+			e.Width, e.Height = e.Height, e.Width
+		}
+	}
+}
+
+// imageSize reads the size of the image at path without decoding the pixels
+func imageSize(path string) (int, int) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0
+	}
+	return config.Width, config.Height
+}
+
+// WriteJSON writes the manifest as an indented JSON object
+func (m *Manifest) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteCSV writes one line per image. Tags are separated by semicolons, unknown values are empty.
+func (m *Manifest) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"original", "name", "tags", "width", "height", "size", "date", "latitude", "longitude"})
+	for _, e := range m.Images {
+		record := []string{e.Original, e.Name, strings.Join(e.Tags, "; "), "", "", strconv.FormatInt(e.Size, 10), "", "", ""}
+		if e.Width > 0 && e.Height > 0 {
+			record[3], record[4] = strconv.Itoa(e.Width), strconv.Itoa(e.Height)
+		}
+		if e.Date != nil {
+			record[6] = e.Date.Format(dateLayout)
+		}
+		if e.Latitude != nil && e.Longitude != nil {
+			record[7] = strconv.FormatFloat(*e.Latitude, 'f', 6, 64)
+			record[8] = strconv.FormatFloat(*e.Longitude, 'f', 6, 64)
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteFile writes the manifest to path, as JSON for a .json extension and as CSV otherwise
+func (m *Manifest) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = m.WriteJSON(f)
+	} else {
+		err = m.WriteCSV(f)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
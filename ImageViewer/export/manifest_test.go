@@ -0,0 +1,121 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+)
+
+// writePNG creates a w x h PNG image at path
+func writePNG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// taggedSession returns a session of a folder with two PNG images, one of them
+// renamed to carry the tag ATTIC
+func taggedSession(t *testing.T) *tagger.Session {
+	t.Helper()
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "IMG_1.png"), 40, 30)
+	if err := ioutil.WriteFile(filepath.Join(dir, "IMG_2.png"), []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	j, err := tagger.OpenJournal(filepath.Join(t.TempDir(), "journal.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := tagger.NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetJournal(j)
+	s.SetVocabulary([]string{"ATTIC"})
+	s.ToggleTag("ATTIC")
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNewManifest(t *testing.T) {
+	m := NewManifest(taggedSession(t))
+	if len(m.Images) != 2 {
+		t.Fatalf("manifest has %d images, want 2", len(m.Images))
+	}
+	first := m.Images[0]
+	first.Size = 0
+	want := Entry{Original: "IMG_1.png", Name: "IMG_1 ATTIC.png", Tags: []string{"ATTIC"}, Width: 40, Height: 30}
+	if !reflect.DeepEqual(first, want) {
+		t.Errorf("first image = %+v, want %+v", first, want)
+	}
+	if m.Images[0].Size == 0 || m.Images[1].Size != int64(len("broken")) {
+		t.Errorf("sizes = %d, %d", m.Images[0].Size, m.Images[1].Size)
+	}
+	if m.Images[1].Width != 0 || m.Images[1].Height != 0 {
+		t.Errorf("size of an image that can't be read = %dx%d", m.Images[1].Width, m.Images[1].Height)
+	}
+}
+
+func TestSessionManifest(t *testing.T) {
+	m := SessionManifest(taggedSession(t))
+	if len(m.Images) != 2 || m.Images[0].Name != "IMG_1 ATTIC.png" || m.Images[0].Size != 0 {
+		t.Fatalf("SessionManifest() = %+v, want the names without the files read", m.Images)
+	}
+	done := 0
+	m.Read(func(n, total int) { done = n })
+	if done != 2 {
+		t.Errorf("progress reached %d of 2", done)
+	}
+	if first := m.Images[0]; first.Width != 40 || first.Height != 30 || first.Size == 0 {
+		t.Errorf("first image after Read = %+v", first)
+	}
+}
+
+func TestManifestWrite(t *testing.T) {
+	m := NewManifest(taggedSession(t))
+
+	var b bytes.Buffer
+	if err := m.WriteCSV(&b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "IMG_1.png,IMG_1 ATTIC.png,ATTIC,40,30,") {
+		t.Errorf("WriteCSV() =\n%s", b.String())
+	}
+	if !strings.HasPrefix(lines[2], "IMG_2.png,IMG_2.png,,,,6,") {
+		t.Errorf("unknown values aren't empty: %s", lines[2])
+	}
+
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := m.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := Manifest{}
+	if err := json.Unmarshal(data, &read); err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Images) != 2 || read.Images[0].Name != "IMG_1 ATTIC.png" || read.Images[1].Date != nil {
+		t.Errorf("JSON manifest = %+v", read)
+	}
+}
@@ -0,0 +1,167 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+	"github.com/jjwinters/image-tagger/ImageViewer/thumbs"
+)
+
+// PackageItem is a tagged image and the manifest entry of its copy in a package
+type PackageItem struct {
+	Item
+	Entry Entry
+}
+
+// PackageOptions configure a job package
+type PackageOptions struct {
+	// Name is the job, the folder of the package inside the zip
+	Name string
+	// Size is the longest side of the copies in pixels; they keep the size of the originals if zero
+	Size int
+	// Quality is the JPEG quality from 1 to 100. If it and Size are zero the
+	// originals are copied unchanged, else the copies are recompressed as JPEG with quality 85.
+	Quality int
+	Hooks
+}
+
+// PackageItems returns the tagged images of the session, named by the template
+// with their tags as if they were renamed. The images aren't changed.
+func PackageItems(s *tagger.Session, vocabulary []string) []PackageItem {
+	names := []string{}
+	for _, name := range s.Images() {
+		if len(s.ImageTags(name)) > 0 {
+			names = append(names, name)
+		}
+	}
+	items := []PackageItem{}
+	for _, b := range s.PlanCopies(names) {
+		e := newEntry(s, b.Name)
+		e.Name = filepath.Join(filepath.Dir(b.Name), b.Target)
+		e.Tags = tagger.OrderTags(e.Tags, vocabulary)
+		item := Item{Path: filepath.Join(s.Dir(), b.Name), Name: b.Name, Tags: e.Tags}
+		items = append(items, PackageItem{Item: item, Entry: e})
+	}
+	return items
+}
+
+// WritePackage writes a zip of copies of items under their new names to w, with
+// the manifest of the copies as manifest.csv and manifest.json. Everything is
+// in a folder named after the job.
+func WritePackage(w io.Writer, items []PackageItem, o PackageOptions) error {
+	convert := o.Size > 0 || o.Quality > 0
+	if o.Quality <= 0 || o.Quality > 100 {
+		o.Quality = 85
+	}
+	dir := strings.TrimSpace(o.Name)
+	if dir == "" {
+		dir = "package"
+	}
+
+	zw := zip.NewWriter(w)
+	m := &Manifest{Dir: dir, Generated: time.Now(), Images: []Entry{}}
+	taken := map[string]bool{}
+	for i, item := range items {
+		e := item.Entry
+		var data []byte
+		var err error
+		modified := time.Now()
+		if info, err := os.Stat(item.Path); err == nil {
+			modified = info.ModTime()
+		}
+		if convert {
+			e.Name = strings.TrimSuffix(e.Name, filepath.Ext(e.Name)) + ".jpg"
+			data, e.Width, e.Height, err = packageJPEG(item.Path, o)
+		} else {
+			data, err = ioutil.ReadFile(item.Path)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", item.Name, err)
+		}
+		e.Name = packageFile(e.Name, taken)
+		e.Size = int64(len(data))
+
+		// images are compressed already
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: path.Join(dir, e.Name), Method: zip.Store, Modified: modified})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+		m.Images = append(m.Images, e)
+		o.progress(i+1, len(items))
+	}
+
+	for _, name := range []string{"manifest.csv", "manifest.json"} {
+		f, err := zw.Create(path.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		if name == "manifest.json" {
+			err = m.WriteJSON(f)
+		} else {
+			err = m.WriteCSV(f)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// packageJPEG returns the image at path scaled to fit o.Size and encoded as JPEG, and its size
+func packageJPEG(path string, o PackageOptions) ([]byte, int, int, error) {
+	img, err := o.decode(path)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if o.Size > 0 {
+		img = thumbs.Fit(img, o.Size)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: o.Quality}); err != nil {
+		return nil, 0, 0, err
+	}
+	return buf.Bytes(), img.Bounds().Dx(), img.Bounds().Dy(), nil
+}
+
+// packageFile returns name as a slash separated path that isn't taken yet in the
+// package, numbered if needed. Converted copies of a.png and a.jpg would both be a.jpg.
+func packageFile(name string, taken map[string]bool) string {
+	name = filepath.ToSlash(name)
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for n := 2; taken[strings.ToLower(name)]; n++ {
+		name = fmt.Sprintf("%s %d%s", stem, n, ext)
+	}
+	taken[strings.ToLower(name)] = true
+	return name
+}
+
+// WritePackageFile writes the zip package of items to path. A package that
+// can't be written completely is removed.
+func WritePackageFile(path string, items []PackageItem, o PackageOptions) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = WritePackage(f, items, o)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
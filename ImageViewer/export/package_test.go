@@ -0,0 +1,118 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readZip returns the contents of the files of a zip by name
+func readZip(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], err = ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return files
+}
+
+func TestWritePackage(t *testing.T) {
+	s := taggedSession(t)
+	items := PackageItems(s, []string{"ATTIC"})
+	if len(items) != 1 || items[0].Entry.Name != "IMG_1 ATTIC.png" || items[0].Entry.Original != "IMG_1.png" {
+		t.Fatalf("PackageItems() = %+v", items)
+	}
+	original, err := ioutil.ReadFile(items[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := WritePackage(&b, items, PackageOptions{Name: "Smith"}); err != nil {
+		t.Fatal(err)
+	}
+	files := readZip(t, b.Bytes())
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	want := []string{"Smith/IMG_1 ATTIC.png", "Smith/manifest.csv", "Smith/manifest.json"}
+	if len(names) != len(want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+	for _, name := range want {
+		if files[name] == nil {
+			t.Errorf("%s is missing, files = %v", name, names)
+		}
+	}
+	if !bytes.Equal(files["Smith/IMG_1 ATTIC.png"], original) {
+		t.Error("the copy isn't the original")
+	}
+	if !bytes.Contains(files["Smith/manifest.csv"], []byte("\nIMG_1.png,IMG_1 ATTIC.png,ATTIC,40,30,")) {
+		t.Errorf("manifest.csv =\n%s", files["Smith/manifest.csv"])
+	}
+
+	b.Reset()
+	if err := WritePackage(&b, items, PackageOptions{Name: "Smith", Size: 20}); err != nil {
+		t.Fatal(err)
+	}
+	files = readZip(t, b.Bytes())
+	config, err := jpeg.DecodeConfig(bytes.NewReader(files["Smith/IMG_1 ATTIC.jpg"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 20 || config.Height != 15 {
+		t.Errorf("resized copy is %dx%d, want 20x15", config.Width, config.Height)
+	}
+	if !bytes.Contains(files["Smith/manifest.csv"], []byte("\nIMG_1.png,IMG_1 ATTIC.jpg,ATTIC,20,15,")) {
+		t.Errorf("manifest.csv =\n%s", files["Smith/manifest.csv"])
+	}
+
+	after, err := ioutil.ReadFile(filepath.Join(s.Dir(), "IMG_1 ATTIC.png"))
+	if err != nil || !bytes.Equal(after, original) {
+		t.Errorf("the original changed: %v", err)
+	}
+	if got := s.Images(); !reflect.DeepEqual(got, []string{"IMG_1 ATTIC.png", "IMG_2.png"}) {
+		t.Errorf("images = %v", got)
+	}
+}
+
+func TestWritePackageFileRemovesPartial(t *testing.T) {
+	dir := t.TempDir()
+	items := []PackageItem{{Item: Item{Path: filepath.Join(dir, "missing.png"), Name: "missing.png"}}}
+	out := filepath.Join(dir, "package.zip")
+	if err := WritePackageFile(out, items, PackageOptions{Name: "Smith"}); err == nil {
+		t.Fatal("no error for a missing image")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("partial package left behind: %v", err)
+	}
+}
+
+func TestPackageFile(t *testing.T) {
+	taken := map[string]bool{}
+	got := []string{}
+	for _, name := range []string{"a.jpg", "A.jpg", filepath.Join("attic", "a.jpg"), "a.jpg"} {
+		got = append(got, packageFile(name, taken))
+	}
+	if want := []string{"a.jpg", "A 2.jpg", "attic/a.jpg", "a 3.jpg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("packageFile() = %v, want %v", got, want)
+	}
+}
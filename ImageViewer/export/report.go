@@ -0,0 +1,174 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+	"github.com/jjwinters/image-tagger/ImageViewer/thumbs"
+)
+
+// report layout in millimeters
+const (
+	reportMargin  = 15.0
+	reportGap     = 6.0
+	reportCaption = 10.0
+	reportFooter  = 8.0
+	// reportDPI is the resolution images are embedded with, enough for print
+	reportDPI = 200
+)
+
+// ReportOptions configure a photo report
+type ReportOptions struct {
+	// Title and Info are the header of every page, e.g. the job and its variables
+	Title string
+	Info  []string
+	// PerPage is the number of images on a page, 4 if zero
+	PerPage int
+	// PageSize is "Letter" or "A4", Letter if empty
+	PageSize string
+	Hooks
+}
+
+// ReportItems returns the tagged images of the session, ordered by their first
+// tag in the order of vocabulary. Images of the same tag keep the session order.
+func ReportItems(s *tagger.Session, vocabulary []string) []Item {
+	items := []Item{}
+	for _, item := range Items(s, vocabulary) {
+		if len(item.Tags) > 0 {
+			items = append(items, item)
+		}
+	}
+	rank := func(item Item) int {
+		for i, tag := range vocabulary {
+			if tag == item.Tags[0] {
+				return i
+			}
+		}
+		return len(vocabulary)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return rank(items[i]) < rank(items[j])
+	})
+	return items
+}
+
+// WriteReport writes a PDF photo log of items to w, each image with its tags as caption
+func WriteReport(w io.Writer, items []Item, o ReportOptions) error {
+	if o.PerPage <= 0 {
+		o.PerPage = 4
+	}
+	if o.PageSize == "" {
+		o.PageSize = "Letter"
+	}
+	cols := 1
+	if o.PerPage > 2 {
+		cols = 2
+	}
+	rows := (o.PerPage + cols - 1) / cols
+
+	pdf := fpdf.New("P", "mm", o.PageSize, "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetMargins(reportMargin, reportMargin, reportMargin)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AliasNbPages("")
+	pageW, pageH := pdf.GetPageSize()
+	contentW := pageW - 2*reportMargin
+
+	pdf.SetHeaderFunc(func() {
+		pdf.SetFont("Helvetica", "B", 14)
+		pdf.CellFormat(contentW, 7, tr(o.Title), "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 9)
+		for _, line := range o.Info {
+			pdf.CellFormat(contentW, 4.5, tr(line), "", 1, "L", false, 0, "")
+		}
+		y := pdf.GetY() + 2
+		pdf.Line(reportMargin, y, pageW-reportMargin, y)
+		pdf.SetY(y + 4)
+	})
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-reportMargin)
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(0, 5, fmt.Sprintf("Page %d of {nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	if len(items) == 0 {
+		pdf.AddPage()
+		pdf.SetFont("Helvetica", "", 11)
+		pdf.CellFormat(contentW, 6, "No tagged images.", "", 1, "L", false, 0, "")
+	}
+	var cellW, cellH, top float64
+	for i, item := range items {
+		if i%o.PerPage == 0 {
+			pdf.AddPage()
+			top = pdf.GetY()
+			cellW = (contentW - reportGap*float64(cols-1)) / float64(cols)
+			cellH = (pageH-reportMargin-reportFooter-top-reportGap*float64(rows-1))/float64(rows) - reportCaption
+		}
+		n := i % o.PerPage
+		x := reportMargin + float64(n%cols)*(cellW+reportGap)
+		y := top + float64(n/cols)*(cellH+reportCaption+reportGap)
+		placeImage(pdf, tr, item, o.decode, i, x, y, cellW, cellH)
+
+		pdf.SetXY(x, y+cellH+1)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(cellW, 5, tr(strings.Join(item.Tags, ", ")), "", 2, "C", false, 0, "")
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetTextColor(96, 96, 96)
+		pdf.CellFormat(cellW, 4, tr(item.Name), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+		o.progress(i+1, len(items))
+	}
+	return pdf.Output(w)
+}
+
+// placeImage draws the image of item as large as it fits into the box at x, y,
+// or a frame with a note if it can't be read
+func placeImage(pdf *fpdf.Fpdf, tr func(string) string, item Item, decode func(string) (image.Image, error), i int, x, y, w, h float64) {
+	img, err := decode(item.Path)
+	if err != nil || img.Bounds().Empty() {
+		pdf.Rect(x, y, w, h, "D")
+		pdf.SetXY(x, y+h/2-3)
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(w, 6, tr("Image can't be read"), "", 0, "C", false, 0, "")
+		return
+	}
+
+	b := img.Bounds()
+	scale := math.Min(w/float64(b.Dx()), h/float64(b.Dy()))
+	iw, ih := float64(b.Dx())*scale, float64(b.Dy())*scale
+
+	// embed the image only as large as it is printed
+	img = thumbs.Fit(img, int(math.Ceil(math.Max(iw, ih)/25.4*reportDPI)))
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		pdf.SetError(err)
+		return
+	}
+	name := fmt.Sprintf("image%d", i)
+	options := fpdf.ImageOptions{ImageType: "JPG"}
+	pdf.RegisterImageOptionsReader(name, options, &buf)
+	pdf.ImageOptions(name, x+(w-iw)/2, y+(h-ih)/2, iw, ih, false, options, 0, "")
+}
+
+// WriteReportFile writes the PDF photo report of items to path
+func WriteReportFile(path string, items []Item, o ReportOptions) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteReport(f, items, o); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,75 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/imgcache"
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+)
+
+func TestReportItems(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"IMG_1 REAR.png", "IMG_2.png", "IMG_3 FRONT.png", "IMG_4 ATTIC REAR.png", "IMG_5 FRONT.png"} {
+		writePNG(t, filepath.Join(dir, name), 8, 6)
+	}
+	s, err := tagger.NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vocabulary := []string{"FRONT", "REAR", "ATTIC"}
+	s.SetVocabulary(vocabulary)
+
+	names := []string{}
+	for _, item := range ReportItems(s, vocabulary) {
+		names = append(names, item.Name)
+	}
+	want := []string{"IMG_3 FRONT.png", "IMG_5 FRONT.png", "IMG_1 REAR.png", "IMG_4 ATTIC REAR.png"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("ReportItems() = %v, want %v", names, want)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	dir := t.TempDir()
+	items := []Item{}
+	for i := 1; i <= 5; i++ {
+		name := fmt.Sprintf("IMG_%d FRONT.png", i)
+		writePNG(t, filepath.Join(dir, name), 300, 200)
+		items = append(items, Item{Path: filepath.Join(dir, name), Name: name, Tags: []string{"FRONT"}})
+	}
+	items = append(items, Item{Path: filepath.Join(dir, "missing.png"), Name: "missing.png", Tags: []string{"REAR"}})
+
+	done := 0
+	decoded := 0
+	var b bytes.Buffer
+	err := WriteReport(&b, items, ReportOptions{
+		Title:   "Job 42 Main St",
+		Info:    []string{"Profile: Audit"},
+		PerPage: 4,
+		Hooks: Hooks{
+			Progress: func(n, total int) { done = n },
+			Decode: func(path string) (image.Image, error) {
+				decoded++
+				return imgcache.DecodeFile(path)
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b.Bytes(), []byte("%PDF-")) {
+		t.Fatalf("not a PDF: %q", b.Bytes()[:16])
+	}
+	if pages := len(regexp.MustCompile(`/Type /Page\b[^s]`).FindAll(b.Bytes(), -1)); pages != 2 {
+		t.Errorf("report has %d pages, want 2", pages)
+	}
+	if done != len(items) || decoded != len(items) {
+		t.Errorf("progress %d, decoded %d, want %d", done, decoded, len(items))
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"image/png"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 
 	"fyne.io/fyne/v2"
@@ -16,7 +15,7 @@ import (
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/storage"
 
-	"github.com/disintegration/imageorient"
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
 )
 
 func (a *App) openFileDialog() {
@@ -48,7 +47,7 @@ func (a *App) openFileDialog() {
     // https://github.com/fyne-io/fyne/pull/1379/files
     startLocation, err1 := storage.ListerForURI(storage.NewFileURI(a.config.GetString("ImagePath")))
     if err1 != nil {
-		fmt.Errorf("Error finding startLocation %v", err1)
+		fmt.Fprintf(os.Stderr, "Error finding startLocation %v\n", err1)
     }
     dialog.SetLocation(startLocation)
 
@@ -58,15 +57,16 @@ func (a *App) openFileDialog() {
 func (a *App) open(file *os.File, folder bool) error {
 	defer file.Close()
 
-	// decode and update the image + get image path
+	// decode and update the image + get image path, usually prefetched already
 	var err error
-	a.img.OriginalImage, _, err = imageorient.Decode(file)
+	a.img.OriginalImage, err = a.imageCache.Get(file.Name())
 	if err != nil {
 		return fmt.Errorf("Unable to decode image %v", err)
 	}
 	a.img.Path = file.Name()
-	a.image.Image = a.img.OriginalImage
-	a.image.Refresh()
+	size := a.image.Size()
+	a.img.setDisplayImage(int(fyne.Max(size.Width, size.Height) * a.mainWin.Canvas().Scale()))
+	a.image.SetImage(a.img.DisplayImage, a.img.OriginalImage, 1/a.img.displayScale)
 
 	// get and display FileInfo
 	a.img.FileData, err = os.Stat(a.img.Path)
@@ -80,19 +80,29 @@ func (a *App) open(file *os.File, folder bool) error {
 
 	// save all images from folder for next/back
 	if folder {
-		a.img.Directory = filepath.Dir(file.Name())
-        a.refreshImagesInFolder(file)
+		session, err := tagger.Open(file.Name())
+		if err != nil {
+			return err
+		}
+		a.setSession(session)
 	}
 
 	a.widthLabel.SetText(fmt.Sprintf("Width:   %dpx", a.img.OriginalImage.Bounds().Max.X))
 	a.heightLabel.SetText(fmt.Sprintf("Height: %dpx", a.img.OriginalImage.Bounds().Max.Y))
 
-    fileName := strings.Split(a.img.Path, "/")[len(strings.Split(a.img.Path, "/"))-1]
+    fileName := filepath.Base(a.img.Path)
 	a.mainWin.SetTitle(fmt.Sprintf("Image Tagger - %v", fileName))
-    a.renamePreview.SetText(fileName)
+    a.renamePreview.SetText(a.session.Preview())
+    a.refreshTagViews()
+    a.refreshFilmstrip()
+    a.prefetch()
+    if a.gridWin != nil {
+        a.gridWin.update()
+    }
+    a.reportErrors()
 
     // Save the image path to the config.
-    a.config.Set("imagepath", a.img.Directory)
+    a.config.Set("imagepath", a.session.Dir())
     a.WriteConfig()
 
 	// append to last opened images
@@ -116,9 +126,11 @@ func (a *App) open(file *os.File, folder bool) error {
 	a.zoomIn.Enable()
 	a.zoomOut.Enable()
 	a.resetZoomBtn.Enable()
+	a.fillZoomBtn.Enable()
+	a.actualZoomBtn.Enable()
 
-    for i := 0; i < tagBtnTotal; i++ {
-        a.tagBtns[i].Enable()
+    for _, btn := range a.tagBtns {
+        btn.Enable()
     }
 
 	return nil
@@ -129,10 +141,6 @@ func (a *App) saveFileDialog() {
 		dialog.ShowError(errors.New("no image opened"), a.mainWin)
 		return
 	}
-	if a.img.EditedImage == nil {
-		a.apply()
-	}
-
 	dialog.ShowFileSave(func(writer fyne.URIWriteCloser, err error) {
 		err = a.save(writer)
 		if err != nil {
@@ -146,16 +154,18 @@ func (a *App) save(writer fyne.URIWriteCloser) error {
 	if writer == nil {
 		return nil
 	}
+	// the edits are previewed on the display image, the saved image gets them in full resolution
+	edited := a.img.render(false)
 
 	switch writer.URI().Extension() {
 	case ".jpeg":
-		jpeg.Encode(writer, a.img.EditedImage, nil)
+		jpeg.Encode(writer, edited, nil)
 	case ".jpg":
-		jpeg.Encode(writer, a.img.EditedImage, nil)
+		jpeg.Encode(writer, edited, nil)
 	case ".png":
-		png.Encode(writer, a.img.EditedImage)
+		png.Encode(writer, edited)
 	case ".gif":
-		gif.Encode(writer, a.img.EditedImage, nil)
+		gif.Encode(writer, edited, nil)
 	default:
 		os.Remove(writer.URI().String()[7:])
 		return errors.New("unsupported file extension\n supported extensions: .jpg, .png, .gif")
@@ -164,75 +174,182 @@ func (a *App) save(writer fyne.URIWriteCloser) error {
 }
 
 func (a *App) deleteFile() {
-	if err := os.Remove(a.img.Path); err != nil {
-		dialog.NewError(err, a.mainWin)
+	if a.session == nil || a.img.OriginalImage == nil {
+		return
+	}
+	if err := a.session.Delete(); err != nil {
+		dialog.ShowError(err, a.mainWin)
 		return
 	}
-	if a.img.index == len(a.img.ImagesInFolder)-1 {
-		a.nextImage(false, true)
-	} else if len(a.img.ImagesInFolder) == 1 {
-		a.image.Image = nil
-		a.img.EditedImage = nil
-		a.img.OriginalImage = nil
-		a.rightArrow.Disable()
-		a.leftArrow.Disable()
-		a.deleteBtn.Disable()
-		a.image.Refresh()
+	if a.session.Len() == 0 {
+		a.clearImage()
 	} else {
-		a.nextImage(true, true)
+		a.openCurrent()
 	}
 }
 
-func (a *App) refreshImagesInFolder(file *os.File) {
-    openFolder, _ := os.Open(a.img.Directory)
-    a.img.ImagesInFolder, _ = openFolder.Readdirnames(0)
-    // filter image files
-    imgList := []string{}
-    for _, v := range a.img.ImagesInFolder {
-        if strings.HasSuffix(strings.ToLower(v), ".png") || strings.HasSuffix(strings.ToLower(v), ".jpg") || strings.HasSuffix(strings.ToLower(v), ".jpeg") || strings.HasSuffix(strings.ToLower(v), ".gif") {
-            imgList = append(imgList, v)
-        }
-    }
-    a.img.ImagesInFolder = imgList
-    sort.Strings(a.img.ImagesInFolder) // sort array alphabetically
-
-    // get first index value
-    for i, v := range a.img.ImagesInFolder {
-        if filepath.Base(file.Name()) == v {
-            a.img.index = i
-        }
-    }
+// setSession applies the settings to a new session and makes it the current one
+func (a *App) setSession(session *tagger.Session) {
+	session.SetCollisionPolicy(a.collisionPolicy())
+	session.SetJournal(a.journal)
+	session.SetSortOrder(a.sortOrder())
+	session.SetPermanentDelete(a.permanentDelete())
+	a.selectFolderProfile(session.Dir())
+	session.SetVocabulary(a.buttonTags)
+	session.SetTemplate(a.nameTemplate())
+	session.SetTagStorage(a.tagStorage())
+	session.SetEmbedTags(a.config.GetBool("embedtags"))
+	a.session = session
+	if session.Recursive() {
+		for _, f := range session.Folders() {
+			a.purgeTrash(filepath.Join(session.Dir(), f.Path))
+		}
+	} else {
+		a.purgeTrash(session.Dir())
+	}
+	a.watchSession(session)
+}
+
+// clearImage empties the view after the last image of the folder is gone
+func (a *App) clearImage() {
+	a.image.SetImage(nil, nil, 1)
+	a.img.EditedImage = nil
+	a.img.OriginalImage = nil
+	a.img.nextGeneration()
+	a.img.DisplayImage = nil
+	a.rightArrow.Disable()
+	a.leftArrow.Disable()
+	a.deleteBtn.Disable()
+	a.image.Refresh()
+	a.refreshChecklist()
+	a.refreshFolderTree()
+	a.refreshFilmstrip()
+}
+
+// openPath opens the image at path together with its folder
+func (a *App) openPath(path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		dialog.ShowError(err, a.mainWin)
+		return
+	}
+	if err := a.open(file, true); err != nil {
+		dialog.ShowError(err, a.mainWin)
+	}
+}
+
+// openCurrent opens the current image of the session without reading the folder again
+func (a *App) openCurrent() {
+	file, err := os.Open(a.session.CurrentPath())
+	if err != nil {
+		dialog.ShowError(err, a.mainWin)
+		return
+	}
+	if err := a.open(file, false); err != nil {
+		dialog.ShowError(err, a.mainWin)
+	}
 }
 
-func (a *App) renameImage(s string) {
-    newPath := strings.TrimSuffix(a.img.Path, filepath.Base(a.img.Path)) + s
-    if err := os.Rename(a.img.Path, newPath); err != nil {
+// prefetch decodes the images around the current one in the background, the next one first.
+// Images that were prefetched for another position and aren't started yet are dropped.
+func (a *App) prefetch() {
+	if a.session == nil {
+		return
+	}
+	images, index := a.session.Images(), a.session.Index()
+	paths := []string{}
+	for i := 1; i <= a.config.GetInt("prefetchimages"); i++ {
+		for _, j := range []int{index + i, index - i} {
+			if j >= 0 && j < len(images) {
+				paths = append(paths, filepath.Join(a.session.Dir(), images[j]))
+			}
+		}
+	}
+	a.imageCache.Prefetch(paths)
+}
+
+// renameImage renames the current image and calls done if the rename succeeded.
+// With the prompt collision policy the user is asked before the name is numbered.
+func (a *App) renameImage(s string, done func()) {
+    if a.session == nil {
+        return
+    }
+    err := a.session.Rename(s)
+    if exists, ok := err.(*tagger.ExistsError); ok && a.session.CollisionPolicy() == tagger.CollisionPrompt {
+        dialog.ShowConfirm("File exists", fmt.Sprintf("%s already exists.\nSave as %s instead?", exists.Name, exists.Suggestion), func(b bool) {
+            if b {
+                a.renameImage(exists.Suggestion, done)
+            }
+        }, a.mainWin)
+        return
+    }
+    if err != nil {
         dialog.ShowError(fmt.Errorf("failed to rename file: %v", err), a.mainWin)
         return
     }
-    a.img.Path = newPath
-    a.refreshImagesInFolder(a.file)
-    a.mainWin.SetTitle("Image Tagger - " + s)
+    a.img.Path = a.session.CurrentPath()
+    a.renamePreview.SetText(a.session.Preview())
+    a.mainWin.SetTitle("Image Tagger - " + a.session.Current())
+    a.refreshTagViews()
     //a.mainWin.Canvas().Overlays().Top().Hide()
+    if done != nil {
+        done()
+    }
+}
+
+// collisionPolicy returns the configured rename collision policy
+func (a *App) collisionPolicy() tagger.CollisionPolicy {
+    policy, err := tagger.ParseCollisionPolicy(a.config.GetString("renamecollision"))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v, numbering names instead\n", err)
+    }
+    return policy
+}
+
+// tagStorage returns where the tags are kept according to the config
+func (a *App) tagStorage() tagger.TagStorage {
+    storage, err := tagger.ParseTagStorage(a.config.GetString("tagstorage"))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v, renaming instead\n", err)
+    }
+    return storage
+}
+
+// sortOrder returns the configured order of the images
+func (a *App) sortOrder() tagger.SortOrder {
+    order, err := tagger.ParseSortOrder(a.config.GetString("sortorder"))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v, sorting naturally instead\n", err)
+    }
+    return order
+}
+
+// validateRenamePreview flags a preview name that collides with another file
+func (a *App) validateRenamePreview(name string) error {
+    if a.session == nil || name == "" {
+        return nil
+    }
+    target, conflict := a.session.Resolve(name)
+    if !conflict {
+        return nil
+    }
+    if a.session.CollisionPolicy() == tagger.CollisionSequence {
+        return fmt.Errorf("%s exists, will be saved as %s", name, target)
+    }
+    return fmt.Errorf("%s already exists", name)
 }
 
 func (a *App) renameDialog() {
 	entry := newEnterEntry()
 	entry.enterFunc = func(s string) {
-        a.renameImage(s)
         a.mainWin.Canvas().Overlays().Top().Hide()
+        a.renameImage(s, nil)
 	}
 
 	entry.SetPlaceHolder(filepath.Base(a.img.Path))
 	dialog.ShowCustomConfirm("Rename Image", "Ok", "Cancel", container.NewVBox(entry), func(b bool) {
 		if b {
-			newPath := strings.TrimSuffix(a.img.Path, filepath.Base(a.img.Path)) + entry.Text
-			if err := os.Rename(a.img.Path, newPath); err != nil {
-				dialog.ShowError(fmt.Errorf("failed to rename file: %v", err), a.mainWin)
-				return
-			}
-			a.img.Path = newPath
-			a.mainWin.SetTitle("Image Tagger - " + entry.Text)
+			a.renameImage(entry.Text, nil)
 		}
 	}, a.mainWin)
 }
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"image"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/thumbs"
+)
+
+// thumbSize is the width and height thumbnails are scaled to fit
+const thumbSize = 96
+
+// thumbnail is an image of the filmstrip or the grid with its tags
+type thumbnail struct {
+	widget.BaseWidget
+	image *canvas.Image
+	badge *canvas.Text
+	frame *canvas.Rectangle
+	// onTapped gets the modifier keys held while clicking, e.g. for multi-select
+	onTapped func(fyne.KeyModifier)
+	modifier fyne.KeyModifier
+}
+
+func newThumbnail(onTapped func(fyne.KeyModifier)) *thumbnail {
+	t := &thumbnail{
+		image:    &canvas.Image{FillMode: canvas.ImageFillContain},
+		badge:    canvas.NewText("", theme.ForegroundColor()),
+		frame:    canvas.NewRectangle(theme.BackgroundColor()),
+		onTapped: onTapped,
+	}
+	t.image.SetMinSize(fyne.NewSize(thumbSize, thumbSize*3/4))
+	t.badge.TextSize = theme.CaptionTextSize()
+	t.ExtendBaseWidget(t)
+	return t
+}
+
+func (t *thumbnail) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(container.NewMax(
+		t.frame,
+		container.NewPadded(container.NewBorder(nil, t.badge, nil, nil, t.image)),
+	))
+}
+
+// Tapped calls onTapped with the modifiers of the last mouse press
+func (t *thumbnail) Tapped(*fyne.PointEvent) {
+	if t.onTapped != nil {
+		t.onTapped(t.modifier)
+	}
+}
+
+// MouseDown remembers the modifiers for Tapped, which doesn't get them
+func (t *thumbnail) MouseDown(e *desktop.MouseEvent) {
+	t.modifier = e.Modifier
+}
+
+func (t *thumbnail) MouseUp(*desktop.MouseEvent) {}
+
+// setTags shows tags as the badge, shortened to the width of the thumbnail
+func (t *thumbnail) setTags(tags []string) {
+	text := strings.Join(tags, ", ")
+	if runes := []rune(text); len(runes) > 16 {
+		text = string(runes[:15]) + "…"
+	}
+	if t.badge.Text != text {
+		t.badge.Text = text
+		t.badge.Refresh()
+	}
+}
+
+// setCurrent highlights the thumbnail of the current image, or a selected image in the grid
+func (t *thumbnail) setCurrent(current bool) {
+	color := theme.BackgroundColor()
+	if current {
+		color = theme.PrimaryColor()
+	}
+	if t.frame.FillColor != color {
+		t.frame.FillColor = color
+		t.frame.Refresh()
+	}
+}
+
+// loadFilmstrip returns the scrollable row of thumbnails under the image
+func (a *App) loadFilmstrip() fyne.CanvasObject {
+	a.thumbCache = thumbs.NewCache(filepath.Join(viperPath(), "thumbnails"), thumbSize,
+		int64(a.config.GetInt("thumbnailcachemb"))<<20)
+	a.thumbPool = thumbs.NewPool(a.thumbCache, runtime.NumCPU())
+	a.filmstripBox = container.NewHBox()
+	a.filmstrip = container.NewHScroll(a.filmstripBox)
+	return a.filmstrip
+}
+
+// refreshFilmstrip shows a thumbnail for every image of the session and highlights the current one.
+// When the images changed, only the thumbnails that aren't shown yet are requested.
+func (a *App) refreshFilmstrip() {
+	if a.filmstrip == nil {
+		return
+	}
+	images := []string{}
+	if a.session != nil {
+		images = a.session.Images()
+	}
+	if !sameStrings(images, a.filmstripNames) {
+		old := map[string]*thumbnail{}
+		for i, name := range a.filmstripNames {
+			old[name] = a.thumbnails[i]
+		}
+		a.filmstripNames = append([]string{}, images...)
+		a.thumbnails = make([]*thumbnail, len(images))
+		a.filmstripBox.Objects = nil
+		missing := []string{}
+		waiting := map[string]*thumbnail{}
+		for i, name := range images {
+			name := name
+			t, ok := old[name]
+			if !ok {
+				t = newThumbnail(func(fyne.KeyModifier) {
+					if a.session != nil && a.session.Seek(name) {
+						a.openCurrent()
+					}
+				})
+			}
+			if t.image.Image == nil {
+				path := filepath.Join(a.session.Dir(), name)
+				missing = append(missing, path)
+				waiting[path] = t
+			}
+			t.setTags(a.session.ImageTags(name))
+			a.thumbnails[i] = t
+			a.filmstripBox.Add(t)
+		}
+		a.filmstripBox.Refresh()
+
+		a.thumbPool.Request(missing, func(path string, img image.Image) {
+			// the pool calls back from its workers
+			a.runOnUI(func() {
+				t := waiting[path]
+				t.image.Image = img
+				t.image.Refresh()
+			})
+		})
+	} else if a.session != nil && a.session.Current() != "" {
+		// the tags of the current image may have changed
+		a.thumbnails[a.session.Index()].setTags(a.session.ImageTags(a.session.Current()))
+	}
+
+	for i, t := range a.thumbnails {
+		t.setCurrent(a.session != nil && i == a.session.Index())
+	}
+	a.scrollToCurrent()
+}
+
+// scrollToCurrent scrolls the filmstrip so the thumbnail of the current image is visible
+func (a *App) scrollToCurrent() {
+	if a.session == nil || a.session.Current() == "" || len(a.thumbnails) == 0 {
+		return
+	}
+	t := a.thumbnails[a.session.Index()]
+	left, right := t.Position().X, t.Position().X+t.Size().Width
+	view := a.filmstrip.Size().Width
+	switch {
+	case left < a.filmstrip.Offset.X:
+		a.filmstrip.Offset.X = left
+	case right > a.filmstrip.Offset.X+view:
+		a.filmstrip.Offset.X = right - view
+	default:
+		return
+	}
+	a.filmstrip.Refresh()
+}
+
+// sameStrings reports whether a and b contain the same strings in the same order
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
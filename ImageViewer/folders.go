@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+)
+
+// openFolderDialog opens all images of a folder and its subfolders
+func (a *App) openFolderDialog() {
+	d := dialog.NewFolderOpen(func(uri fyne.ListableURI, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.mainWin)
+			return
+		}
+		if uri == nil {
+			return
+		}
+		a.openFolder(uri.Path())
+	}, a.mainWin)
+	if location, err := storage.ListerForURI(storage.NewFileURI(a.config.GetString("imagepath"))); err == nil {
+		d.SetLocation(location)
+	}
+	d.Show()
+}
+
+// openFolder starts a recursive session over dir and shows its first image
+func (a *App) openFolder(dir string) {
+	session, err := tagger.OpenFolder(dir)
+	if err != nil {
+		dialog.ShowError(err, a.mainWin)
+		return
+	}
+	if session.Len() == 0 {
+		dialog.ShowError(fmt.Errorf("no images in %s or its subfolders", dir), a.mainWin)
+		return
+	}
+	a.setSession(session)
+	a.openCurrent()
+}
+
+// loadFoldersTab returns the tab with the folder tree of the session
+func (a *App) loadFoldersTab() *container.TabItem {
+	a.folderTree = widget.NewTree(
+		func(id widget.TreeNodeID) []widget.TreeNodeID {
+			children := []widget.TreeNodeID{}
+			if id == "" {
+				if len(a.folders) > 0 {
+					children = append(children, ".")
+				}
+				return children
+			}
+			for _, f := range a.folders {
+				if f.Path != "." && filepath.Dir(f.Path) == id {
+					children = append(children, f.Path)
+				}
+			}
+			return children
+		},
+		func(id widget.TreeNodeID) bool {
+			if id == "" {
+				return true
+			}
+			for _, f := range a.folders {
+				if f.Path != "." && filepath.Dir(f.Path) == id {
+					return true
+				}
+			}
+			return false
+		},
+		func(branch bool) fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.TreeNodeID, branch bool, obj fyne.CanvasObject) {
+			for _, f := range a.folders {
+				if f.Path == id {
+					obj.(*widget.Label).SetText(a.folderLabel(f))
+				}
+			}
+		},
+	)
+	a.folderTree.OnSelected = func(id widget.TreeNodeID) {
+		// the tree only navigates, so selecting the same folder again works too
+		a.folderTree.UnselectAll()
+		if a.session != nil && a.session.SeekFolder(id) {
+			a.openCurrent()
+		}
+	}
+
+	return container.NewTabItem("Folders", container.NewBorder(
+		widget.NewLabel("Open a job with File > Open Folder to include its subfolders."),
+		nil, nil, nil,
+		a.folderTree,
+	))
+}
+
+// folderLabel returns the text of a folder in the tree
+func (a *App) folderLabel(f tagger.Folder) string {
+	name := filepath.Base(f.Path)
+	if f.Path == "." {
+		name = filepath.Base(a.session.Dir())
+	}
+	if f.Tagged+f.Untagged == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s (%d tagged, %d untagged)", name, f.Tagged, f.Untagged)
+}
+
+// refreshFolderTree counts the tagged and untagged images of each folder again
+func (a *App) refreshFolderTree() {
+	if a.folderTree == nil {
+		return
+	}
+	a.folders = nil
+	if a.session != nil {
+		a.folders = a.session.Folders()
+	}
+	a.folderTree.Refresh()
+	a.folderTree.OpenAllBranches()
+}
@@ -6,5 +6,8 @@ require (
 	fyne.io/fyne/v2 v2.4.1
 	github.com/disintegration/gift v1.2.1
 	github.com/disintegration/imageorient v0.0.0-20180920195336-8147d86e83ec
+	github.com/fsnotify/fsnotify v1.6.0
+	github.com/go-pdf/fpdf v0.9.0
 	github.com/spf13/viper v1.17.0
+	gopkg.in/yaml.v3 v3.0.1
 )
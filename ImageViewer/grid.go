@@ -0,0 +1,356 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+	"github.com/jjwinters/image-tagger/ImageViewer/thumbs"
+)
+
+// gridView is the window that shows all images of the session as a grid,
+// so a tag can be applied to many selected images at once. The window handles
+// its input on its own goroutine; the handlers hand over to the main window's
+// with runOnUI, where the session and the grid state are changed.
+type gridView struct {
+	app    *App
+	win    fyne.Window
+	area    *selectionArea
+	status  *widget.Label
+	tagBtns *fyne.Container
+	pool    *thumbs.Pool
+
+	names []string
+	items []*thumbnail
+	// selected are the selected images by their path relative to the session directory
+	selected map[string]bool
+	// anchor is the image a shift click selects from
+	anchor string
+}
+
+// showGrid opens the grid view of the images, or brings it to the front
+func (a *App) showGrid() {
+	if a.session == nil {
+		dialog.ShowError(fmt.Errorf("no folder opened"), a.mainWin)
+		return
+	}
+	if a.gridWin != nil {
+		a.gridWin.refresh()
+		a.gridWin.win.RequestFocus()
+		return
+	}
+	g := &gridView{
+		app:      a,
+		win:      a.app.NewWindow("Grid"),
+		status:   widget.NewLabel(""),
+		tagBtns:  container.NewGridWithColumns(4),
+		pool:     thumbs.NewPool(a.thumbCache, runtime.NumCPU()),
+		selected: map[string]bool{},
+	}
+	g.area = newSelectionArea(func(indexes []int) {
+		a.runOnUI(func() { g.selectBand(indexes) })
+	})
+	g.setTagButtons(a.buttonTags)
+	selectAll := widget.NewButton("Select All", func() {
+		a.runOnUI(func() {
+			for _, name := range g.names {
+				g.selected[name] = true
+			}
+			g.showSelection()
+		})
+	})
+	selectNone := widget.NewButton("Select None", func() {
+		a.runOnUI(func() {
+			g.selected = map[string]bool{}
+			g.showSelection()
+		})
+	})
+
+	g.win.SetContent(container.NewBorder(
+		container.NewBorder(nil, nil, nil, container.NewHBox(selectAll, selectNone), g.status),
+		container.NewVBox(
+			widget.NewLabel("Click a tag to add it to all selected images, or to remove it if they all have it."),
+			g.tagBtns,
+		),
+		nil, nil,
+		container.NewVScroll(g.area),
+	))
+	g.win.SetOnClosed(func() {
+		g.pool.Close()
+		a.gridWin = nil
+	})
+	g.win.Resize(fyne.NewSize(900, 700))
+	a.gridWin = g
+	g.refresh()
+	g.win.Show()
+}
+
+// setTagButtons shows a button for each of tags, e.g. after the tag buttons of the main window changed
+func (g *gridView) setTagButtons(tags []string) {
+	g.tagBtns.Objects = nil
+	for _, tag := range tags {
+		if tag == "" {
+			continue
+		}
+		tag := tag
+		g.tagBtns.Add(widget.NewButton(tag, func() {
+			g.app.runOnUI(func() { g.toggleTag(tag) })
+		}))
+	}
+	g.tagBtns.Refresh()
+}
+
+// refresh shows the images of the session again, keeping the selection of images that still exist
+func (g *gridView) refresh() {
+	a := g.app
+	g.names = append([]string{}, a.session.Images()...)
+	g.items = make([]*thumbnail, len(g.names))
+	g.area.grid.Objects = nil
+	paths := make([]string, len(g.names))
+	index := map[string]int{}
+	selected := map[string]bool{}
+	for i, name := range g.names {
+		name := name
+		g.items[i] = newThumbnail(func(mod fyne.KeyModifier) {
+			a.runOnUI(func() { g.click(name, mod) })
+		})
+		g.items[i].setTags(a.session.ImageTags(name))
+		g.area.grid.Add(g.items[i])
+		paths[i] = filepath.Join(a.session.Dir(), name)
+		index[paths[i]] = i
+		if g.selected[name] {
+			selected[name] = true
+		}
+	}
+	g.selected = selected
+	g.area.grid.Refresh()
+
+	items := g.items
+	g.pool.Request(paths, func(path string, img image.Image) {
+		// the pool calls back from its workers
+		g.app.runOnUI(func() {
+			t := items[index[path]]
+			t.image.Image = img
+			t.image.Refresh()
+		})
+	})
+	g.showSelection()
+}
+
+// update shows the images of the session again if they changed, e.g. after another folder was opened
+func (g *gridView) update() {
+	if !sameStrings(g.app.session.Images(), g.names) {
+		g.refresh()
+	}
+}
+
+// click selects name alone, adds it to the selection with Ctrl, or selects the range from the anchor with Shift
+func (g *gridView) click(name string, mod fyne.KeyModifier) {
+	switch {
+	case mod&fyne.KeyModifierShift != 0 && g.anchor != "":
+		from, to := g.indexOf(g.anchor), g.indexOf(name)
+		if from > to {
+			from, to = to, from
+		}
+		if mod&(fyne.KeyModifierControl|fyne.KeyModifierSuper) == 0 {
+			g.selected = map[string]bool{}
+		}
+		for i := from; i <= to && i >= 0; i++ {
+			g.selected[g.names[i]] = true
+		}
+	case mod&(fyne.KeyModifierControl|fyne.KeyModifierSuper) != 0:
+		if g.selected[name] {
+			delete(g.selected, name)
+		} else {
+			g.selected[name] = true
+		}
+		g.anchor = name
+	default:
+		g.selected = map[string]bool{name: true}
+		g.anchor = name
+	}
+	g.showSelection()
+}
+
+// selectBand selects the images under the rubber band. The indexes were found
+// while dragging, images the grid lost since then are left out.
+func (g *gridView) selectBand(indexes []int) {
+	g.selected = map[string]bool{}
+	anchor := ""
+	for _, i := range indexes {
+		if i < 0 || i >= len(g.names) {
+			continue
+		}
+		g.selected[g.names[i]] = true
+		if anchor == "" {
+			anchor = g.names[i]
+		}
+	}
+	if anchor != "" {
+		g.anchor = anchor
+	}
+	g.showSelection()
+}
+
+func (g *gridView) indexOf(name string) int {
+	for i, n := range g.names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// selection returns the selected images in the order of the grid
+func (g *gridView) selection() []string {
+	names := []string{}
+	for _, name := range g.names {
+		if g.selected[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (g *gridView) showSelection() {
+	for i, t := range g.items {
+		t.setCurrent(g.selected[g.names[i]])
+	}
+	g.status.SetText(fmt.Sprintf("%d of %d images selected. Ctrl+click adds, Shift+click selects a range, drag to select an area.",
+		len(g.selected), len(g.names)))
+}
+
+// toggleTag shows the names the selected images get with tag toggled and renames them after confirmation
+func (g *gridView) toggleTag(tag string) {
+	a := g.app
+	names := g.selection()
+	if len(names) == 0 {
+		dialog.ShowInformation("Grid", "Select the images to tag first", g.win)
+		return
+	}
+	items := a.session.PlanToggle(names, tag)
+
+	lines := []string{}
+	conflicts := 0
+	for _, item := range items {
+		line := fmt.Sprintf("%s → %s", item.Name, item.Target)
+		switch {
+		case item.Conflict:
+			line += "  (exists, skipped)"
+			conflicts++
+		case !item.Changed():
+			line = fmt.Sprintf("%s: %s", item.Name, strings.Join(item.Tags, ", "))
+		}
+		lines = append(lines, line)
+	}
+	list := widget.NewList(
+		func() int { return len(lines) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(lines[id])
+		},
+	)
+	message := fmt.Sprintf("%d images get these names:", len(items))
+	if conflicts > 0 {
+		message += fmt.Sprintf("\n%d names are taken and will be skipped, see Preferences > If the new name exists.", conflicts)
+	}
+	content := container.NewBorder(widget.NewLabel(message), nil, nil, nil, list)
+	d := dialog.NewCustomConfirm("Tag "+tag, "Apply", "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		a.runOnUI(func() { g.apply(items) })
+	}, g.win)
+	d.Resize(fyne.NewSize(700, 500))
+	d.Show()
+}
+
+// apply renames the images of a batch and keeps them selected under their new names
+func (g *gridView) apply(items []tagger.BatchItem) {
+	a := g.app
+	err := a.session.ApplyBatch(items)
+	for _, item := range items {
+		if item.Conflict || !item.Changed() {
+			continue
+		}
+		delete(g.selected, item.Name)
+		g.selected[filepath.Join(filepath.Dir(item.Name), item.Target)] = true
+	}
+	if err != nil {
+		dialog.ShowError(err, g.win)
+	}
+	g.refresh()
+	if a.session.Len() > 0 {
+		a.openCurrent()
+	}
+}
+
+// selectionArea lays out thumbnails in a grid. Dragging over it selects the
+// thumbnails inside the rubber band.
+type selectionArea struct {
+	widget.BaseWidget
+	grid *fyne.Container
+	band *canvas.Rectangle
+
+	start    fyne.Position
+	dragging bool
+	onBand   func(indexes []int)
+}
+
+func newSelectionArea(onBand func(indexes []int)) *selectionArea {
+	s := &selectionArea{
+		grid:   container.NewGridWrap(fyne.NewSize(thumbSize+2*theme.Padding(), thumbSize+theme.CaptionTextSize()+4*theme.Padding())),
+		band:   canvas.NewRectangle(theme.SelectionColor()),
+		onBand: onBand,
+	}
+	s.band.StrokeColor = theme.PrimaryColor()
+	s.band.StrokeWidth = 1
+	s.band.Hide()
+	s.ExtendBaseWidget(s)
+	return s
+}
+
+func (s *selectionArea) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(container.NewMax(s.grid, container.NewWithoutLayout(s.band)))
+}
+
+// Dragged draws the rubber band from where the drag started and selects the thumbnails it touches
+func (s *selectionArea) Dragged(e *fyne.DragEvent) {
+	if !s.dragging {
+		s.dragging = true
+		s.start = e.Position.Subtract(e.Dragged)
+	}
+	topLeft := fyne.NewPos(fyne.Min(s.start.X, e.Position.X), fyne.Min(s.start.Y, e.Position.Y))
+	bottomRight := fyne.NewPos(fyne.Max(s.start.X, e.Position.X), fyne.Max(s.start.Y, e.Position.Y))
+	size := fyne.NewSize(bottomRight.X-topLeft.X, bottomRight.Y-topLeft.Y)
+	s.band.Move(topLeft)
+	s.band.Resize(size)
+	s.band.Show()
+	s.band.Refresh()
+
+	indexes := []int{}
+	for i, obj := range s.grid.Objects {
+		pos, objSize := obj.Position(), obj.Size()
+		if pos.X < topLeft.X+size.Width && topLeft.X < pos.X+objSize.Width &&
+			pos.Y < topLeft.Y+size.Height && topLeft.Y < pos.Y+objSize.Height {
+			indexes = append(indexes, i)
+		}
+	}
+	s.onBand(indexes)
+}
+
+// DragEnd removes the rubber band
+func (s *selectionArea) DragEnd() {
+	s.dragging = false
+	s.band.Hide()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+)
+
+const journalFilename = "journal.json"
+
+// openJournal loads the rename/delete journal from the config directory
+func (a *App) openJournal() {
+	journal, err := tagger.OpenJournal(filepath.Join(viperPath(), journalFilename))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading journal: %v. File operations can't be undone.\n", err)
+		journal = nil
+	}
+	a.journal = journal
+}
+
+// undoFileOperation reverts the last rename or delete, also from an earlier session
+func (a *App) undoFileOperation() {
+	if a.journal == nil {
+		return
+	}
+	op, err := a.journal.Undo()
+	if err != nil {
+		dialog.ShowError(err, a.mainWin)
+		return
+	}
+	a.afterFileOperation(op, op.From)
+}
+
+// redoFileOperation applies the last undone rename or delete again
+func (a *App) redoFileOperation() {
+	if a.journal == nil {
+		return
+	}
+	op, err := a.journal.Redo()
+	if err != nil {
+		dialog.ShowError(err, a.mainWin)
+		return
+	}
+	name := op.To
+	if op.Kind == tagger.OpDelete {
+		name = ""
+	}
+	a.afterFileOperation(op, name)
+}
+
+// afterFileOperation updates the view after an undo or redo and selects the file
+// named name, if it isn't empty
+func (a *App) afterFileOperation(op tagger.Operation, name string) {
+	if a.historyList != nil {
+		a.historyList.Refresh()
+	}
+	var rel string
+	inSession := false
+	if a.session != nil {
+		rel, inSession = a.session.Locate(op.Dir, name)
+	}
+	if !inSession {
+		// the operation happened in another folder, open the file there
+		if name != "" && tagger.IsImage(name) {
+			a.openPath(filepath.Join(op.Dir, name))
+		}
+		return
+	}
+	if err := a.session.Refresh(); err != nil {
+		dialog.ShowError(err, a.mainWin)
+		return
+	}
+	a.session.Seek(rel)
+	if a.session.Len() == 0 {
+		a.clearImage()
+		return
+	}
+	a.openCurrent()
+}
+
+// showHistory lists the file operations of the current folder
+func (a *App) showHistory() {
+	if a.journal == nil {
+		dialog.ShowError(fmt.Errorf("no journal available"), a.mainWin)
+		return
+	}
+	dir := ""
+	if a.session != nil {
+		dir = a.session.CurrentDir()
+	}
+	history := func() []tagger.Operation {
+		return a.journal.History(dir)
+	}
+
+	win := a.app.NewWindow("History")
+	a.historyList = widget.NewList(
+		func() int { return len(history()) },
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			ops := history()
+			// newest first
+			op := ops[len(ops)-1-id]
+			obj.(*widget.Label).SetText(fmt.Sprintf("%s  %s", op.Time.Format("2006-01-02 15:04"), op))
+		},
+	)
+	title := "No folder opened"
+	if dir != "" {
+		title = dir
+	}
+	win.SetContent(container.NewBorder(
+		widget.NewLabel(title),
+		container.NewHBox(
+			// the journal and the session belong to the main window's goroutine
+			widget.NewButton("Undo", func() { a.runOnUI(a.undoFileOperation) }),
+			widget.NewButton("Redo", func() { a.runOnUI(a.redoFileOperation) }),
+		),
+		nil, nil,
+		a.historyList,
+	))
+	win.SetOnClosed(func() { a.historyList = nil })
+	win.Resize(fyne.NewSize(500, 400))
+	win.Show()
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+)
+
+// DefaultButtonHotkeys binds the first nine tag buttons to the number keys
+func DefaultButtonHotkeys() []string {
+	hotkeys := make([]string, len(DefaultButtonTags()))
+	for i := 0; i < 9; i++ {
+		hotkeys[i] = fmt.Sprint(i + 1)
+	}
+	return hotkeys
+}
+
+// tagHotkey is a key or chord bound to a tag button, e.g. "1", "Shift+F" or "Ctrl+Alt+3"
+type tagHotkey struct {
+	key      fyne.KeyName
+	modifier fyne.KeyModifier
+}
+
+var hotkeyModifiers = map[string]fyne.KeyModifier{
+	"shift": fyne.KeyModifierShift,
+	"ctrl":  fyne.KeyModifierControl,
+	"alt":   fyne.KeyModifierAlt,
+	"super": fyne.KeyModifierSuper,
+	"cmd":   fyne.KeyModifierSuper,
+}
+
+// reservedHotkeys are already used by loadKeyboardShortcuts and can't be bound to tags
+var reservedHotkeys = []string{
+	"Left", "Right", "Return", "Enter", "Delete", "Escape", "F2", "F11", "+", "-", "=", "0",
+	"Ctrl+O", "Ctrl+S", "Ctrl+Z", "Ctrl+Y", "Ctrl+Q", "Ctrl+Shift+Z", "Ctrl+Shift+Y",
+	"Super+O", "Super+S", "Super+Z", "Super+Y", "Super+Q", "Super+Shift+Z", "Super+Shift+Y",
+}
+
+// parseTagHotkey parses a hotkey like "Shift+F". An empty string is a valid, unbound hotkey.
+func parseTagHotkey(s string) (tagHotkey, error) {
+	h := tagHotkey{}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return h, nil
+	}
+
+	parts := strings.Split(s, "+")
+	key := strings.TrimSpace(parts[len(parts)-1])
+	mods := parts[:len(parts)-1]
+	// "Ctrl++" and "+" bind the plus key
+	if key == "" && len(mods) > 0 && mods[len(mods)-1] == "" {
+		key = "+"
+		mods = mods[:len(mods)-1]
+	}
+	if key == "" {
+		return h, fmt.Errorf("hotkey %q has no key", s)
+	}
+	for _, p := range mods {
+		mod, ok := hotkeyModifiers[strings.ToLower(strings.TrimSpace(p))]
+		if !ok {
+			return h, fmt.Errorf("unknown modifier %q in hotkey %q", p, s)
+		}
+		h.modifier |= mod
+	}
+
+	switch {
+	case strings.ToLower(key) == "enter":
+		h.key = fyne.KeyEnter
+	case len(key) == 1:
+		h.key = fyne.KeyName(strings.ToUpper(key))
+		if !strings.Contains(singleKeys, string(h.key)) {
+			return h, fmt.Errorf("unknown key %q in hotkey %q", key, s)
+		}
+	default:
+		// named keys such as F5, Space or Home
+		for _, k := range namedKeys {
+			if strings.EqualFold(key, string(k)) {
+				h.key = k
+				return h, nil
+			}
+		}
+		return h, fmt.Errorf("unknown key %q in hotkey %q", key, s)
+	}
+	return h, nil
+}
+
+// singleKeys are the keys named by one character
+const singleKeys = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ',-./\\[];=*+`"
+
+// namedKeys are the keys named by a word
+var namedKeys = []fyne.KeyName{
+	fyne.KeyEscape, fyne.KeyReturn, fyne.KeyTab, fyne.KeyBackspace, fyne.KeyInsert, fyne.KeyDelete,
+	fyne.KeyRight, fyne.KeyLeft, fyne.KeyDown, fyne.KeyUp, fyne.KeyPageUp, fyne.KeyPageDown,
+	fyne.KeyHome, fyne.KeyEnd, fyne.KeySpace,
+	fyne.KeyF1, fyne.KeyF2, fyne.KeyF3, fyne.KeyF4, fyne.KeyF5, fyne.KeyF6,
+	fyne.KeyF7, fyne.KeyF8, fyne.KeyF9, fyne.KeyF10, fyne.KeyF11, fyne.KeyF12,
+}
+
+func (h tagHotkey) isEmpty() bool {
+	return h.key == ""
+}
+
+// isChord reports whether the hotkey has to be registered as a canvas shortcut.
+// Plain keys and Shift+key are delivered through TypedKey instead.
+func (h tagHotkey) isChord() bool {
+	return h.modifier&^fyne.KeyModifierShift != 0
+}
+
+func (h tagHotkey) String() string {
+	if h.isEmpty() {
+		return ""
+	}
+	s := ""
+	if h.modifier&fyne.KeyModifierControl != 0 {
+		s += "Ctrl+"
+	}
+	if h.modifier&fyne.KeyModifierAlt != 0 {
+		s += "Alt+"
+	}
+	if h.modifier&fyne.KeyModifierSuper != 0 {
+		s += "Super+"
+	}
+	if h.modifier&fyne.KeyModifierShift != 0 {
+		s += "Shift+"
+	}
+	switch h.key {
+	case fyne.KeyPlus:
+		return s + "+"
+	case fyne.KeyMinus:
+		return s + "-"
+	case fyne.KeyEqual:
+		return s + "="
+	}
+	return s + string(h.key)
+}
+
+// validateTagHotkeys checks every slot for syntax errors, reserved keys and duplicates
+func validateTagHotkeys(hotkeys []string) error {
+	reserved := map[string]bool{}
+	for _, r := range reservedHotkeys {
+		h, _ := parseTagHotkey(r)
+		reserved[h.String()] = true
+	}
+
+	seen := map[string]int{}
+	for i, s := range hotkeys {
+		h, err := parseTagHotkey(s)
+		if err != nil {
+			return fmt.Errorf("tag button %d: %v", i+1, err)
+		}
+		if h.isEmpty() {
+			continue
+		}
+		if reserved[h.String()] {
+			return fmt.Errorf("tag button %d: %s is already used by another shortcut", i+1, h)
+		}
+		if j, ok := seen[h.String()]; ok {
+			return fmt.Errorf("tag buttons %d and %d both use %s", j+1, i+1, h)
+		}
+		seen[h.String()] = i
+	}
+	return nil
+}
+
+// tagButtonText returns the text shown on a tag button face
+func tagButtonText(tag, hotkey string) string {
+	h, err := parseTagHotkey(hotkey)
+	if err != nil || h.isEmpty() || tag == "" {
+		return tag
+	}
+	return fmt.Sprintf("%s  [%s]", tag, h)
+}
+
+// pressTagButton triggers the tag button in slot i as if it was clicked
+func (a *App) pressTagButton(i int) {
+	if i < 0 || i >= len(a.tagBtns) {
+		return
+	}
+	btn := a.tagBtns[i]
+	if btn.Disabled() || !btn.Visible() || btn.OnTapped == nil {
+		return
+	}
+	btn.OnTapped()
+}
+
+// bindTagHotkeys (re)registers the hotkeys of all tag buttons on the main window
+func (a *App) bindTagHotkeys(hotkeys []string) {
+	for _, s := range a.tagShortcuts {
+		a.mainWin.Canvas().RemoveShortcut(s)
+	}
+	a.tagShortcuts = nil
+	a.tagHotkeys = map[tagHotkey]int{}
+
+	for i, s := range hotkeys {
+		h, err := parseTagHotkey(s)
+		if err != nil || h.isEmpty() {
+			continue
+		}
+		index := i
+		if h.isChord() {
+			shortcut := &desktop.CustomShortcut{KeyName: h.key, Modifier: h.modifier}
+			a.mainWin.Canvas().AddShortcut(shortcut, func(fyne.Shortcut) { a.pressTagButton(index) })
+			a.tagShortcuts = append(a.tagShortcuts, shortcut)
+			continue
+		}
+		a.tagHotkeys[h] = index
+	}
+}
+
+// typedTagHotkey handles plain and Shift+key hotkeys. It returns true if the key triggered a tag button.
+func (a *App) typedTagHotkey(key *fyne.KeyEvent) bool {
+	h := tagHotkey{key: key.Name}
+	if a.shiftDown {
+		h.modifier = fyne.KeyModifierShift
+	}
+	i, ok := a.tagHotkeys[h]
+	if !ok {
+		return false
+	}
+	a.pressTagButton(i)
+	return true
+}
@@ -2,24 +2,39 @@ package main
 
 import (
 	"image"
+	"math"
 	"os"
 	"strconv"
+	"sync"
 
 	"github.com/disintegration/gift"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/thumbs"
 )
 
+// minDisplaySize is the least width and height of the display image, used while the canvas has no size yet
+const minDisplaySize = 1280
+
 // Img is used for the whole image editing process
 type Img struct {
 	OriginalImage  image.Image
+	// DisplayImage is OriginalImage scaled down to the size of the canvas. The filters run on
+	// it while editing, and on OriginalImage only when the image is saved.
+	DisplayImage   image.Image
+	displayScale   float64
+	// displayFilters are the variants for DisplayImage of filters that depend on the image size
+	displayFilters map[gift.Filter]gift.Filter
 	FileData       os.FileInfo
+	// EditedImage is DisplayImage with the filters applied
 	EditedImage    *image.RGBA
 	gifted         *gifted
 	Path           string
-	ImagesInFolder []string
-	index          int
-	Directory      string
 
-	zoom int
+	// generation counts the changes of the edits, so an outdated render is dropped.
+	// mu guards it and renderingFull, renders finish on other goroutines.
+	mu            sync.Mutex
+	generation    int
+	renderingFull bool
 
 	// saved filters
 	// general
@@ -49,6 +64,60 @@ type Img struct {
 func (i *Img) init() {
 	i.gifted = &gifted{}
 	i.gifted.GIFT = gift.New()
+	i.displayFilters = map[gift.Filter]gift.Filter{}
+}
+
+// setDisplayImage makes a copy of OriginalImage that is no larger than size pixels wide and high
+func (i *Img) setDisplayImage(size int) {
+	if size < minDisplaySize {
+		size = minDisplaySize
+	}
+	i.DisplayImage = thumbs.Fit(i.OriginalImage, size)
+	i.displayScale = float64(i.DisplayImage.Bounds().Dx()) / float64(i.OriginalImage.Bounds().Dx())
+}
+
+// render runs the filters on OriginalImage, or on DisplayImage if display is set
+func (i *Img) render(display bool) *image.RGBA {
+	return drawFilters(i.renderer(display))
+}
+
+// renderer returns the image and the filters render uses. They are a copy, so
+// they can be drawn in the background while the edits go on.
+func (i *Img) renderer(display bool) (image.Image, *gift.GIFT) {
+	src := i.OriginalImage
+	g := gift.New()
+	for _, f := range i.gifted.Filters {
+		if scaled, ok := i.displayFilters[f]; ok && display {
+			f = scaled
+		}
+		g.Add(f)
+	}
+	if display {
+		src = i.DisplayImage
+	}
+	return src, g
+}
+
+// drawFilters runs the filters g on src
+func drawFilters(src image.Image, g *gift.GIFT) *image.RGBA {
+	dst := image.NewRGBA(g.Bounds(src.Bounds()))
+	g.Draw(dst, src)
+	return dst
+}
+
+// nextGeneration marks a change of the edits and returns its generation
+func (i *Img) nextGeneration() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.generation++
+	return i.generation
+}
+
+// isGeneration reports whether the edits didn't change since generation
+func (i *Img) isGeneration(generation int) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.generation == generation
 }
 
 func (a *App) changeParameter(filterVar *gift.Filter, newFilter gift.Filter) {
@@ -59,8 +128,19 @@ func (a *App) changeParameter(filterVar *gift.Filter, newFilter gift.Filter) {
 	a.img.gifted.replace(*filterVar, newFilter)
 	*filterVar = newFilter
 	a.img.lastFilters = append(a.img.lastFilters, newFilter)
-	go a.apply()
+	a.apply()
+
+}
 
+// changeScaledParameter is changeParameter for filters that depend on the image size, like blur.
+// scaled returns the filter for the image scaled by scale.
+func (a *App) changeScaledParameter(filterVar *gift.Filter, scaled func(scale float64) gift.Filter) {
+	if a.img.OriginalImage == nil {
+		return
+	}
+	filter := scaled(1)
+	a.img.displayFilters[filter] = scaled(a.img.displayScale)
+	a.changeParameter(filterVar, filter)
 }
 
 func (a *App) addParameter(filter gift.Filter) {
@@ -69,17 +149,26 @@ func (a *App) addParameter(filter gift.Filter) {
 	}
 	a.img.gifted.Add(filter)
 	a.img.lastFilters = append(a.img.lastFilters, filter)
-	go a.apply()
+	a.apply()
 }
 
+// apply renders the filters on the display image in the background and shows it
 func (a *App) apply() {
-	// apply filters
-	a.img.EditedImage = image.NewRGBA(a.img.gifted.Bounds(a.img.OriginalImage.Bounds()))
-	a.img.gifted.Draw(a.img.EditedImage, a.img.OriginalImage)
-
-	// show new image
-	a.image.Image = a.img.EditedImage
-	a.image.Refresh()
+	// apply filters to the display image, saving applies them to the original
+	src, g := a.img.renderer(true)
+	generation := a.img.nextGeneration()
+	go func() {
+		edited := drawFilters(src, g)
+		a.runOnUI(func() {
+			if !a.img.isGeneration(generation) {
+				// edited again or another image opened meanwhile
+				return
+			}
+			// show new image, the full resolution is rendered again when needed
+			a.img.EditedImage = edited
+			a.image.SetImage(edited, nil, 1/a.img.displayScale)
+		})
+	}()
 }
 
 func (a *App) reset() {
@@ -100,9 +189,11 @@ func (a *App) reset() {
 	a.img.gifted.Empty()
 	a.img.lastFilters = nil
 	a.img.lastFiltersUndone = nil
+	a.img.displayFilters = map[gift.Filter]gift.Filter{}
 
 	a.img.EditedImage = nil
-	a.image.Image = a.img.OriginalImage
+	a.img.nextGeneration()
+	a.image.SetImage(a.img.DisplayImage, a.img.OriginalImage, 1/a.img.displayScale)
 }
 
 func (a *App) undo() {
@@ -129,61 +220,55 @@ func (a *App) zoomImageIn() {
 	if a.img.OriginalImage == nil {
 		return
 	}
-
-	src := a.img.OriginalImage
-
-	my_width := src.Bounds().Dx() - a.img.zoom
-	my_height := src.Bounds().Dy() - a.img.zoom
-
-	if a.img.zoom < my_width {
-		a.img.zoom += 25
-	}
-	g := gift.New(
-		gift.Crop(image.Rect(a.img.zoom, a.img.zoom, my_width, my_height)),
-	)
-	dst := image.NewRGBA(g.Bounds(src.Bounds()))
-	g.Draw(dst, src)
-
-	// show new image
-	a.image.Image = dst
-	a.image.Refresh()
-
-	// show zoom level
-	a.zoomLabel.SetText(strconv.Itoa(100+a.img.zoom) + "%")
+	a.image.ZoomBy(1.25)
 }
 
 func (a *App) zoomImageOut() {
 	if a.img.OriginalImage == nil {
 		return
 	}
-	if a.img.zoom > 0 {
-		a.img.zoom -= 25
+	a.image.ZoomBy(0.8)
+}
 
+// zoomActual shows every pixel of the full resolution image
+func (a *App) zoomActual() {
+	if a.img.OriginalImage == nil {
+		return
 	}
-	src := a.img.OriginalImage
-
-	my_width := src.Bounds().Dx() - a.img.zoom
-	my_height := src.Bounds().Dy() - a.img.zoom
-
-	g := gift.New(
-		gift.Crop(image.Rect(a.img.zoom, a.img.zoom, my_width, my_height)),
-	)
-	dst := image.NewRGBA(g.Bounds(src.Bounds()))
-	g.Draw(dst, src)
-
-	// show new image
-	a.image.Image = dst
-	a.image.Refresh()
-
-	// show zoom level
-	a.zoomLabel.SetText(strconv.Itoa(100+a.img.zoom) + "%")
+	a.image.Actual()
 }
 
+// resetZoom fits the whole image into the view
 func (a *App) resetZoom() {
-	a.img.zoom = 0
-	a.zoomLabel.SetText("100%")
+	a.image.Fit()
+}
+
+// showZoom shows the zoom in percent of the full resolution image
+func (a *App) showZoom(zoom float64) {
+	a.zoomLabel.SetText(strconv.Itoa(int(math.Round(zoom*100))) + "%")
+}
 
-	// show new image
-	a.image.Image = a.img.OriginalImage
-	a.image.Refresh()
+// renderFull applies the filters to the full resolution image in the background,
+// for zooming in further than the display image allows. It runs on the UI goroutine
+// like the edits, so the filters are copied before they change.
+func (a *App) renderFull() {
+	a.img.mu.Lock()
+	defer a.img.mu.Unlock()
+	if a.img.renderingFull {
+		return
+	}
+	a.img.renderingFull = true
+	generation := a.img.generation
+	src, g := a.img.renderer(false)
+	go func() {
+		full := drawFilters(src, g)
+		a.img.mu.Lock()
+		a.img.renderingFull = false
+		a.img.mu.Unlock()
+		a.runOnUI(func() {
+			if a.img.isGeneration(generation) {
+				a.image.SetFull(full)
+			}
+		})
+	}()
 }
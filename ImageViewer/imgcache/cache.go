@@ -0,0 +1,223 @@
+// Package imgcache decodes images ahead of time in the background and keeps
+// them in a memory-bounded cache, so moving to the next image doesn't wait for the decoder.
+package imgcache
+
+import (
+	"container/list"
+	"image"
+	"os"
+	"sync"
+
+	// decoders of the supported image formats
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/disintegration/imageorient"
+)
+
+// key identifies a version of a file, so a changed file is decoded again
+type key struct {
+	path     string
+	modified int64
+	size     int64
+}
+
+type entry struct {
+	key   key
+	image image.Image
+	bytes int64
+}
+
+// call is a decode in progress that other callers can wait for
+type call struct {
+	done  chan struct{}
+	image image.Image
+	err   error
+}
+
+// Cache holds decoded images up to a number of bytes and drops the least recently used ones first
+type Cache struct {
+	maxBytes int64
+	workers  int
+	// Decode decodes the image at path, by default turned upright by its EXIF orientation
+	Decode func(path string) (image.Image, error)
+
+	mu       sync.Mutex
+	used     int64
+	entries  map[key]*list.Element
+	lru      *list.List
+	inflight map[key]*call
+	// generation counts the prefetch requests, older requests stop early
+	generation int
+}
+
+// New returns a cache for up to maxBytes of decoded pixels that prefetches with workers goroutines
+func New(maxBytes int64, workers int) *Cache {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Cache{
+		maxBytes: maxBytes,
+		workers:  workers,
+		Decode:   DecodeFile,
+		entries:  map[key]*list.Element{},
+		lru:      list.New(),
+		inflight: map[key]*call{},
+	}
+}
+
+// Get returns the decoded image at path from the cache, waiting for a prefetch
+// of it in progress, or decodes it now
+func (c *Cache) Get(path string) (image.Image, error) {
+	k, err := fileKey(path)
+	if err != nil {
+		return nil, err
+	}
+	return c.get(k)
+}
+
+// Cached reports whether the current version of the image at path is decoded already
+func (c *Cache) Cached(path string) bool {
+	k, err := fileKey(path)
+	if err != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[k]
+	return ok
+}
+
+// Prefetch decodes the images at paths in the background, in order, unless they are
+// cached already. It cancels the images of earlier calls that aren't started yet,
+// e.g. when the user jumps to another part of the folder.
+func (c *Cache) Prefetch(paths []string) {
+	c.mu.Lock()
+	c.generation++
+	generation := c.generation
+	c.mu.Unlock()
+
+	queue := make(chan string, len(paths))
+	for _, path := range paths {
+		queue <- path
+	}
+	close(queue)
+	for i := 0; i < c.workers; i++ {
+		go func() {
+			for path := range queue {
+				if !c.current(generation) {
+					return
+				}
+				k, err := fileKey(path)
+				if err != nil {
+					continue
+				}
+				// a failed decode isn't cached, Get tries again and returns the error
+				c.get(k)
+			}
+		}()
+	}
+}
+
+// Len returns the number of cached images and their size in bytes
+func (c *Cache) Len() (int, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len(), c.used
+}
+
+func (c *Cache) current(generation int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return generation == c.generation
+}
+
+func (c *Cache) get(k key) (image.Image, error) {
+	c.mu.Lock()
+	if el, ok := c.entries[k]; ok {
+		c.lru.MoveToFront(el)
+		c.mu.Unlock()
+		return el.Value.(*entry).image, nil
+	}
+	if cl, ok := c.inflight[k]; ok {
+		c.mu.Unlock()
+		<-cl.done
+		return cl.image, cl.err
+	}
+	cl := &call{done: make(chan struct{})}
+	c.inflight[k] = cl
+	c.mu.Unlock()
+
+	cl.image, cl.err = c.Decode(k.path)
+
+	c.mu.Lock()
+	delete(c.inflight, k)
+	if cl.err == nil {
+		c.add(k, cl.image)
+	}
+	c.mu.Unlock()
+	close(cl.done)
+	return cl.image, cl.err
+}
+
+// add stores img and drops the least recently used images until the cache fits again.
+// The new image is kept even if it is larger than the whole cache.
+func (c *Cache) add(k key, img image.Image) {
+	// older versions of the file won't be asked for again
+	for other, el := range c.entries {
+		if other.path == k.path {
+			c.remove(el)
+		}
+	}
+	e := &entry{key: k, image: img, bytes: imageBytes(img)}
+	c.entries[k] = c.lru.PushFront(e)
+	c.used += e.bytes
+	for c.used > c.maxBytes && c.lru.Len() > 1 {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	e := el.Value.(*entry)
+	c.lru.Remove(el)
+	delete(c.entries, e.key)
+	c.used -= e.bytes
+}
+
+func fileKey(path string) (key, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return key{}, err
+	}
+	return key{path: path, modified: info.ModTime().UnixNano(), size: info.Size()}, nil
+}
+
+// imageBytes estimates the memory used by the pixels of img
+func imageBytes(img image.Image) int64 {
+	switch img := img.(type) {
+	case *image.YCbCr:
+		return int64(len(img.Y) + len(img.Cb) + len(img.Cr))
+	case *image.RGBA:
+		return int64(len(img.Pix))
+	case *image.NRGBA:
+		return int64(len(img.Pix))
+	case *image.Paletted:
+		return int64(len(img.Pix))
+	case *image.Gray:
+		return int64(len(img.Pix))
+	}
+	b := img.Bounds()
+	return int64(b.Dx()) * int64(b.Dy()) * 4
+}
+
+// DecodeFile decodes the image at path turned upright by its EXIF orientation
+func DecodeFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := imageorient.Decode(f)
+	return img, err
+}
@@ -0,0 +1,125 @@
+package imgcache
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCache returns a cache that "decodes" files into 10x10 RGBA images (400 bytes) and counts the decodes
+func testCache(maxBytes int64) (*Cache, func(path string) int) {
+	c := New(maxBytes, 2)
+	var mu sync.Mutex
+	decoded := map[string]int{}
+	c.Decode = func(path string) (image.Image, error) {
+		mu.Lock()
+		decoded[path]++
+		mu.Unlock()
+		return image.NewRGBA(image.Rect(0, 0, 10, 10)), nil
+	}
+	return c, func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return decoded[path]
+	}
+}
+
+func makeFiles(t *testing.T, names ...string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	paths := []string{}
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestGetCachesAndEvicts(t *testing.T) {
+	c, decodes := testCache(1000)
+	paths := makeFiles(t, "a.jpg", "b.jpg", "c.jpg")
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get(paths[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := decodes(paths[0]); n != 1 {
+		t.Errorf("a.jpg decoded %d times", n)
+	}
+
+	// two images fit, the least recently used one goes
+	c.Get(paths[1])
+	c.Get(paths[0])
+	c.Get(paths[2])
+	if n, used := c.Len(); n != 2 || used != 800 {
+		t.Errorf("Len() = %d, %d", n, used)
+	}
+	if !c.Cached(paths[0]) || c.Cached(paths[1]) || !c.Cached(paths[2]) {
+		t.Error("the wrong image was evicted")
+	}
+
+	// a changed file is decoded again
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(paths[0], later, later); err != nil {
+		t.Fatal(err)
+	}
+	if c.Cached(paths[0]) {
+		t.Error("changed file counts as cached")
+	}
+	c.Get(paths[0])
+	if n := decodes(paths[0]); n != 2 {
+		t.Errorf("changed a.jpg decoded %d times", n)
+	}
+	if n, _ := c.Len(); n != 2 {
+		t.Errorf("the old version of a.jpg is still cached, %d images", n)
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	c, decodes := testCache(1 << 20)
+	paths := makeFiles(t, "a.jpg", "b.jpg", "c.jpg")
+	c.Prefetch(paths[1:])
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !(c.Cached(paths[1]) && c.Cached(paths[2])) {
+		if time.Now().After(deadline) {
+			t.Fatal("images weren't prefetched")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Get(paths[1])
+	if n := decodes(paths[1]); n != 1 {
+		t.Errorf("prefetched image decoded %d times", n)
+	}
+	if c.Cached(paths[0]) {
+		t.Error("image that wasn't asked for is cached")
+	}
+}
+
+func TestPrefetchCancel(t *testing.T) {
+	c := New(1<<20, 1)
+	release := make(chan struct{})
+	c.Decode = func(path string) (image.Image, error) {
+		<-release
+		return image.NewRGBA(image.Rect(0, 0, 1, 1)), nil
+	}
+	paths := makeFiles(t, "a.jpg", "b.jpg", "c.jpg")
+	c.Prefetch(paths)
+	// wait until a.jpg is being decoded, then jump elsewhere
+	time.Sleep(50 * time.Millisecond)
+	c.Prefetch(nil)
+	close(release)
+
+	time.Sleep(100 * time.Millisecond)
+	if c.Cached(paths[1]) || c.Cached(paths[2]) {
+		t.Error("canceled images were prefetched")
+	}
+}
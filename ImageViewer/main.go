@@ -8,7 +8,6 @@ import (
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
-	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/data/binding"
 	"fyne.io/fyne/v2/driver/desktop"
@@ -16,11 +15,13 @@ import (
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
     "github.com/spf13/viper"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/imgcache"
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+	"github.com/jjwinters/image-tagger/ImageViewer/thumbs"
 )
 
 const (
-    // tagBtnTotal must match size of DefaultButtonTags()
-    tagBtnTotal = 30
     viperFilename = "config"
 )
 
@@ -133,12 +134,14 @@ type App struct {
     config  *viper.Viper
 
 	img        Img
+	session    *tagger.Session
+	journal    *tagger.Journal
 	mainModKey desktop.Modifier
 	focus      bool
 	lastOpened []string
     file       *os.File
 
-	image *canvas.Image
+	image *zoomView
 
 	sliderBrightness    *editingSlider
 	sliderContrast      *editingSlider
@@ -158,10 +161,21 @@ type App struct {
 	imgLastMod  *widget.Label
 	tagBtnLabel *widget.Label
     tagBtns     []*widget.Button
-    tagBtnEntries   []*widget.Entry
-    tagBtnGrid  *fyne.Container
+    tagBtnArea  *fyne.Container
+    tagEditor   *fyne.Container
+    tagEditRows []tagEditRow
     saveTagsBtn *widget.Button
     editTagsBtn *widget.Button
+    buttonTags      []string
+    buttonHotkeys   []string
+    buttons         []tagger.Tag
+    profiles        []tagger.Profile
+    profile         int
+    profileSelector *widget.Select
+    checklistBox    *fyne.Container
+    tagHotkeys      map[tagHotkey]int
+    tagShortcuts    []fyne.Shortcut
+    shiftDown       bool
 
 	bottomBar       *fyne.Container
 	bottomBarSplit  *container.Split
@@ -177,8 +191,32 @@ type App struct {
 	zoomOut      *widget.Button
 	zoomLabel    *widget.Label
 	resetZoomBtn *widget.Button
+	fillZoomBtn   *widget.Button
+	actualZoomBtn *widget.Button
 
 	fullscreenWin fyne.Window
+	historyList   *widget.List
+
+	folderTree *widget.Tree
+	folders    []tagger.Folder
+	watcher    *tagger.Watcher
+	// shownSession and shownVersion are what the checklist and the folder tree show
+	shownSession *tagger.Session
+	shownVersion int
+
+	filmstrip      *container.Scroll
+	filmstripBox   *fyne.Container
+	filmstripNames []string
+	thumbnails     []*thumbnail
+	thumbPool      *thumbs.Pool
+	thumbCache     *thumbs.Cache
+
+	gridWin *gridView
+
+	imageCache *imgcache.Cache
+
+	// commandSessions are the sessions a subcommand opened, see reportErrors
+	commandSessions []*tagger.Session
 }
 
 func reverseArray(arr []string) []string {
@@ -192,6 +230,7 @@ func reverseArray(arr []string) []string {
 func (a *App) init() {
 	a.img = Img{}
 	a.img.init()
+	a.imageCache = imgcache.New(int64(a.config.GetInt("imagecachemb"))<<20, 2)
 
 	// theme
 	switch a.app.Preferences().StringWithFallback("Theme", "Dark") {
@@ -209,19 +248,61 @@ func (a *App) init() {
 
 func (a *App) WriteConfig() {
     if err := os.MkdirAll(viperPath(), os.ModePerm); err != nil {
-        fmt.Errorf("Error creating config file directory: %w. Default configs will be used.\n", err)
+        fmt.Fprintf(os.Stderr, "Error creating config file directory: %v. Default configs will be used.\n", err)
     }
     if err := a.config.WriteConfigAs(filepath.Join(viperPath(), viperFilename)); err != nil {
-        fmt.Errorf("Error writing config file: %w. Updates to configs will not be saved.\n", err)
+        fmt.Fprintf(os.Stderr, "Error writing config file: %v. Updates to configs will not be saved.\n", err)
     }
 }
 
+// reportErrors writes the problems collected while working, like unreadable sidecars
+// or a journal that couldn't be saved, to stderr. The work went on without them.
+func (a *App) reportErrors() {
+	errs := []error{}
+	for _, session := range append([]*tagger.Session{a.session}, a.commandSessions...) {
+		if session != nil {
+			errs = append(errs, session.Errors()...)
+		}
+	}
+	if a.journal != nil {
+		errs = append(errs, a.journal.Errors()...)
+	}
+	if a.watcher != nil {
+		errs = append(errs, a.watcher.Errors()...)
+	}
+	if a.thumbCache != nil {
+		errs = append(errs, a.thumbCache.Errors()...)
+	}
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+	}
+}
+
 func main() {
     viperConfig := viper.New()
 
     viperConfig.SetDefault("auto-generated-file", "This file managed by Image Tagger. Do not modify!")
     viperConfig.SetDefault("ImagePath", os.Getenv("HOME"))
     viperConfig.SetDefault("ButtonTags", DefaultButtonTags() )
+    viperConfig.SetDefault("ButtonHotkeys", DefaultButtonHotkeys() )
+    viperConfig.SetDefault("RenameCollision", string(tagger.CollisionSequence))
+    viperConfig.SetDefault("DeleteMode", deleteModeTrash)
+    viperConfig.SetDefault("TrashMaxAgeDays", 30)
+    viperConfig.SetDefault("TagStorage", string(tagger.StorageRename))
+    viperConfig.SetDefault("EmbedTags", false)
+    viperConfig.SetDefault("NameTemplate", tagger.DefaultTemplate)
+    viperConfig.SetDefault("JobVariables", []string{})
+    viperConfig.SetDefault("SortOrder", string(tagger.SortNatural))
+    viperConfig.SetDefault("PrefetchImages", 2)
+    viperConfig.SetDefault("ImageCacheMB", 512)
+    viperConfig.SetDefault("ThumbnailCacheMB", 256)
+    viperConfig.SetDefault("ReportPerPage", 4)
+    viperConfig.SetDefault("ReportPageSize", "Letter")
+    viperConfig.SetDefault("GallerySize", 2048)
+    viperConfig.SetDefault("GalleryQuality", 85)
+    viperConfig.SetDefault("PackageResize", false)
+    viperConfig.SetDefault("PackageSize", 2048)
+    viperConfig.SetDefault("PackageQuality", 85)
 
     viperConfig.SetConfigName(viperFilename)       // name of config file (without extension)
     viperConfig.SetConfigType("yaml")
@@ -230,13 +311,25 @@ func main() {
     if err := viperConfig.ReadInConfig(); err != nil {
         if _, ok := err.(viper.ConfigFileNotFoundError); ok {
             // Config file not found; use defaults.
-            fmt.Printf("No config file found; defaults will be used.\n")
+            fmt.Fprintf(os.Stderr, "No config file found; defaults will be used.\n")
         } else {
             // Config file was found but another error was produced
-            fmt.Errorf("Error reading config: %w. Default configs will be used.\n", err)
+            fmt.Fprintf(os.Stderr, "Error reading config: %v. Default configs will be used.\n", err)
         }
     }
 
+	// subcommands work on folders without opening a window
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "help", "-h", "--help":
+			printUsage(os.Stdout)
+			return
+		}
+		if c, ok := findCommand(os.Args[1]); ok {
+			os.Exit((&App{config: viperConfig}).runCommand(c, os.Args[2:]))
+		}
+	}
+
 	a := app.NewWithID("io.github.jjwinters.image-tagger")
 	w := a.NewWindow("Image Tagger")
 	a.SetIcon(resourceIconPng)
@@ -244,11 +337,12 @@ func main() {
 	ui := &App{app: a, mainWin: w, config: viperConfig}
 	ui.init()
     ui.WriteConfig()
+	ui.openJournal()
 	w.SetContent(ui.loadMainUI())
 	if len(os.Args) > 1 {
 		file, err := os.Open(os.Args[1])
 		if err != nil {
-			fmt.Printf("error while opening the file: %v\n", err)
+			fmt.Fprintf(os.Stderr, "error while opening the file: %v\n", err)
 		}
 		ui.open(file, true)
 	}
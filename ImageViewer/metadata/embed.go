@@ -0,0 +1,254 @@
+package metadata
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// ErrUnsupportedFormat is returned for images other than JPEG and PNG
+var ErrUnsupportedFormat = errors.New("only JPEG and PNG images can hold keywords")
+
+// pngXMPKeyword is the keyword of the iTXt chunk holding the XMP packet
+const pngXMPKeyword = "XML:com.adobe.xmp"
+
+// xpacketPadding is the whitespace left in new XMP packets so other tools can edit them in place
+const xpacketPadding = 2048
+
+// ReadKeywords returns the keywords embedded in the JPEG or PNG image at path.
+// The XMP dc:subject wins over the IPTC keywords of a JPEG.
+func ReadKeywords(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case isJPEG(data):
+		segments, _, err := splitJPEG(data)
+		if err != nil {
+			return nil, err
+		}
+		var iptc []string
+		for _, seg := range segments {
+			switch {
+			case seg.marker == markerAPP1 && bytes.HasPrefix(seg.data, xmpHeader):
+				return ParseXMP(seg.data[len(xmpHeader):])
+			case seg.marker == markerAPP13 && bytes.HasPrefix(seg.data, photoshopHeader):
+				if iptc, err = readIPTCKeywords(seg.data[len(photoshopHeader):]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return iptc, nil
+	case isPNG(data):
+		chunks, err := readPNGChunks(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range chunks {
+			if c.typ != "iTXt" {
+				continue
+			}
+			keyword, packet, err := parseITXt(c.data)
+			if err == nil && keyword == pngXMPKeyword {
+				return ParseXMP(packet)
+			}
+		}
+		return nil, nil
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+// WriteKeywords embeds keywords into the JPEG or PNG image at path as XMP dc:subject,
+// and for JPEG also as IPTC keywords. Only the metadata is rewritten, the pixel data
+// and other metadata like EXIF are copied as they are.
+func WriteKeywords(path string, keywords []string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch {
+	case isJPEG(data):
+		data, err = EmbedJPEG(data, keywords)
+	case isPNG(data):
+		data, err = EmbedPNG(data, keywords)
+	default:
+		return ErrUnsupportedFormat
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	// write to a temporary file first so a crash can't leave a truncated image
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// EmbedJPEG returns the JPEG file data with keywords set in the XMP and IPTC segments.
+// New segments are inserted after the JFIF and EXIF segments. Without keywords no
+// segments are added, and an XMP segment that only held keywords is removed.
+func EmbedJPEG(data []byte, keywords []string) ([]byte, error) {
+	segments, scan, err := splitJPEG(data)
+	if err != nil {
+		return nil, err
+	}
+	xmpDone, iptcDone := false, false
+	for i := 0; i < len(segments); i++ {
+		seg := segments[i]
+		switch {
+		case seg.marker == markerAPP1 && bytes.HasPrefix(seg.data, xmpHeader) && !xmpDone:
+			packet, err := SetXMPSubject(seg.data[len(xmpHeader):], keywords)
+			if err != nil {
+				return nil, err
+			}
+			xmpDone = true
+			if len(keywords) == 0 && subjectOnly(packet) {
+				segments = append(segments[:i], segments[i+1:]...)
+				i--
+				continue
+			}
+			segments[i].data = concat(xmpHeader, packet)
+		case seg.marker == markerAPP13 && bytes.HasPrefix(seg.data, photoshopHeader) && !iptcDone:
+			resources, err := writeIPTCKeywords(seg.data[len(photoshopHeader):], keywords)
+			if err != nil {
+				return nil, err
+			}
+			segments[i].data = concat(photoshopHeader, resources)
+			iptcDone = true
+		}
+	}
+
+	insert := []jpegSegment{}
+	if !xmpDone && len(keywords) > 0 {
+		insert = append(insert, jpegSegment{marker: markerAPP1, data: concat(xmpHeader, wrapXMP(BuildXMP(keywords)))})
+	}
+	if !iptcDone && len(keywords) > 0 {
+		resources, err := writeIPTCKeywords(nil, keywords)
+		if err != nil {
+			return nil, err
+		}
+		insert = append(insert, jpegSegment{marker: markerAPP13, data: concat(photoshopHeader, resources)})
+	}
+	at := 0
+	for at < len(segments) && (segments[at].marker == markerAPP0 || segments[at].marker == markerAPP1) {
+		at++
+	}
+	segments = append(segments[:at], append(insert, segments[at:]...)...)
+	return joinJPEG(segments, scan)
+}
+
+// EmbedPNG returns the PNG file data with keywords set in the XMP iTXt chunk.
+// A new chunk is inserted before the image data. Without keywords no chunk is
+// added, and a chunk that only held keywords is removed.
+func EmbedPNG(data []byte, keywords []string) ([]byte, error) {
+	chunks, err := readPNGChunks(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	for i, c := range chunks {
+		if c.typ != "iTXt" {
+			continue
+		}
+		keyword, packet, err := parseITXt(c.data)
+		if err != nil || keyword != pngXMPKeyword {
+			continue
+		}
+		if packet, err = SetXMPSubject(packet, keywords); err != nil {
+			return nil, err
+		}
+		if len(keywords) == 0 && subjectOnly(packet) {
+			return writePNGChunks(append(chunks[:i], chunks[i+1:]...)), nil
+		}
+		chunks[i].data = buildITXt(pngXMPKeyword, packet)
+		return writePNGChunks(chunks), nil
+	}
+	if len(keywords) == 0 {
+		return data, nil
+	}
+
+	at := len(chunks) - 1 // before IEND if there is no IDAT
+	for i, c := range chunks {
+		if c.typ == "IDAT" {
+			at = i
+			break
+		}
+	}
+	chunk := pngChunk{typ: "iTXt", data: buildITXt(pngXMPKeyword, wrapXMP(BuildXMP(keywords)))}
+	chunks = append(chunks[:at], append([]pngChunk{chunk}, chunks[at:]...)...)
+	return writePNGChunks(chunks), nil
+}
+
+// subjectOnly reports whether packet holds nothing but dc:subject, like the
+// packets this package writes
+func subjectOnly(packet []byte) bool {
+	return bytes.Equal(xmpSkeleton(packet), xmpSkeleton(BuildXMP(nil)))
+}
+
+// xmpSkeleton returns packet without xpacket instructions, dc:subject and white space
+func xmpSkeleton(packet []byte) []byte {
+	packet = xpacketInstruction.ReplaceAll(packet, nil)
+	packet = subjectElement.ReplaceAll(packet, nil)
+	return bytes.Join(bytes.Fields(packet), nil)
+}
+
+// wrapXMP wraps an XMP packet into xpacket processing instructions with padding
+func wrapXMP(packet []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.Write(packet)
+	for i := 0; i < xpacketPadding/100; i++ {
+		b.WriteString(strings.Repeat(" ", 99) + "\n")
+	}
+	b.WriteString(`<?xpacket end="w"?>`)
+	return b.Bytes()
+}
+
+// parseITXt returns the keyword and the text of an iTXt chunk
+func parseITXt(data []byte) (string, []byte, error) {
+	parts := bytes.SplitN(data, []byte{0}, 2)
+	if len(parts) != 2 || len(parts[1]) < 2 {
+		return "", nil, fmt.Errorf("invalid iTXt chunk")
+	}
+	keyword, compressed, rest := string(parts[0]), parts[1][0] == 1, parts[1][2:]
+	// skip language tag and translated keyword
+	for i := 0; i < 2; i++ {
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return "", nil, fmt.Errorf("invalid iTXt chunk")
+		}
+		rest = rest[end+1:]
+	}
+	if !compressed {
+		return keyword, rest, nil
+	}
+	r, err := zlib.NewReader(bytes.NewReader(rest))
+	if err != nil {
+		return "", nil, err
+	}
+	defer r.Close()
+	text, err := ioutil.ReadAll(r)
+	return keyword, text, err
+}
+
+// buildITXt returns an uncompressed iTXt chunk without language tag
+func buildITXt(keyword string, text []byte) []byte {
+	return concat([]byte(keyword), []byte{0, 0, 0, 0, 0}, text)
+}
+
+func isJPEG(data []byte) bool {
+	return len(data) > 2 && data[0] == 0xFF && data[1] == markerSOI
+}
+
+func isPNG(data []byte) bool {
+	return bytes.HasPrefix(data, pngSignature)
+}
@@ -0,0 +1,291 @@
+package metadata
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// samplePNG encodes a small PNG and inserts an eXIf chunk before the image data
+func samplePNG(t *testing.T, tiff []byte) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 2, color.RGBA{R: 200, A: 255})
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := readPNGChunks(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks = append(chunks[:1], append([]pngChunk{{typ: "eXIf", data: tiff}}, chunks[1:]...)...)
+	return writePNGChunks(chunks)
+}
+
+// writeSample writes data to a file in a new temporary directory
+func writeSample(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// checkUnchanged compares the pixels and the EXIF date of the file at path with the original
+func checkUnchanged(t *testing.T, path string, original []byte) {
+	t.Helper()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decoding the rewritten image: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("pixel data changed")
+	}
+	e, err := ReadEXIF(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("EXIF lost: %v", err)
+	}
+	if want := time.Date(2023, 5, 17, 8, 30, 0, 0, time.Local); !e.Date().Equal(want) {
+		t.Errorf("EXIF date = %v, want %v", e.Date(), want)
+	}
+}
+
+func TestKeywordsRoundTrip(t *testing.T) {
+	tiff := exifTIFF("2023:06:01 10:00:00", "2023:05:17 08:30:00")
+	samples := map[string][]byte{
+		"photo.jpg": jpegWithEXIF(t, tiff),
+		"photo.png": samplePNG(t, tiff),
+	}
+	for name, original := range samples {
+		path := writeSample(t, name, original)
+		if got, err := ReadKeywords(path); err != nil || len(got) != 0 {
+			t.Errorf("%s: ReadKeywords() before writing = %v, %v", name, got, err)
+		}
+
+		for _, keywords := range [][]string{{"FURNACE", "TANK DATA", "Küche"}, {"AC"}, {}} {
+			if err := WriteKeywords(path, keywords); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			got, err := ReadKeywords(path)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if len(got) != 0 || len(keywords) != 0 {
+				if !reflect.DeepEqual(got, keywords) {
+					t.Errorf("%s: ReadKeywords() = %v, want %v", name, got, keywords)
+				}
+			}
+			checkUnchanged(t, path, original)
+		}
+	}
+}
+
+func TestEmbedJPEGSegments(t *testing.T) {
+	original := jpegWithEXIF(t, exifTIFF("2023:06:01 10:00:00", "2023:05:17 08:30:00"))
+	data, err := EmbedJPEG(original, []string{"FURNACE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// writing again replaces the segments instead of adding more
+	if data, err = EmbedJPEG(data, []string{"AC", "TANK"}); err != nil {
+		t.Fatal(err)
+	}
+	segments, _, err := splitJPEG(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xmp, iptc := 0, 0
+	for _, seg := range segments {
+		if seg.marker == markerAPP1 && bytes.HasPrefix(seg.data, xmpHeader) {
+			xmp++
+		}
+		if seg.marker == markerAPP13 && bytes.HasPrefix(seg.data, photoshopHeader) {
+			iptc++
+			keywords, err := readIPTCKeywords(seg.data[len(photoshopHeader):])
+			if err != nil || !reflect.DeepEqual(keywords, []string{"AC", "TANK"}) {
+				t.Errorf("IPTC keywords = %v, %v", keywords, err)
+			}
+		}
+	}
+	if xmp != 1 || iptc != 1 {
+		t.Errorf("got %d XMP and %d IPTC segments, want one each", xmp, iptc)
+	}
+	// EXIF stays the first segment after JFIF
+	if !bytes.HasPrefix(segments[0].data, exifHeader) && !bytes.HasPrefix(segments[1].data, exifHeader) {
+		t.Error("EXIF segment moved")
+	}
+}
+
+func TestEmbedWithoutKeywords(t *testing.T) {
+	tiff := exifTIFF("2023:06:01 10:00:00", "2023:05:17 08:30:00")
+	xmpSegments := func(data []byte) int {
+		t.Helper()
+		segments, _, err := splitJPEG(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, seg := range segments {
+			if seg.marker == markerAPP1 && bytes.HasPrefix(seg.data, xmpHeader) {
+				n++
+			}
+		}
+		return n
+	}
+	xmpChunks := func(data []byte) int {
+		t.Helper()
+		chunks, err := readPNGChunks(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, c := range chunks {
+			if keyword, _, err := parseITXt(c.data); c.typ == "iTXt" && err == nil && keyword == pngXMPKeyword {
+				n++
+			}
+		}
+		return n
+	}
+
+	original := jpegWithEXIF(t, tiff)
+	if data, err := EmbedJPEG(original, nil); err != nil || xmpSegments(data) != 0 {
+		t.Errorf("JPEG without keywords got an XMP segment, %v", err)
+	}
+	data, err := EmbedJPEG(original, []string{"AC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = EmbedJPEG(data, nil); err != nil || xmpSegments(data) != 0 {
+		t.Errorf("untagged JPEG kept its XMP segment, %v", err)
+	}
+
+	original = samplePNG(t, tiff)
+	if data, err := EmbedPNG(original, nil); err != nil || xmpChunks(data) != 0 {
+		t.Errorf("PNG without keywords got an XMP chunk, %v", err)
+	}
+	if data, err = EmbedPNG(original, []string{"AC"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = EmbedPNG(data, nil); err != nil || xmpChunks(data) != 0 {
+		t.Errorf("untagged PNG kept its XMP chunk, %v", err)
+	}
+
+	// a packet of another program keeps its other properties
+	packet := bytes.Replace(wrapXMP(BuildXMP([]string{"AC"})), []byte(`rdf:about=""`),
+		[]byte(`rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmp:Rating="3"`), 1)
+	segments, scan, err := splitJPEG(jpegWithEXIF(t, tiff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments = append(segments, jpegSegment{marker: markerAPP1, data: concat(xmpHeader, packet)})
+	if data, err = joinJPEG(segments, scan); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = EmbedJPEG(data, nil); err != nil || xmpSegments(data) != 1 || !bytes.Contains(data, []byte(`xmp:Rating="3"`)) {
+		t.Errorf("XMP of another program was removed, %v", err)
+	}
+}
+
+func TestIPTCKeepsOtherDatasets(t *testing.T) {
+	caption := iptcDataset{record: iptcRecordApplication, dataset: 120, data: []byte("Basement")}
+	old := buildPhotoshop([]psResource{
+		{id: 0x03ED, name: []byte{0, 0}, data: []byte{1, 2, 3}},
+		{id: resourceIPTC, name: []byte{0, 0}, data: buildIPTC([]iptcDataset{
+			caption,
+			{record: iptcRecordApplication, dataset: iptcKeywords, data: []byte("OLD")},
+		})},
+		{id: resourceIPTCDigest, name: []byte{0, 0}, data: make([]byte, 16)},
+	})
+	out, err := writeIPTCKeywords(old, []string{"AC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources, err := parsePhotoshop(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 2 || resources[0].id != 0x03ED || !bytes.Equal(resources[0].data, []byte{1, 2, 3}) {
+		t.Fatalf("resources = %v, want the other resource kept and the digest dropped", resources)
+	}
+	datasets, err := parseIPTC(resources[1].data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(iptcKeywordList(datasets), []string{"AC"}) {
+		t.Errorf("keywords = %v, want [AC]", iptcKeywordList(datasets))
+	}
+	found := false
+	for _, d := range datasets {
+		found = found || reflect.DeepEqual(d, caption)
+	}
+	if !found {
+		t.Error("caption dataset lost")
+	}
+}
+
+func TestIPTCCharset(t *testing.T) {
+	latin1 := []byte("\x1b.A")
+	charset := func(data []byte) iptcDataset {
+		return iptcDataset{record: iptcRecordEnvelope, dataset: iptcCharset, data: data}
+	}
+	caption := func(text string) iptcDataset {
+		return iptcDataset{record: iptcRecordApplication, dataset: 120, data: []byte(text)}
+	}
+	keyword := func(k string) iptcDataset {
+		return iptcDataset{record: iptcRecordApplication, dataset: iptcKeywords, data: []byte(k)}
+	}
+	tests := []struct {
+		name     string
+		datasets []iptcDataset
+		want     []iptcDataset
+	}{
+		{"new record", nil, []iptcDataset{charset(iptcUTF8), keyword("AC"), keyword("GARÇON")}},
+		{"ASCII record", []iptcDataset{caption("Basement")},
+			[]iptcDataset{charset(iptcUTF8), caption("Basement"), keyword("AC"), keyword("GARÇON")}},
+		{"UTF-8 record", []iptcDataset{charset(iptcUTF8), caption("Küche")},
+			[]iptcDataset{charset(iptcUTF8), caption("Küche"), keyword("AC"), keyword("GARÇON")}},
+		{"Latin-1 record", []iptcDataset{charset(latin1), caption("K\xfcche")},
+			[]iptcDataset{charset(latin1), caption("K\xfcche"), keyword("AC")}},
+		{"undeclared record", []iptcDataset{caption("K\xfcche")},
+			[]iptcDataset{caption("K\xfcche"), keyword("AC")}},
+	}
+	for _, test := range tests {
+		got := setIPTCKeywords(test.datasets, []string{"AC", "GARÇON"})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: setIPTCKeywords() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPNGChunkLength(t *testing.T) {
+	data := samplePNG(t, nil)
+	// the first chunk claims 2 GB
+	broken := append([]byte{}, data...)
+	copy(broken[len(pngSignature):], []byte{0x7f, 0xff, 0xff, 0xff})
+	if _, err := readPNGChunks(bytes.NewReader(broken)); err == nil {
+		t.Error("readPNGChunks() accepted a chunk longer than the file")
+	}
+}
+
+func TestWriteKeywordsUnsupported(t *testing.T) {
+	path := writeSample(t, "anim.gif", []byte("GIF89a"))
+	if err := WriteKeywords(path, []string{"AC"}); err == nil {
+		t.Error("WriteKeywords() on a GIF succeeded")
+	}
+}
@@ -0,0 +1,283 @@
+// Package metadata reads and writes image metadata (EXIF, XMP, IPTC) without
+// decoding or re-encoding the pixel data.
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNoEXIF is returned by ReadEXIF when the image has no EXIF data
+var ErrNoEXIF = errors.New("no EXIF data")
+
+// EXIF tags read by this package
+const (
+	tagOrientation      = 0x0112
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+)
+
+// GPS tags, in the GPS IFD
+const (
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+)
+
+// exifTimeLayout is the format of EXIF date fields
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// EXIF holds the fields of the EXIF data used by the tagger
+type EXIF struct {
+	// DateTimeOriginal is the capture time, zero if unknown
+	DateTimeOriginal time.Time
+	// DateTime is the time the file was last changed by the camera or an editor, zero if unknown
+	DateTime time.Time
+	// Orientation is the EXIF orientation 1 to 8, 0 if unknown. 5 to 8 turn the image by 90 degrees.
+	Orientation int
+	// HasGPS is set if the image has a GPS position
+	HasGPS bool
+	// Latitude and Longitude are the GPS position in decimal degrees, negative for south and west
+	Latitude  float64
+	Longitude float64
+}
+
+// Rotated reports whether the orientation turns the image by 90 degrees, swapping width and height
+func (e *EXIF) Rotated() bool {
+	return e.Orientation >= 5 && e.Orientation <= 8
+}
+
+// Date returns the capture time, or DateTime if the capture time is unknown
+func (e *EXIF) Date() time.Time {
+	if !e.DateTimeOriginal.IsZero() {
+		return e.DateTimeOriginal
+	}
+	return e.DateTime
+}
+
+// ReadEXIFFile reads the EXIF data of the JPEG or PNG file at path
+func ReadEXIFFile(path string) (*EXIF, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadEXIF(bufio.NewReader(f))
+}
+
+// ReadEXIF reads the EXIF data of a JPEG or PNG image
+func ReadEXIF(r io.Reader) (*EXIF, error) {
+	payload, err := findEXIF(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseEXIF(payload)
+}
+
+// findEXIF returns the raw TIFF structure of the EXIF data
+func findEXIF(r io.Reader) ([]byte, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(8)
+	if err != nil {
+		return nil, ErrNoEXIF
+	}
+	switch {
+	case magic[0] == 0xFF && magic[1] == 0xD8:
+		segments, err := readJPEGSegments(br)
+		if err != nil {
+			return nil, err
+		}
+		for _, seg := range segments {
+			if seg.marker == markerAPP1 && bytes.HasPrefix(seg.data, exifHeader) {
+				return seg.data[len(exifHeader):], nil
+			}
+		}
+	case bytes.Equal(magic, pngSignature):
+		chunks, err := readPNGChunks(br)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range chunks {
+			if c.typ == "eXIf" {
+				return c.data, nil
+			}
+		}
+	}
+	return nil, ErrNoEXIF
+}
+
+// tiffReader reads IFD entries from a TIFF structure
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// ifdEntry is one field of an image file directory
+type ifdEntry struct {
+	tag    uint16
+	typ    uint16
+	count  uint32
+	offset uint32 // value itself if it fits into 4 bytes
+	raw    []byte // the 4 value/offset bytes
+}
+
+func parseEXIF(data []byte) (*EXIF, error) {
+	t, ifd0, err := newTIFFReader(data)
+	if err != nil {
+		return nil, err
+	}
+	e := &EXIF{}
+	entries, err := t.readIFD(ifd0)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		switch entry.tag {
+		case tagDateTime:
+			e.DateTime = t.time(entry)
+		case tagOrientation:
+			if v := t.value(entry, 2); len(v) == 2 {
+				e.Orientation = int(t.order.Uint16(v))
+			}
+		case tagGPSIFD:
+			if gps, err := t.readIFD(entry.offset); err == nil {
+				e.parseGPS(t, gps)
+			}
+		case tagExifIFD:
+			sub, err := t.readIFD(entry.offset)
+			if err != nil {
+				continue
+			}
+			for _, s := range sub {
+				if s.tag == tagDateTimeOriginal {
+					e.DateTimeOriginal = t.time(s)
+				}
+			}
+		}
+	}
+	return e, nil
+}
+
+// parseGPS reads the position from the entries of the GPS IFD
+func (e *EXIF) parseGPS(t *tiffReader, entries []ifdEntry) {
+	var lat, lon []float64
+	latRef, lonRef := "N", "E"
+	for _, entry := range entries {
+		switch entry.tag {
+		case tagGPSLatitudeRef:
+			latRef = t.string(entry)
+		case tagGPSLatitude:
+			lat = t.rationals(entry)
+		case tagGPSLongitudeRef:
+			lonRef = t.string(entry)
+		case tagGPSLongitude:
+			lon = t.rationals(entry)
+		}
+	}
+	if len(lat) != 3 || len(lon) != 3 {
+		return
+	}
+	e.HasGPS = true
+	e.Latitude = lat[0] + lat[1]/60 + lat[2]/3600
+	e.Longitude = lon[0] + lon[1]/60 + lon[2]/3600
+	if latRef == "S" {
+		e.Latitude = -e.Latitude
+	}
+	if lonRef == "W" {
+		e.Longitude = -e.Longitude
+	}
+}
+
+func newTIFFReader(data []byte) (*tiffReader, uint32, error) {
+	if len(data) < 8 {
+		return nil, 0, fmt.Errorf("EXIF data too short")
+	}
+	t := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, 0, fmt.Errorf("invalid TIFF byte order %q", data[:2])
+	}
+	if t.order.Uint16(data[2:4]) != 42 {
+		return nil, 0, fmt.Errorf("invalid TIFF header")
+	}
+	return t, t.order.Uint32(data[4:8]), nil
+}
+
+func (t *tiffReader) readIFD(offset uint32) ([]ifdEntry, error) {
+	if int(offset)+2 > len(t.data) {
+		return nil, fmt.Errorf("IFD offset %d out of range", offset)
+	}
+	n := int(t.order.Uint16(t.data[offset:]))
+	start := int(offset) + 2
+	if start+n*12 > len(t.data) {
+		return nil, fmt.Errorf("IFD at %d truncated", offset)
+	}
+	entries := make([]ifdEntry, n)
+	for i := range entries {
+		b := t.data[start+i*12:]
+		entries[i] = ifdEntry{
+			tag:    t.order.Uint16(b[0:2]),
+			typ:    t.order.Uint16(b[2:4]),
+			count:  t.order.Uint32(b[4:8]),
+			offset: t.order.Uint32(b[8:12]),
+			raw:    b[8:12],
+		}
+	}
+	return entries, nil
+}
+
+// value returns the bytes of an entry's value, size is the byte size of one element
+func (t *tiffReader) value(e ifdEntry, size int) []byte {
+	n := int(e.count) * size
+	if n <= 4 {
+		return e.raw[:n]
+	}
+	if int(e.offset)+n > len(t.data) {
+		return nil
+	}
+	return t.data[e.offset : int(e.offset)+n]
+}
+
+func (t *tiffReader) string(e ifdEntry) string {
+	return strings.TrimRight(string(t.value(e, 1)), "\x00 ")
+}
+
+// rationals returns the values of an unsigned RATIONAL entry
+func (t *tiffReader) rationals(e ifdEntry) []float64 {
+	const typeRational = 5
+	if e.typ != typeRational {
+		return nil
+	}
+	data := t.value(e, 8)
+	values := []float64{}
+	for i := 0; i+8 <= len(data); i += 8 {
+		num, den := t.order.Uint32(data[i:]), t.order.Uint32(data[i+4:])
+		if den == 0 {
+			return nil
+		}
+		values = append(values, float64(num)/float64(den))
+	}
+	return values
+}
+
+func (t *tiffReader) time(e ifdEntry) time.Time {
+	v, err := time.ParseInLocation(exifTimeLayout, t.string(e), time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return v
+}
@@ -0,0 +1,149 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"math"
+	"testing"
+	"time"
+)
+
+// exifTIFF builds a little endian TIFF structure with DateTime in IFD0 and
+// DateTimeOriginal in the EXIF IFD
+func exifTIFF(dateTime, original string) []byte {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	b.WriteString("II")
+	binary.Write(&b, le, uint16(42))
+	binary.Write(&b, le, uint32(8))
+
+	// IFD0 at 8: 2 entries, next IFD offset, then the DateTime string
+	const ifd0Size = 2 + 2*12 + 4
+	dateOffset := uint32(8 + ifd0Size)
+	exifOffset := dateOffset + 20
+	binary.Write(&b, le, uint16(2))
+	binary.Write(&b, le, []uint16{tagDateTime, 2})
+	binary.Write(&b, le, []uint32{20, dateOffset})
+	binary.Write(&b, le, []uint16{tagExifIFD, 4})
+	binary.Write(&b, le, []uint32{1, exifOffset})
+	binary.Write(&b, le, uint32(0))
+	b.Write(append([]byte(dateTime), 0))
+
+	// EXIF IFD: 1 entry, then the DateTimeOriginal string
+	binary.Write(&b, le, uint16(1))
+	binary.Write(&b, le, []uint16{tagDateTimeOriginal, 2})
+	binary.Write(&b, le, []uint32{20, exifOffset + 2 + 12 + 4})
+	binary.Write(&b, le, uint32(0))
+	b.Write(append([]byte(original), 0))
+	return b.Bytes()
+}
+
+// jpegWithEXIF encodes a small JPEG and inserts an APP1 EXIF segment after SOI
+func jpegWithEXIF(t *testing.T, tiff []byte) []byte {
+	t.Helper()
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+	payload := append(append([]byte{}, exifHeader...), tiff...)
+	var b bytes.Buffer
+	b.Write(img.Bytes()[:2])
+	b.Write([]byte{0xFF, markerAPP1})
+	binary.Write(&b, binary.BigEndian, uint16(len(payload)+2))
+	b.Write(payload)
+	b.Write(img.Bytes()[2:])
+	return b.Bytes()
+}
+
+func TestReadEXIF(t *testing.T) {
+	data := jpegWithEXIF(t, exifTIFF("2023:06:01 10:00:00", "2023:05:17 08:30:00"))
+	e, err := ReadEXIF(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2023, 5, 17, 8, 30, 0, 0, time.Local)
+	if !e.Date().Equal(want) {
+		t.Errorf("Date() = %v, want %v", e.Date(), want)
+	}
+	if want := time.Date(2023, 6, 1, 10, 0, 0, 0, time.Local); !e.DateTime.Equal(want) {
+		t.Errorf("DateTime = %v, want %v", e.DateTime, want)
+	}
+	// the image still decodes with the inserted segment
+	if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("decoding the image: %v", err)
+	}
+}
+
+func TestReadEXIFMissing(t *testing.T) {
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadEXIF(&img); err != ErrNoEXIF {
+		t.Errorf("ReadEXIF() error = %v, want ErrNoEXIF", err)
+	}
+	if _, err := ReadEXIF(bytes.NewReader([]byte("not an image"))); err != ErrNoEXIF {
+		t.Errorf("ReadEXIF(text) error = %v, want ErrNoEXIF", err)
+	}
+}
+
+// gpsTIFF builds a little endian TIFF structure with an orientation in IFD0
+// and a position of 33°52'30" S, 151°12'36" W in the GPS IFD
+func gpsTIFF() []byte {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	b.WriteString("II")
+	binary.Write(&b, le, uint16(42))
+	binary.Write(&b, le, uint32(8))
+
+	// IFD0 at 8: orientation 6 and the offset of the GPS IFD
+	const gpsOffset = 8 + 2 + 2*12 + 4
+	binary.Write(&b, le, uint16(2))
+	binary.Write(&b, le, []uint16{tagOrientation, 3})
+	binary.Write(&b, le, []uint32{1})
+	binary.Write(&b, le, []uint16{6, 0})
+	binary.Write(&b, le, []uint16{tagGPSIFD, 4})
+	binary.Write(&b, le, []uint32{1, gpsOffset})
+	binary.Write(&b, le, uint32(0))
+
+	// GPS IFD: 4 entries, then the latitude and longitude rationals
+	const latOffset = gpsOffset + 2 + 4*12 + 4
+	binary.Write(&b, le, uint16(4))
+	binary.Write(&b, le, []uint16{tagGPSLatitudeRef, 2})
+	binary.Write(&b, le, uint32(2))
+	b.Write([]byte{'S', 0, 0, 0})
+	binary.Write(&b, le, []uint16{tagGPSLatitude, 5})
+	binary.Write(&b, le, []uint32{3, latOffset})
+	binary.Write(&b, le, []uint16{tagGPSLongitudeRef, 2})
+	binary.Write(&b, le, uint32(2))
+	b.Write([]byte{'W', 0, 0, 0})
+	binary.Write(&b, le, []uint16{tagGPSLongitude, 5})
+	binary.Write(&b, le, []uint32{3, latOffset + 24})
+	binary.Write(&b, le, uint32(0))
+	binary.Write(&b, le, []uint32{33, 1, 52, 1, 300, 10})
+	binary.Write(&b, le, []uint32{151, 1, 12, 1, 36, 1})
+	return b.Bytes()
+}
+
+func TestReadEXIFGPS(t *testing.T) {
+	e, err := ReadEXIF(bytes.NewReader(jpegWithEXIF(t, gpsTIFF())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Orientation != 6 || !e.Rotated() {
+		t.Errorf("Orientation = %d, want 6", e.Orientation)
+	}
+	if !e.HasGPS || math.Abs(e.Latitude+33.875) > 1e-9 || math.Abs(e.Longitude+151.21) > 1e-9 {
+		t.Errorf("position = %v, %v, %v, want -33.875, -151.21", e.HasGPS, e.Latitude, e.Longitude)
+	}
+
+	e, err = ReadEXIF(bytes.NewReader(jpegWithEXIF(t, exifTIFF("2023:06:01 10:00:00", "2023:05:17 08:30:00"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.HasGPS || e.Orientation != 0 {
+		t.Errorf("no GPS data, got %+v", e)
+	}
+}
@@ -0,0 +1,236 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// photoshopHeader starts the APP13 segment holding Photoshop image resources, among them IPTC
+var photoshopHeader = []byte("Photoshop 3.0\x00")
+
+// Photoshop image resources used by this package
+const (
+	resourceIPTC       = 0x0404
+	resourceIPTCDigest = 0x0425
+)
+
+// IPTC datasets used by this package
+const (
+	iptcTag               = 0x1C
+	iptcCharset           = 90 // record 1
+	iptcKeywords          = 25 // record 2
+	iptcRecordEnvelope    = 1
+	iptcRecordApplication = 2
+)
+
+// iptcUTF8 is the ISO 2022 escape sequence declaring UTF-8 in the coded character set dataset
+var iptcUTF8 = []byte("\x1b%G")
+
+// psResource is a Photoshop image resource block
+type psResource struct {
+	id   uint16
+	name []byte // Pascal string including the length byte and padding
+	data []byte
+}
+
+// iptcDataset is one IPTC field
+type iptcDataset struct {
+	record  byte
+	dataset byte
+	data    []byte
+}
+
+// parsePhotoshop parses the resource blocks of an APP13 segment without its header
+func parsePhotoshop(data []byte) ([]psResource, error) {
+	resources := []psResource{}
+	for len(data) > 0 {
+		if len(data) < 7 || string(data[:4]) != "8BIM" {
+			return nil, fmt.Errorf("invalid Photoshop resource")
+		}
+		r := psResource{id: binary.BigEndian.Uint16(data[4:6])}
+		nameLen := 1 + int(data[6])
+		if nameLen%2 != 0 {
+			nameLen++
+		}
+		if len(data) < 6+nameLen+4 {
+			return nil, fmt.Errorf("truncated Photoshop resource")
+		}
+		r.name = data[6 : 6+nameLen]
+		data = data[6+nameLen:]
+		size := int(binary.BigEndian.Uint32(data[:4]))
+		data = data[4:]
+		if size > len(data) {
+			return nil, fmt.Errorf("truncated Photoshop resource")
+		}
+		r.data = data[:size]
+		if size%2 != 0 {
+			size++
+		}
+		if size > len(data) {
+			size = len(data)
+		}
+		data = data[size:]
+		resources = append(resources, r)
+	}
+	return resources, nil
+}
+
+func buildPhotoshop(resources []psResource) []byte {
+	var b bytes.Buffer
+	for _, r := range resources {
+		b.WriteString("8BIM")
+		binary.Write(&b, binary.BigEndian, r.id)
+		b.Write(r.name)
+		binary.Write(&b, binary.BigEndian, uint32(len(r.data)))
+		b.Write(r.data)
+		if len(r.data)%2 != 0 {
+			b.WriteByte(0)
+		}
+	}
+	return b.Bytes()
+}
+
+func parseIPTC(data []byte) ([]iptcDataset, error) {
+	datasets := []iptcDataset{}
+	for len(data) > 0 {
+		if data[0] != iptcTag {
+			// some writers pad the resource with zeros
+			if bytes.Count(data, []byte{0}) == len(data) {
+				break
+			}
+			return nil, fmt.Errorf("invalid IPTC dataset")
+		}
+		if len(data) < 5 {
+			return nil, fmt.Errorf("truncated IPTC dataset")
+		}
+		size := int(binary.BigEndian.Uint16(data[3:5]))
+		if size&0x8000 != 0 {
+			return nil, fmt.Errorf("extended IPTC datasets are not supported")
+		}
+		if len(data) < 5+size {
+			return nil, fmt.Errorf("truncated IPTC dataset")
+		}
+		datasets = append(datasets, iptcDataset{record: data[1], dataset: data[2], data: data[5 : 5+size]})
+		data = data[5+size:]
+	}
+	return datasets, nil
+}
+
+func buildIPTC(datasets []iptcDataset) []byte {
+	var b bytes.Buffer
+	for _, d := range datasets {
+		b.Write([]byte{iptcTag, d.record, d.dataset})
+		binary.Write(&b, binary.BigEndian, uint16(len(d.data)))
+		b.Write(d.data)
+	}
+	return b.Bytes()
+}
+
+// iptcKeywordList returns the keywords of the IPTC datasets
+func iptcKeywordList(datasets []iptcDataset) []string {
+	keywords := []string{}
+	for _, d := range datasets {
+		if d.record == iptcRecordApplication && d.dataset == iptcKeywords {
+			keywords = append(keywords, string(d.data))
+		}
+	}
+	return keywords
+}
+
+// setIPTCKeywords replaces the keywords in datasets. The envelope record stays in front
+// of the application record, other datasets keep their order, data and character set.
+// UTF-8 is declared if nothing is declared and the other datasets are ASCII, which
+// reads the same in UTF-8. Under another character set only ASCII keywords are
+// written, the XMP packet has all of them.
+func setIPTCKeywords(datasets []iptcDataset, keywords []string) []iptcDataset {
+	envelope, application := []iptcDataset{}, []iptcDataset{}
+	var charset []byte
+	hasCharset, ascii := false, true
+	for _, d := range datasets {
+		switch {
+		case d.record == iptcRecordEnvelope:
+			if d.dataset == iptcCharset {
+				charset, hasCharset = d.data, true
+			}
+			envelope = append(envelope, d)
+		case d.record == iptcRecordApplication && d.dataset == iptcKeywords:
+			continue
+		default:
+			application = append(application, d)
+		}
+		ascii = ascii && isASCII(d.data)
+	}
+	if !hasCharset && ascii {
+		charset = iptcUTF8
+		envelope = append(envelope, iptcDataset{record: iptcRecordEnvelope, dataset: iptcCharset, data: iptcUTF8})
+	}
+	utf8 := bytes.Equal(charset, iptcUTF8)
+	for _, k := range keywords {
+		if !utf8 && !isASCII([]byte(k)) {
+			continue
+		}
+		application = append(application, iptcDataset{record: iptcRecordApplication, dataset: iptcKeywords, data: []byte(k)})
+	}
+	return append(envelope, application...)
+}
+
+func isASCII(data []byte) bool {
+	for _, c := range data {
+		if c >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// readIPTCKeywords returns the IPTC keywords of an APP13 segment without its header
+func readIPTCKeywords(segment []byte) ([]string, error) {
+	resources, err := parsePhotoshop(segment)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resources {
+		if r.id == resourceIPTC {
+			datasets, err := parseIPTC(r.data)
+			if err != nil {
+				return nil, err
+			}
+			return iptcKeywordList(datasets), nil
+		}
+	}
+	return nil, nil
+}
+
+// writeIPTCKeywords returns the APP13 segment, without its header, with keywords set.
+// The IPTC digest is dropped because it doesn't match anymore.
+func writeIPTCKeywords(segment []byte, keywords []string) ([]byte, error) {
+	resources, err := parsePhotoshop(segment)
+	if err != nil {
+		return nil, err
+	}
+	kept := []psResource{}
+	found := false
+	for _, r := range resources {
+		switch r.id {
+		case resourceIPTCDigest:
+			continue
+		case resourceIPTC:
+			datasets, err := parseIPTC(r.data)
+			if err != nil {
+				return nil, err
+			}
+			r.data = buildIPTC(setIPTCKeywords(datasets, keywords))
+			found = true
+		}
+		kept = append(kept, r)
+	}
+	if !found && len(keywords) > 0 {
+		kept = append(kept, psResource{
+			id:   resourceIPTC,
+			name: []byte{0, 0},
+			data: buildIPTC(setIPTCKeywords(nil, keywords)),
+		})
+	}
+	return buildPhotoshop(kept), nil
+}
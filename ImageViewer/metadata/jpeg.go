@@ -0,0 +1,120 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// JPEG markers used by this package
+const (
+	markerSOI   = 0xD8
+	markerSOS   = 0xDA
+	markerAPP0  = 0xE0
+	markerAPP1  = 0xE1
+	markerAPP13 = 0xED
+)
+
+// exifHeader starts the APP1 segment holding EXIF data
+var exifHeader = []byte("Exif\x00\x00")
+
+// xmpHeader starts the APP1 segment holding the XMP packet
+var xmpHeader = []byte("http://ns.adobe.com/xap/1.0/\x00")
+
+// maxSegmentData is the most data a JPEG segment can hold besides its length field
+const maxSegmentData = 0xFFFF - 2
+
+// jpegSegment is a marker segment of a JPEG file, data excludes the length field
+type jpegSegment struct {
+	marker byte
+	data   []byte
+}
+
+// readJPEGSegments reads the marker segments of a JPEG file up to the start of scan
+func readJPEGSegments(r io.Reader) ([]jpegSegment, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil {
+		return nil, err
+	}
+	if soi[0] != 0xFF || soi[1] != markerSOI {
+		return nil, fmt.Errorf("not a JPEG file")
+	}
+
+	segments := []jpegSegment{}
+	for {
+		marker, err := readMarker(r)
+		if err != nil {
+			return nil, err
+		}
+		if marker == markerSOS {
+			return segments, nil
+		}
+		data, err := readSegmentData(r)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, jpegSegment{marker: marker, data: data})
+	}
+}
+
+// readMarker reads the next marker, skipping fill bytes
+func readMarker(r io.Reader) (byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	if b[0] != 0xFF {
+		return 0, fmt.Errorf("invalid JPEG marker 0x%02x", b[0])
+	}
+	for b[0] == 0xFF {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, err
+		}
+	}
+	return b[0], nil
+}
+
+func readSegmentData(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint16(length[:]))
+	if n < 2 {
+		return nil, fmt.Errorf("invalid JPEG segment length %d", n)
+	}
+	data := make([]byte, n-2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// splitJPEG returns the marker segments of a JPEG file and the remaining data
+// starting with the start of scan marker, which holds the compressed pixels.
+func splitJPEG(data []byte) ([]jpegSegment, []byte, error) {
+	r := bytes.NewReader(data)
+	segments, err := readJPEGSegments(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	consumed := len(data) - r.Len()
+	return segments, data[consumed-2:], nil
+}
+
+// joinJPEG is the inverse of splitJPEG
+func joinJPEG(segments []jpegSegment, scan []byte) ([]byte, error) {
+	var b bytes.Buffer
+	b.Write([]byte{0xFF, markerSOI})
+	for _, seg := range segments {
+		if len(seg.data) > maxSegmentData {
+			return nil, fmt.Errorf("JPEG segment 0x%02x too large", seg.marker)
+		}
+		b.Write([]byte{0xFF, seg.marker})
+		binary.Write(&b, binary.BigEndian, uint16(len(seg.data)+2))
+		b.Write(seg.data)
+	}
+	b.Write(scan)
+	return b.Bytes(), nil
+}
@@ -0,0 +1,75 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+// pngSignature starts every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngChunk is a chunk of a PNG file, without length and CRC
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+// readPNGChunks reads all chunks of a PNG file up to IEND
+func readPNGChunks(r io.Reader) ([]pngChunk, error) {
+	sig := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, sig); err != nil {
+		return nil, err
+	}
+	if string(sig) != string(pngSignature) {
+		return nil, fmt.Errorf("not a PNG file")
+	}
+
+	chunks := []pngChunk{}
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, err
+		}
+		n := binary.BigEndian.Uint32(header[:4])
+		if n > 1<<31-1 {
+			return nil, fmt.Errorf("invalid PNG chunk length %d", n)
+		}
+		// a broken length mustn't allocate more than the file has
+		data, err := ioutil.ReadAll(io.LimitReader(r, int64(n)))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) < int(n) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		c := pngChunk{typ: string(header[4:8]), data: data}
+		var crc [4]byte
+		if _, err := io.ReadFull(r, crc[:]); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, c)
+		if c.typ == "IEND" {
+			return chunks, nil
+		}
+	}
+}
+
+// writePNGChunks returns a PNG file made of chunks, with their CRCs computed again
+func writePNGChunks(chunks []pngChunk) []byte {
+	var b bytes.Buffer
+	b.Write(pngSignature)
+	for _, c := range chunks {
+		binary.Write(&b, binary.BigEndian, uint32(len(c.data)))
+		crc := crc32.NewIEEE()
+		crc.Write([]byte(c.typ))
+		crc.Write(c.data)
+		b.WriteString(c.typ)
+		b.Write(c.data)
+		binary.Write(&b, binary.BigEndian, crc.Sum32())
+	}
+	return b.Bytes()
+}
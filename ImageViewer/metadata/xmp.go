@@ -0,0 +1,172 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// XML namespaces used in XMP packets
+const (
+	nsRDF = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	nsDC  = "http://purl.org/dc/elements/1.1/"
+)
+
+// SidecarExt is appended to an image file name to get its XMP sidecar, e.g. "photo.jpg.xmp"
+const SidecarExt = ".xmp"
+
+// SidecarPath returns the path of the XMP sidecar of the image at imagePath
+func SidecarPath(imagePath string) string {
+	return imagePath + SidecarExt
+}
+
+// IsSidecar reports whether name is the name of an XMP sidecar
+func IsSidecar(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), SidecarExt)
+}
+
+// ReadSidecar returns the keywords (dc:subject) stored in the sidecar of the image at imagePath.
+// A missing sidecar gives no keywords and no error.
+func ReadSidecar(imagePath string) ([]string, error) {
+	data, err := ioutil.ReadFile(SidecarPath(imagePath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseXMP(data)
+}
+
+// WriteSidecar stores keywords as dc:subject in the sidecar of the image at imagePath.
+// Other properties of an existing sidecar are kept.
+func WriteSidecar(imagePath string, keywords []string) error {
+	path := SidecarPath(imagePath)
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		data = BuildXMP(keywords)
+	case err != nil:
+		return err
+	default:
+		if data, err = SetXMPSubject(data, keywords); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	// write to a temporary file first so a crash can't leave a truncated sidecar
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ParseXMP returns the keywords (the dc:subject bag) of an XMP packet
+func ParseXMP(data []byte) ([]string, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	keywords := []string{}
+	inSubject, inItem := false, false
+	item := ""
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				return keywords, nil
+			}
+			return nil, fmt.Errorf("invalid XMP: %v", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == nsDC && t.Name.Local == "subject":
+				inSubject = true
+			case inSubject && t.Name.Space == nsRDF && t.Name.Local == "li":
+				inItem, item = true, ""
+			}
+		case xml.EndElement:
+			switch {
+			case t.Name.Space == nsDC && t.Name.Local == "subject":
+				inSubject = false
+			case inItem && t.Name.Space == nsRDF && t.Name.Local == "li":
+				inItem = false
+				if item = strings.TrimSpace(item); item != "" {
+					keywords = append(keywords, item)
+				}
+			}
+		case xml.CharData:
+			if inItem {
+				item += string(t)
+			}
+		}
+	}
+}
+
+// BuildXMP returns an XMP packet with keywords as dc:subject
+func BuildXMP(keywords []string) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` + "\n")
+	b.WriteString(` <rdf:RDF xmlns:rdf="` + nsRDF + `">` + "\n")
+	b.WriteString(`  <rdf:Description rdf:about="" xmlns:dc="` + nsDC + `">` + "\n")
+	b.WriteString(subjectXML(keywords))
+	b.WriteString("  </rdf:Description>\n")
+	b.WriteString(" </rdf:RDF>\n")
+	b.WriteString("</x:xmpmeta>\n")
+	return b.Bytes()
+}
+
+// subjectXML returns the dc:subject element for keywords
+func subjectXML(keywords []string) string {
+	var b bytes.Buffer
+	b.WriteString("   <dc:subject>\n    <rdf:Bag>\n")
+	for _, k := range keywords {
+		b.WriteString("     <rdf:li>")
+		xml.EscapeText(&b, []byte(k))
+		b.WriteString("</rdf:li>\n")
+	}
+	b.WriteString("    </rdf:Bag>\n   </dc:subject>\n")
+	return b.String()
+}
+
+var (
+	subjectElement     = regexp.MustCompile(`(?s)[ \t]*<dc:subject\b(?:[^>]*/>|.*?</dc:subject>)\s*`)
+	descriptionElement = regexp.MustCompile(`(?s)<rdf:Description\b[^>]*?(/?)>`)
+	xpacketInstruction = regexp.MustCompile(`<\?xpacket\b[^>]*\?>`)
+)
+
+// SetXMPSubject replaces the keywords of an existing XMP packet and keeps all other properties
+func SetXMPSubject(packet []byte, keywords []string) ([]byte, error) {
+	if _, err := ParseXMP(packet); err != nil {
+		return nil, err
+	}
+	subject := subjectXML(keywords)
+	if loc := subjectElement.FindIndex(packet); loc != nil {
+		return concat(packet[:loc[0]], []byte(subject), packet[loc[1]:]), nil
+	}
+
+	m := descriptionElement.FindSubmatchIndex(packet)
+	if m == nil {
+		return nil, fmt.Errorf("XMP packet has no rdf:Description")
+	}
+	start := string(packet[m[0]:m[1]])
+	selfClosing := m[2] != m[3]
+	if selfClosing {
+		start = strings.TrimSuffix(start, "/>") + ">"
+	}
+	if !bytes.Contains(packet, []byte(`xmlns:dc=`)) {
+		start = strings.TrimSuffix(start, ">") + ` xmlns:dc="` + nsDC + `">`
+	}
+	insert := start + "\n" + subject
+	if selfClosing {
+		insert += "  </rdf:Description>"
+	}
+	return concat(packet[:m[0]], []byte(insert), packet[m[1]:]), nil
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
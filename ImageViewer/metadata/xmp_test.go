@@ -0,0 +1,75 @@
+package metadata
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSidecarRoundTrip(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "photo.jpg")
+	if tags, err := ReadSidecar(image); err != nil || len(tags) != 0 {
+		t.Fatalf("ReadSidecar() without sidecar = %v, %v", tags, err)
+	}
+
+	want := []string{"FURNACE", "TANK & DATA"}
+	if err := WriteSidecar(image, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadSidecar(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadSidecar() = %v, want %v", got, want)
+	}
+
+	if err := WriteSidecar(image, []string{"AC"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ReadSidecar(image); !reflect.DeepEqual(got, []string{"AC"}) {
+		t.Errorf("ReadSidecar() after rewrite = %v, want [AC]", got)
+	}
+}
+
+func TestSetXMPSubjectKeepsProperties(t *testing.T) {
+	packets := map[string]string{
+		"with subject": `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmp:Rating="4">
+   <dc:subject><rdf:Bag><rdf:li>OLD</rdf:li></rdf:Bag></dc:subject>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`,
+		"without subject": `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmp:Rating="4"/>
+ </rdf:RDF>
+</x:xmpmeta>`,
+	}
+	for name, packet := range packets {
+		out, err := SetXMPSubject([]byte(packet), []string{"AC", "TANK"})
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !strings.Contains(string(out), `xmp:Rating="4"`) {
+			t.Errorf("%s: rating lost:\n%s", name, out)
+		}
+		if got, err := ParseXMP(out); err != nil || !reflect.DeepEqual(got, []string{"AC", "TANK"}) {
+			t.Errorf("%s: ParseXMP() = %v, %v\n%s", name, got, err, out)
+		}
+	}
+}
+
+func TestWriteSidecarInvalid(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := ioutil.WriteFile(SidecarPath(image), []byte("<x:xmpmeta>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteSidecar(image, []string{"AC"}); err == nil {
+		t.Error("WriteSidecar() over a broken sidecar succeeded")
+	}
+}
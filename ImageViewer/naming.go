@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+)
+
+// parseJobVariables parses "name=value" lines, e.g. "address=12 Main St"
+func parseJobVariables(lines []string) (map[string]string, error) {
+	vars := map[string]string{}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" {
+			return nil, fmt.Errorf("job variable %q is not of the form name=value", line)
+		}
+		if strings.ContainsAny(name, "{}: ") {
+			return nil, fmt.Errorf("job variable name %q can't contain braces, colons or spaces", name)
+		}
+		if strings.ContainsAny(parts[1], `/\`) {
+			return nil, fmt.Errorf("job variable %q can't contain path separators", name)
+		}
+		vars[name] = strings.TrimSpace(parts[1])
+	}
+	return vars, nil
+}
+
+// parseNameTemplate validates the template and job variables as entered in the Tagger tab
+func parseNameTemplate(text string, lines []string) (*tagger.Template, map[string]string, error) {
+	vars, err := parseJobVariables(lines)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := tagger.ParseTemplate(text, vars)
+	if err != nil {
+		return nil, nil, err
+	}
+	return t, vars, nil
+}
+
+// nameTemplate returns the configured template, or the default template if the config is invalid
+func (a *App) nameTemplate() (*tagger.Template, map[string]string) {
+	t, vars, err := parseNameTemplate(a.config.GetString("nametemplate"), a.config.GetStringSlice("jobvariables"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid name template: %v. The default template will be used.\n", err)
+		t, _ = tagger.ParseTemplate(tagger.DefaultTemplate, nil)
+		return t, nil
+	}
+	return t, vars
+}
+
+// templateExample renders t with sample values for the live preview
+func (a *App) templateExample(t *tagger.Template, vars map[string]string) string {
+	f := tagger.Fields{Stem: "IMG_0001", Seq: 1, Date: time.Now(), Folder: "Folder", Vars: vars}
+	for _, tag := range a.buttonTags {
+		if tag != "" && len(f.Tags) < 2 {
+			f.Tags = append(f.Tags, tag)
+		}
+	}
+	if a.session != nil {
+		f.Folder = filepath.Base(a.session.CurrentDir())
+	}
+	return t.Render(f, ".jpg")
+}
+
+// loadTemplateEditor returns the editor for the name template and the job variables
+// templateSaveDelay is how long the template editor waits after the last key before it writes the config
+const templateSaveDelay = time.Second
+
+func (a *App) loadTemplateEditor() fyne.CanvasObject {
+	templateEntry := widget.NewEntry()
+	templateEntry.SetText(a.config.GetString("nametemplate"))
+	templateEntry.SetPlaceHolder(tagger.DefaultTemplate)
+	varsEntry := widget.NewMultiLineEntry()
+	varsEntry.SetText(strings.Join(a.config.GetStringSlice("jobvariables"), "\n"))
+	varsEntry.SetPlaceHolder("address=12 Main St")
+	varsEntry.SetMinRowsVisible(2)
+	example := widget.NewLabel("")
+
+	validate := func() (*tagger.Template, map[string]string, error) {
+		return parseNameTemplate(templateEntry.Text, strings.Split(varsEntry.Text, "\n"))
+	}
+	templateEntry.Validator = func(string) error {
+		_, _, err := validate()
+		return err
+	}
+	// the config is written when typing paused, or at once with Enter
+	var saveTimer *time.Timer
+	save := func(delay time.Duration) {
+		if saveTimer != nil {
+			saveTimer.Stop()
+		}
+		saveTimer = time.AfterFunc(delay, func() {
+			a.runOnUI(a.WriteConfig)
+		})
+	}
+	update := func() bool {
+		t, vars, err := validate()
+		if err != nil {
+			example.SetText(fmt.Sprintf("Example: %v", err))
+			return false
+		}
+		example.SetText(fmt.Sprintf("Example: %s", a.templateExample(t, vars)))
+
+		a.config.Set("nametemplate", templateEntry.Text)
+		a.config.Set("jobvariables", strings.Split(strings.TrimSpace(varsEntry.Text), "\n"))
+		if a.session != nil {
+			a.session.SetTemplate(t, vars)
+			a.renamePreview.SetText(a.session.Preview())
+			a.refreshChecklist()
+		}
+		return true
+	}
+	templateEntry.OnChanged = func(string) {
+		if update() {
+			save(templateSaveDelay)
+		}
+	}
+	templateEntry.OnSubmitted = func(string) {
+		if update() {
+			save(0)
+		}
+	}
+	varsEntry.OnChanged = func(string) {
+		templateEntry.Validate()
+		if update() {
+			save(templateSaveDelay)
+		}
+	}
+	update()
+
+	return container.NewVBox(
+		widget.NewLabel("Name Template: {tags} {seq:03} {stem} {exif.date:2006-01-02} {folder} {variable}"),
+		templateEntry,
+		widget.NewLabel("Job Variables (name=value per line):"),
+		varsEntry,
+		example,
+	)
+}
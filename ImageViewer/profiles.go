@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+)
+
+// defaultProfileName is the profile created from the tag buttons of configs without profiles
+const defaultProfileName = "Default"
+
+// loadProfiles reads the tag profiles from the config. Configs from before profiles
+// existed get a profile holding their tag buttons.
+func (a *App) loadProfiles() {
+	a.profiles = []tagger.Profile{}
+	if err := a.config.UnmarshalKey("profiles", &a.profiles); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading tag profiles: %v. The tag buttons will be used.\n", err)
+	}
+	if len(a.profiles) == 0 {
+		a.profiles = []tagger.Profile{{
+			Name:    defaultProfileName,
+			Tags:    a.config.GetStringSlice("buttontags"),
+			Hotkeys: a.config.GetStringSlice("buttonhotkeys"),
+		}}
+	}
+	// the fixed button slots of older configs become a list of buttons
+	migrated := false
+	for i := range a.profiles {
+		if len(a.profiles[i].Tags) > 0 {
+			a.profiles[i].Migrate()
+			migrated = true
+		}
+	}
+	a.profile = tagger.FindProfile(a.profiles, a.config.GetString("profile"))
+	if a.profile < 0 {
+		a.profile = 0
+	}
+	if migrated {
+		a.saveProfiles()
+	}
+}
+
+// saveProfiles writes the tag profiles and the selected profile to the config
+func (a *App) saveProfiles() {
+	a.config.Set("profiles", a.profiles)
+	if a.profile >= 0 && a.profile < len(a.profiles) {
+		a.config.Set("profile", a.profiles[a.profile].Name)
+	}
+	a.WriteConfig()
+}
+
+// profileNames returns the names of all profiles for the profile selector
+func (a *App) profileNames() []string {
+	names := []string{}
+	for _, p := range a.profiles {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// selectProfile shows the tag buttons of profile i
+func (a *App) selectProfile(i int) {
+	if i < 0 || i >= len(a.profiles) {
+		return
+	}
+	a.profile = i
+	a.setTagButtons(a.profiles[i].Buttons)
+	a.saveProfiles()
+	if a.profileSelector != nil && a.profileSelector.Selected != a.profiles[i].Name {
+		a.profileSelector.SetSelected(a.profiles[i].Name)
+	}
+}
+
+// selectFolderProfile selects the profile assigned to dir, if there is one
+func (a *App) selectFolderProfile(dir string) {
+	if i := tagger.MatchProfile(a.profiles, dir); i >= 0 && i != a.profile {
+		a.selectProfile(i)
+	}
+}
+
+// loadProfileSelector returns the profile selector with the buttons to manage profiles
+func (a *App) loadProfileSelector() fyne.CanvasObject {
+	a.profileSelector = widget.NewSelect(a.profileNames(), func(selected string) {
+		if i := tagger.FindProfile(a.profiles, selected); i >= 0 && i != a.profile {
+			a.selectProfile(i)
+		}
+	})
+	a.profileSelector.SetSelected(a.profiles[a.profile].Name)
+
+	newBtn := widget.NewButton("New", a.newProfileDialog)
+	deleteBtn := widget.NewButton("Delete", a.deleteProfileDialog)
+	folderBtn := widget.NewButton("Use for This Folder", a.assignProfileToFolder)
+	importBtn := widget.NewButton("Import", a.importProfilesDialog)
+	exportBtn := widget.NewButton("Export", a.exportProfilesDialog)
+
+	return container.NewVBox(
+		container.NewBorder(nil, nil, widget.NewLabel("Profile:"), nil, a.profileSelector),
+		container.NewGridWithColumns(3, newBtn, deleteBtn, folderBtn, importBtn, exportBtn),
+	)
+}
+
+// refreshProfileSelector updates the selector after profiles were added or removed
+func (a *App) refreshProfileSelector() {
+	a.profileSelector.Options = a.profileNames()
+	a.profileSelector.Refresh()
+	a.profileSelector.SetSelected(a.profiles[a.profile].Name)
+}
+
+// newProfileDialog creates a profile, starting with a copy of the current tag buttons
+func (a *App) newProfileDialog() {
+	nameEntry := widget.NewEntry()
+	nameEntry.Validator = func(name string) error {
+		if name == "" {
+			return fmt.Errorf("name is empty")
+		}
+		if tagger.FindProfile(a.profiles, name) >= 0 {
+			return fmt.Errorf("profile %s exists", name)
+		}
+		return nil
+	}
+	items := []*widget.FormItem{widget.NewFormItem("Name", nameEntry)}
+	dialog.ShowForm("New Profile", "Create", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		a.profiles = append(a.profiles, tagger.Profile{
+			Name:    nameEntry.Text,
+			Buttons: append([]tagger.Tag{}, a.profiles[a.profile].Buttons...),
+		})
+		a.selectProfile(len(a.profiles) - 1)
+		a.refreshProfileSelector()
+	}, a.mainWin)
+}
+
+// deleteProfileDialog deletes the selected profile after asking
+func (a *App) deleteProfileDialog() {
+	if len(a.profiles) == 1 {
+		dialog.ShowError(fmt.Errorf("the last profile can't be deleted"), a.mainWin)
+		return
+	}
+	name := a.profiles[a.profile].Name
+	dialog.ShowConfirm("Delete profile?", fmt.Sprintf("Do you really want to delete the profile %s?", name), func(b bool) {
+		if !b {
+			return
+		}
+		a.profiles = append(a.profiles[:a.profile], a.profiles[a.profile+1:]...)
+		a.selectProfile(0)
+		a.refreshProfileSelector()
+	}, a.mainWin)
+}
+
+// assignProfileToFolder selects the current profile automatically for the open folder from now on
+func (a *App) assignProfileToFolder() {
+	if a.session == nil {
+		dialog.ShowError(fmt.Errorf("no folder opened"), a.mainWin)
+		return
+	}
+	dir := a.session.Dir()
+	for i := range a.profiles {
+		folders := []string{}
+		for _, f := range a.profiles[i].Folders {
+			if f != dir {
+				folders = append(folders, f)
+			}
+		}
+		a.profiles[i].Folders = folders
+	}
+	a.profiles[a.profile].Folders = append(a.profiles[a.profile].Folders, dir)
+	a.saveProfiles()
+	dialog.ShowInformation("Profile", fmt.Sprintf("%s will be used for\n%s", a.profiles[a.profile].Name, dir), a.mainWin)
+}
+
+// importProfilesDialog adds the profiles of a YAML or JSON file, replacing profiles of the same name
+func (a *App) importProfilesDialog() {
+	d := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.mainWin)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		reader.Close()
+		imported, err := tagger.ReadProfiles(reader.URI().Path())
+		if err != nil {
+			dialog.ShowError(err, a.mainWin)
+			return
+		}
+		name := a.profiles[a.profile].Name
+		a.profiles = tagger.MergeProfiles(a.profiles, imported)
+		a.selectProfile(tagger.FindProfile(a.profiles, name))
+		a.refreshProfileSelector()
+		dialog.ShowInformation("Import", fmt.Sprintf("Imported %d profiles", len(imported)), a.mainWin)
+	}, a.mainWin)
+	d.SetFilter(storage.NewExtensionFileFilter([]string{".yaml", ".yml", ".json"}))
+	d.Show()
+}
+
+// exportProfilesDialog writes all profiles to a YAML or JSON file, depending on the extension
+func (a *App) exportProfilesDialog() {
+	d := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.mainWin)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		writer.Close()
+		if err := tagger.WriteProfiles(writer.URI().Path(), a.profiles); err != nil {
+			dialog.ShowError(err, a.mainWin)
+		}
+	}, a.mainWin)
+	d.SetFileName("profiles.yaml")
+	d.Show()
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"fmt"
+	"strconv"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
 )
 
 func (a *App) loadSettingsUI() {
@@ -23,12 +28,114 @@ func (a *App) loadSettingsUI() {
 	})
 	themeSelector.SetSelected(a.app.Preferences().StringWithFallback("Theme", "System Default"))
 
+	policies := []string{}
+	for _, p := range tagger.CollisionPolicies {
+		policies = append(policies, string(p))
+	}
+	collisionSelector := widget.NewSelect(policies, func(selected string) {
+		a.config.Set("renamecollision", selected)
+		a.WriteConfig()
+		if a.session != nil {
+			a.session.SetCollisionPolicy(a.collisionPolicy())
+			a.renamePreview.Validate()
+		}
+	})
+	collisionSelector.SetSelected(string(a.collisionPolicy()))
+
+	deleteSelector := widget.NewSelect([]string{deleteModeTrash, deleteModePermanent}, func(selected string) {
+		a.config.Set("deletemode", selected)
+		a.WriteConfig()
+		if a.session != nil {
+			a.session.SetPermanentDelete(a.permanentDelete())
+		}
+	})
+	deleteSelector.SetSelected(a.config.GetString("deletemode"))
+
+	storages := []string{}
+	for _, s := range tagger.TagStorages {
+		storages = append(storages, string(s))
+	}
+	storageSelector := widget.NewSelect(storages, func(selected string) {
+		a.config.Set("tagstorage", selected)
+		a.WriteConfig()
+		if a.session != nil {
+			a.session.SetTagStorage(a.tagStorage())
+			a.refreshTagButtons()
+			a.refreshChecklist()
+		}
+	})
+	storageSelector.SetSelected(string(a.tagStorage()))
+
+	orders := []string{}
+	for _, o := range tagger.SortOrders {
+		orders = append(orders, string(o))
+	}
+	sortSelector := widget.NewSelect(orders, func(selected string) {
+		a.config.Set("sortorder", selected)
+		a.WriteConfig()
+		if a.session != nil {
+			a.session.SetSortOrder(a.sortOrder())
+			a.refreshFilmstrip()
+			if a.gridWin != nil {
+				a.gridWin.update()
+			}
+		}
+	})
+	sortSelector.SetSelected(string(a.sortOrder()))
+
+	embedCheck := widget.NewCheck("Also write tags into JPEG and PNG metadata (XMP, IPTC)", func(checked bool) {
+		a.config.Set("embedtags", checked)
+		a.WriteConfig()
+		if a.session != nil {
+			a.session.SetEmbedTags(checked)
+		}
+	})
+	embedCheck.SetChecked(a.config.GetBool("embedtags"))
+
+	trashAgeEntry := widget.NewEntry()
+	trashAgeEntry.SetText(strconv.Itoa(a.config.GetInt("trashmaxagedays")))
+	trashAgeEntry.Validator = func(s string) error {
+		if days, err := strconv.Atoi(s); err != nil || days < 0 {
+			return fmt.Errorf("input is not a valid number of days")
+		}
+		return nil
+	}
+	trashAgeEntry.OnChanged = func(s string) {
+		if trashAgeEntry.Validate() != nil {
+			return
+		}
+		days, _ := strconv.Atoi(s)
+		a.config.Set("trashmaxagedays", days)
+		a.WriteConfig()
+	}
+
 	winSettings.SetContent(container.NewVBox(
 		container.NewHBox(
 			widget.NewLabel("Theme"),
 			themeSelector,
 		),
+		container.NewHBox(
+			widget.NewLabel("Sort images by"),
+			sortSelector,
+		),
+		container.NewHBox(
+			widget.NewLabel("Save tags by"),
+			storageSelector,
+		),
+		embedCheck,
+		container.NewHBox(
+			widget.NewLabel("If the new name exists"),
+			collisionSelector,
+		),
+		container.NewHBox(
+			widget.NewLabel("Deleted images go to"),
+			deleteSelector,
+		),
+		container.NewBorder(nil, nil,
+			widget.NewLabel("Purge trash after days (0 = never)"), nil,
+			trashAgeEntry,
+		),
 	))
-	winSettings.Resize(fyne.NewSize(300, 150))
+	winSettings.Resize(fyne.NewSize(400, 250))
 	winSettings.Show()
 }
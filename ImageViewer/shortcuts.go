@@ -2,7 +2,6 @@ package main
 
 import (
 	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/widget"
 )
@@ -33,42 +32,67 @@ func (a *App) loadKeyboardShortcuts() {
 		Modifier: a.mainModKey,
 	}, func(shortcut fyne.Shortcut) { a.redo() })
 
+	// ctrl+shift+z to undo the last rename or delete
+	a.mainWin.Canvas().AddShortcut(&desktop.CustomShortcut{
+		KeyName:  fyne.KeyZ,
+		Modifier: a.mainModKey | fyne.KeyModifierShift,
+	}, func(shortcut fyne.Shortcut) { a.undoFileOperation() })
+
+	// ctrl+shift+y to redo the last undone rename or delete
+	a.mainWin.Canvas().AddShortcut(&desktop.CustomShortcut{
+		KeyName:  fyne.KeyY,
+		Modifier: a.mainModKey | fyne.KeyModifierShift,
+	}, func(shortcut fyne.Shortcut) { a.redoFileOperation() })
+
 	// ctrl+q to quit application
 	a.mainWin.Canvas().AddShortcut(&desktop.CustomShortcut{
 		KeyName:  fyne.KeyQ,
 		Modifier: a.mainModKey,
 	}, func(shortcut fyne.Shortcut) { a.app.Quit() })
 
+	// track shift for Shift+key tag hotkeys, which fyne doesn't report as shortcuts
+	if deskCanvas, ok := a.mainWin.Canvas().(desktop.Canvas); ok {
+		deskCanvas.SetOnKeyDown(func(key *fyne.KeyEvent) {
+			if key.Name == desktop.KeyShiftLeft || key.Name == desktop.KeyShiftRight {
+				a.shiftDown = true
+			}
+		})
+		deskCanvas.SetOnKeyUp(func(key *fyne.KeyEvent) {
+			if key.Name == desktop.KeyShiftLeft || key.Name == desktop.KeyShiftRight {
+				a.shiftDown = false
+			}
+		})
+	}
+
 	a.mainWin.Canvas().SetOnTypedKey(func(key *fyne.KeyEvent) {
+		// hotkeys of the tag buttons
+		if a.typedTagHotkey(key) {
+			return
+		}
+
 		switch key.Name {
 		// move forward/back within the current folder of images
 		case fyne.KeyRight:
-			a.nextImage(true, false)
+			a.nextImage(true)
 		case fyne.KeyLeft:
-			a.nextImage(false, false)
+			a.nextImage(false)
 		case fyne.KeyReturn:
 			a.nextImageWithSave()
 		// delete images with delete key
 		case fyne.KeyDelete:
-			if a.image.Image != nil {
-				dialog.ShowConfirm("Delte file?", "Do you really want to delete this image?\n This action can't be undone.", func(b bool) {
-					if b {
-						a.deleteFile()
-					}
-				}, a.mainWin)
-			}
+			a.deleteDialog()
 		// close dialogs with esc key
 		case fyne.KeyEscape:
 			if len(a.mainWin.Canvas().Overlays().List()) > 0 {
 				a.mainWin.Canvas().Overlays().Top().Hide()
 			}
 		case fyne.KeyF11:
-			if a.image.Image == nil {
+			if !a.image.HasImage() {
 				return
 			}
 			a.fullscreenMode()
 		case fyne.KeyF2:
-			if a.image.Image == nil {
+			if !a.image.HasImage() {
 				return
 			}
 			a.renameDialog()
@@ -78,6 +102,8 @@ func (a *App) loadKeyboardShortcuts() {
 			a.zoomImageOut()
 		case fyne.KeyEqual:
 			a.resetZoom()
+		case fyne.Key0:
+			a.zoomActual()
 		}
 	})
 }
@@ -87,17 +113,28 @@ func (a *App) showShortcuts() {
 		"Ctrl+O", "Ctrl+S", "Ctrl+Z",
 		"Ctrl+Y", "Ctrl+Q", "F11",
 		"Arrow Right", "Arrow Left", "Delete",
-		"F2", "Escape", "Plus", "Minus", "Equal"}
+		"F2", "Escape", "Plus", "Minus", "Equal", "0",
+		"Ctrl+Shift+Z", "Ctrl+Shift+Y"}
 	descriptions := []string{
 		"Open File", "Save File", "Undo",
 		"Redo", "Quit Application", "Fullscreen View",
 		"Next Image", "Last Image", "Delete Image",
 		"Rename", "Close dialog", "Zoom In", "Zoom Out",
-		"Zoom to 100%"}
+		"Fit to Window", "Zoom to 1:1", "Undo Rename/Delete", "Redo Rename/Delete"}
+
+	// tag button hotkeys
+	for i, hotkey := range a.buttonHotkeys {
+		h, err := parseTagHotkey(hotkey)
+		if err != nil || h.isEmpty() || i >= len(a.buttonTags) || a.buttonTags[i] == "" {
+			continue
+		}
+		shortcuts = append(shortcuts, h.String())
+		descriptions = append(descriptions, "Tag "+a.buttonTags[i])
+	}
 
 	win := a.app.NewWindow("Keyboard Shortcuts")
 	table := widget.NewTable(
-		func() (int, int) { return len(shortcuts), 2 },
+		func() (int, int) { return len(shortcuts) + 1, 2 },
 		func() fyne.CanvasObject {
 			return widget.NewLabel("")
 		},
@@ -115,7 +152,7 @@ func (a *App) showShortcuts() {
 				if id.Col == 0 {
 					label.SetText(descriptions[id.Row-1])
 				} else {
-					label.SetText(descriptions[id.Row-1])
+					label.SetText(shortcuts[id.Row-1])
 				}
 			}
 		},
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+)
+
+// tagEditRow is the row of a tag button in the edit mode
+type tagEditRow struct {
+	name   *widget.Entry
+	hotkey *widget.Entry
+	group  *widget.Entry
+	// required marks the tag as a required shot of the profile
+	required *widget.Check
+}
+
+// setTagButtons shows buttons in the Tagger tab, grouped into sections, and makes
+// their tags the vocabulary of the session.
+func (a *App) setTagButtons(buttons []tagger.Tag) {
+	a.buttons = append([]tagger.Tag{}, buttons...)
+	a.buttonTags = make([]string, len(buttons))
+	a.buttonHotkeys = make([]string, len(buttons))
+	a.tagBtns = make([]*widget.Button, len(buttons))
+	for i, t := range buttons {
+		a.buttonTags[i] = t.Name
+		a.buttonHotkeys[i] = t.Hotkey
+		index := i
+		a.tagBtns[i] = widget.NewButton(tagButtonText(t.Name, t.Hotkey), func() {
+			a.renamePreview.SetText(a.session.ToggleTag(a.buttonTags[index]))
+			// with sidecar storage the preview doesn't change
+			a.refreshTagButtons()
+		})
+		if a.session == nil {
+			a.tagBtns[i].Disable()
+		}
+	}
+
+	a.tagBtnArea.Objects = nil
+	profile := tagger.Profile{Buttons: buttons}
+	for _, group := range profile.Groups() {
+		if group != "" {
+			a.tagBtnArea.Add(widget.NewLabelWithStyle(group, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		}
+		grid := container.NewGridWithColumns(3)
+		for i, t := range buttons {
+			if t.Group == group {
+				grid.Add(a.tagBtns[i])
+			}
+		}
+		a.tagBtnArea.Add(grid)
+	}
+	a.tagBtnArea.Refresh()
+
+	a.bindTagHotkeys(a.buttonHotkeys)
+	if a.session != nil {
+		a.session.SetVocabulary(a.buttonTags)
+	}
+	if a.gridWin != nil {
+		a.gridWin.setTagButtons(a.buttonTags)
+	}
+	a.refreshTagButtons()
+	a.refreshChecklist()
+}
+
+// showTagEditor fills the edit mode with a row per button, required[i] checks the required box of row i
+func (a *App) showTagEditor(buttons []tagger.Tag, required []bool) {
+	a.tagEditRows = nil
+	a.tagEditor.Objects = nil
+	a.tagEditor.Add(widget.NewLabel("Tag, hotkey and group of each button. Buttons of a group are shown together."))
+
+	for i, t := range buttons {
+		row := tagEditRow{
+			name:     widget.NewEntry(),
+			hotkey:   widget.NewEntry(),
+			group:    widget.NewEntry(),
+			required: widget.NewCheck("Required", nil),
+		}
+		row.name.SetText(t.Name)
+		row.hotkey.SetText(t.Hotkey)
+		row.hotkey.SetPlaceHolder("e.g. 1, Shift+F")
+		row.hotkey.Validator = func(s string) error {
+			_, err := parseTagHotkey(s)
+			return err
+		}
+		row.group.SetText(t.Group)
+		row.group.SetPlaceHolder("none")
+		row.required.SetChecked(i < len(required) && required[i])
+		a.tagEditRows = append(a.tagEditRows, row)
+
+		index := i
+		up := widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() { a.moveTagRow(index, -1) })
+		down := widget.NewButtonWithIcon("", theme.MoveDownIcon(), func() { a.moveTagRow(index, 1) })
+		remove := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() { a.removeTagRow(index) })
+		if i == 0 {
+			up.Disable()
+		}
+		if i == len(buttons)-1 {
+			down.Disable()
+		}
+		a.tagEditor.Add(container.NewBorder(nil, nil, nil, container.NewHBox(row.required, up, down, remove),
+			container.NewGridWithColumns(3, row.name, row.hotkey, row.group)))
+	}
+	a.tagEditor.Add(widget.NewButtonWithIcon("Add Tag", theme.ContentAddIcon(), a.addTagRow))
+	a.tagEditor.Refresh()
+}
+
+// editedButtons returns the buttons as currently entered in the edit mode
+func (a *App) editedButtons() []tagger.Tag {
+	buttons := []tagger.Tag{}
+	for _, row := range a.tagEditRows {
+		buttons = append(buttons, tagger.Tag{
+			Name:   strings.TrimSpace(row.name.Text),
+			Hotkey: strings.TrimSpace(row.hotkey.Text),
+			Group:  strings.TrimSpace(row.group.Text),
+		})
+	}
+	return buttons
+}
+
+// editedRequired returns for each row of the edit mode whether it is required
+func (a *App) editedRequired() []bool {
+	required := []bool{}
+	for _, row := range a.tagEditRows {
+		required = append(required, row.required.Checked)
+	}
+	return required
+}
+
+// moveTagRow moves row i up (delta -1) or down (delta 1)
+func (a *App) moveTagRow(i, delta int) {
+	buttons, required := a.editedButtons(), a.editedRequired()
+	j := i + delta
+	if j < 0 || j >= len(buttons) {
+		return
+	}
+	buttons[i], buttons[j] = buttons[j], buttons[i]
+	required[i], required[j] = required[j], required[i]
+	a.showTagEditor(buttons, required)
+}
+
+func (a *App) removeTagRow(i int) {
+	buttons, required := a.editedButtons(), a.editedRequired()
+	a.showTagEditor(append(buttons[:i], buttons[i+1:]...), append(required[:i], required[i+1:]...))
+}
+
+// addTagRow adds an empty row in the group of the last row
+func (a *App) addTagRow() {
+	buttons := a.editedButtons()
+	t := tagger.Tag{}
+	if len(buttons) > 0 {
+		t.Group = buttons[len(buttons)-1].Group
+	}
+	a.showTagEditor(append(buttons, t), append(a.editedRequired(), false))
+	a.mainWin.Canvas().Focus(a.tagEditRows[len(a.tagEditRows)-1].name)
+}
@@ -0,0 +1,158 @@
+package tagger
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// BatchItem is the planned change of one image of a batch
+type BatchItem struct {
+	// Name is the path of the image relative to the session directory
+	Name string
+	// Target is the new file name in the folder of the image. It is the current
+	// file name if only the sidecar changes.
+	Target string
+	Tags   []string
+	// Conflict is set when Target is taken and the collision policy doesn't allow numbering.
+	// Conflicting images are skipped by ApplyBatch.
+	Conflict bool
+}
+
+// Changed reports whether the batch renames the image
+func (b BatchItem) Changed() bool {
+	return b.Target != filepath.Base(b.Name)
+}
+
+// PlanToggle plans adding tag to the images names, or removing it from all of
+// them if they all have it already, like ToggleTag does for one image.
+func (s *Session) PlanToggle(names []string, tag string) []BatchItem {
+	all := len(names) > 0
+	for _, name := range names {
+		if !HasTag(s.ImageTags(name), tag) {
+			all = false
+			break
+		}
+	}
+	return s.PlanTags(names, func(tags []string) []string {
+		if !all {
+			if HasTag(tags, tag) {
+				return tags
+			}
+			return append(tags, tag)
+		}
+		kept := []string{}
+		for _, t := range tags {
+			if t != tag {
+				kept = append(kept, t)
+			}
+		}
+		return kept
+	})
+}
+
+// PlanTags plans giving each of the images names the tags change returns for its tags.
+// The targets are rendered with the template and don't collide with each other.
+// The current image and its pending preview stay as they are.
+func (s *Session) PlanTags(names []string, change func(tags []string) []string) []BatchItem {
+	return s.plan(names, func() ([]string, string) {
+		tags := OrderTags(change(s.Tags()), s.vocabulary)
+		if !s.storage.inName() {
+			return tags, s.name()
+		}
+		return tags, s.Render(tags)
+	})
+}
+
+// PlanTemplate plans renaming the images names to the template t, keeping their
+// stems and tags. The names are read with the current template of the session.
+func (s *Session) PlanTemplate(names []string, t *Template, vars map[string]string) []BatchItem {
+	return s.plan(names, func() ([]string, string) {
+		stem, _ := s.parse(s.preview)
+		tags, named := s.Tags(), s.Tags()
+		if !s.storage.inName() {
+			named = nil
+		}
+		template, templateVars := s.template, s.vars
+		s.template, s.vars = t, vars
+		defer func() {
+			s.template, s.vars = template, templateVars
+		}()
+		return tags, s.render(stem, named)
+	})
+}
+
+// PlanCopies plans the names of copies of the images names: the template with
+// their tags, also when the tags are kept in sidecars. The copies go elsewhere,
+// so only names taken by other copies are numbered, whatever the collision policy.
+func (s *Session) PlanCopies(names []string) []BatchItem {
+	policy := s.policy
+	s.policy, s.copying = CollisionSequence, true
+	defer func() {
+		s.policy, s.copying = policy, false
+	}()
+	return s.plan(names, func() ([]string, string) {
+		stem, _ := s.parse(s.preview)
+		tags := s.Tags()
+		return tags, s.render(stem, tags)
+	})
+}
+
+// plan plans a batch over the images names. target returns the tags and the new
+// name of the current image; targets that collide are numbered or marked as conflicts.
+func (s *Session) plan(names []string, target func() ([]string, string)) []BatchItem {
+	current, preview, tags := s.Current(), s.preview, s.tags
+	defer func() {
+		s.reserved = nil
+		s.Seek(current)
+		s.preview, s.tags = preview, tags
+	}()
+
+	s.reserved = map[string]bool{}
+	items := []BatchItem{}
+	for _, name := range names {
+		if !s.Seek(name) {
+			continue
+		}
+		item := BatchItem{Name: name}
+		item.Tags, item.Target = target()
+		if (item.Target != s.name() || s.copying) && s.collides(item.Target) {
+			if s.policy == CollisionSequence {
+				item.Target = s.sequenceName(item.Target)
+			} else {
+				item.Conflict = true
+			}
+		}
+		s.reserved[filepath.Join(s.CurrentDir(), item.Target)] = true
+		items = append(items, item)
+	}
+	return items
+}
+
+// ApplyBatch renames the images of a plan and writes their tags, skipping conflicts.
+// Each rename is recorded in the journal on its own. The current image stays
+// selected, under its new name if it was part of the batch.
+func (s *Session) ApplyBatch(items []BatchItem) error {
+	current := s.Current()
+	failed := []string{}
+	for _, item := range items {
+		if item.Conflict || !s.Seek(item.Name) {
+			continue
+		}
+		// the target may follow another template than the session's, so its tags aren't parsed from it
+		if err := s.renameTags(item.Target, item.Tags); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", item.Name, err))
+			continue
+		}
+		if item.Name == current {
+			current = s.Current()
+		}
+	}
+	if !s.Seek(current) {
+		s.resetPreview()
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to rename %d images:\n%s", len(failed), strings.Join(failed, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package tagger
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/metadata"
+)
+
+func TestPlanToggle(t *testing.T) {
+	dir := makeDir(t, "IMG_1.jpg", "IMG_2 ATTIC.jpg", "IMG_3.jpg", "IMG_9.jpg")
+	s, err := NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"ATTIC", "FRONT"})
+	s.Seek("IMG_9.jpg")
+	s.ToggleTag("FRONT")
+
+	names := []string{"IMG_1.jpg", "IMG_2 ATTIC.jpg", "IMG_3.jpg"}
+	items := s.PlanToggle(names, "ATTIC")
+	want := []BatchItem{
+		{Name: "IMG_1.jpg", Target: "IMG_1 ATTIC.jpg", Tags: []string{"ATTIC"}},
+		{Name: "IMG_2 ATTIC.jpg", Target: "IMG_2 ATTIC.jpg", Tags: []string{"ATTIC"}},
+		{Name: "IMG_3.jpg", Target: "IMG_3 ATTIC.jpg", Tags: []string{"ATTIC"}},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("PlanToggle = %+v, want %+v", items, want)
+	}
+	if s.Current() != "IMG_9.jpg" || s.Preview() != "IMG_9 FRONT.jpg" {
+		t.Errorf("planning changed the current image to %q, %q", s.Current(), s.Preview())
+	}
+
+	if err := s.ApplyBatch(items); err != nil {
+		t.Fatal(err)
+	}
+	if got := listDir(t, dir); !reflect.DeepEqual(got, []string{"IMG_1 ATTIC.jpg", "IMG_2 ATTIC.jpg", "IMG_3 ATTIC.jpg", "IMG_9.jpg"}) {
+		t.Errorf("files = %v", got)
+	}
+	if s.Current() != "IMG_9.jpg" {
+		t.Errorf("Current() = %q after the batch", s.Current())
+	}
+
+	// all have the tag now, so it is removed
+	items = s.PlanToggle([]string{"IMG_1 ATTIC.jpg", "IMG_3 ATTIC.jpg"}, "ATTIC")
+	if items[0].Target != "IMG_1.jpg" || items[1].Target != "IMG_3.jpg" {
+		t.Errorf("PlanToggle to remove = %+v", items)
+	}
+}
+
+func TestPlanTagsCollisions(t *testing.T) {
+	dir := makeDir(t, "a.jpg", "b.jpg", "c.jpg", "ATTIC.jpg")
+	s, err := NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"ATTIC"})
+	tmpl, err := ParseTemplate("{tags}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetTemplate(tmpl, nil)
+	names := []string{"a.jpg", "b.jpg"}
+
+	items := s.PlanToggle(names, "ATTIC")
+	if items[0].Target != "ATTIC 2.jpg" || items[1].Target != "ATTIC 3.jpg" {
+		t.Errorf("sequence targets = %+v", items)
+	}
+
+	s.SetCollisionPolicy(CollisionRefuse)
+	items = s.PlanToggle(names, "ATTIC")
+	if !items[0].Conflict || !items[1].Conflict {
+		t.Errorf("refuse targets = %+v", items)
+	}
+	if err := s.ApplyBatch(items); err != nil {
+		t.Fatal(err)
+	}
+	if got := listDir(t, dir); !reflect.DeepEqual(got, []string{"ATTIC.jpg", "a.jpg", "b.jpg", "c.jpg"}) {
+		t.Errorf("conflicts were renamed: %v", got)
+	}
+
+	// {seq} numbers the batch
+	s.SetCollisionPolicy(CollisionSequence)
+	tmpl, err = ParseTemplate("{tags}-{seq}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetTemplate(tmpl, nil)
+	items = s.PlanToggle([]string{"a.jpg", "b.jpg", "c.jpg"}, "ATTIC")
+	targets := []string{}
+	for _, item := range items {
+		targets = append(targets, item.Target)
+	}
+	if !reflect.DeepEqual(targets, []string{"ATTIC-1.jpg", "ATTIC-2.jpg", "ATTIC-3.jpg"}) {
+		t.Errorf("seq targets = %v", targets)
+	}
+	if err := s.ApplyBatch(items); err != nil {
+		t.Fatal(err)
+	}
+	if got := listDir(t, dir); !reflect.DeepEqual(got, []string{"ATTIC-1.jpg", "ATTIC-2.jpg", "ATTIC-3.jpg", "ATTIC.jpg"}) {
+		t.Errorf("files = %v", got)
+	}
+}
+
+func TestApplyBatchSidecar(t *testing.T) {
+	dir := makeDir(t, "a.jpg", "b.jpg")
+	s, err := NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"ATTIC"})
+	s.SetTagStorage(StorageSidecar)
+
+	items := s.PlanToggle([]string{"a.jpg", "b.jpg"}, "ATTIC")
+	if err := s.ApplyBatch(items); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		tags, err := metadata.ReadSidecar(filepath.Join(dir, name))
+		if err != nil || !reflect.DeepEqual(tags, []string{"ATTIC"}) {
+			t.Errorf("sidecar of %s = %v, %v", name, tags, err)
+		}
+	}
+}
+
+func TestPlanTemplate(t *testing.T) {
+	dir := makeDir(t, "IMG_1 ATTIC.jpg", "IMG_2.jpg", "ATTIC_IMG_2.jpg")
+	s, err := NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"ATTIC"})
+	tmpl, err := ParseTemplate("{tags}_{stem}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := s.PlanTemplate([]string{"IMG_1 ATTIC.jpg", "IMG_2.jpg"}, tmpl, nil)
+	want := []BatchItem{
+		{Name: "IMG_1 ATTIC.jpg", Target: "ATTIC_IMG_1.jpg", Tags: []string{"ATTIC"}},
+		{Name: "IMG_2.jpg", Target: "IMG_2.jpg", Tags: []string{}},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("PlanTemplate = %+v, want %+v", items, want)
+	}
+	if s.Template() == tmpl {
+		t.Error("PlanTemplate changed the template of the session")
+	}
+
+	if err := s.ApplyBatch(items); err != nil {
+		t.Fatal(err)
+	}
+	if got := listDir(t, dir); !reflect.DeepEqual(got, []string{"ATTIC_IMG_1.jpg", "ATTIC_IMG_2.jpg", "IMG_2.jpg"}) {
+		t.Errorf("files = %v", got)
+	}
+}
+
+func TestApplyBatchTemplateKeepsTags(t *testing.T) {
+	dir := t.TempDir()
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, image.NewGray(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "IMG_1 ATTIC.jpg"), b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"ATTIC"})
+	s.SetTagStorage(StorageBoth)
+	s.SetEmbedTags(true)
+	tmpl, err := ParseTemplate("{tags}_{stem}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ApplyBatch(s.PlanTemplate([]string{"IMG_1 ATTIC.jpg"}, tmpl, nil)); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "ATTIC_IMG_1.jpg")
+	tags, err := metadata.ReadSidecar(path)
+	if err != nil || !reflect.DeepEqual(tags, []string{"ATTIC"}) {
+		t.Errorf("sidecar = %v, %v", tags, err)
+	}
+	keywords, err := metadata.ReadKeywords(path)
+	if err != nil || !reflect.DeepEqual(keywords, []string{"ATTIC"}) {
+		t.Errorf("embedded keywords = %v, %v", keywords, err)
+	}
+}
+
+func TestPlanCopies(t *testing.T) {
+	// ATTIC.jpg is unrelated and doesn't number the copies, they don't go into this folder
+	dir := makeDir(t, "ATTIC.jpg", "IMG_1.jpg", "IMG_2.jpg", "IMG_3 ATTIC.jpg")
+	s, err := NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"ATTIC"})
+	s.SetCollisionPolicy(CollisionRefuse)
+	tmpl, err := ParseTemplate("{tags}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetTemplate(tmpl, nil)
+	s.SetTagStorage(StorageSidecar)
+	for _, name := range []string{"IMG_1.jpg", "IMG_2.jpg"} {
+		if err := metadata.WriteSidecar(filepath.Join(dir, name), []string{"ATTIC"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	targets := []string{}
+	for _, item := range s.PlanCopies([]string{"IMG_1.jpg", "IMG_2.jpg"}) {
+		if item.Conflict {
+			t.Errorf("%s conflicts", item.Name)
+		}
+		targets = append(targets, item.Target)
+	}
+	if !reflect.DeepEqual(targets, []string{"ATTIC.jpg", "ATTIC 2.jpg"}) {
+		t.Errorf("targets = %v", targets)
+	}
+	if s.CollisionPolicy() != CollisionRefuse {
+		t.Error("PlanCopies changed the collision policy")
+	}
+	if got := listDir(t, dir); !reflect.DeepEqual(got, []string{"ATTIC.jpg", "IMG_1.jpg", "IMG_1.jpg.xmp", "IMG_2.jpg", "IMG_2.jpg.xmp", "IMG_3 ATTIC.jpg"}) {
+		t.Errorf("files = %v", got)
+	}
+}
@@ -0,0 +1,178 @@
+package tagger
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/metadata"
+)
+
+// ChecklistItem is a required tag and the images that have it
+type ChecklistItem struct {
+	Tag    string
+	Images []string
+}
+
+// Done reports whether at least one image has the tag
+func (c ChecklistItem) Done() bool {
+	return len(c.Images) > 0
+}
+
+// Report is the completeness of the required shots of a job folder
+type Report struct {
+	Dir       string
+	Profile   string
+	Generated time.Time
+	Items     []ChecklistItem
+}
+
+// Missing returns the required tags no image has
+func (r *Report) Missing() []string {
+	missing := []string{}
+	for _, item := range r.Items {
+		if !item.Done() {
+			missing = append(missing, item.Tag)
+		}
+	}
+	return missing
+}
+
+// Complete reports whether every required tag is present
+func (r *Report) Complete() bool {
+	return len(r.Missing()) == 0
+}
+
+// ImageTags returns the tags of the image name, a path relative to the session directory,
+// from its name or its sidecar depending on the tag storage. They are read once and
+// cached until the image is tagged or renamed, or the session is refreshed.
+func (s *Session) ImageTags(name string) []string {
+	tags, ok := s.imageTags[name]
+	if !ok {
+		tags = s.readImageTags(name)
+		if s.imageTags == nil {
+			s.imageTags = map[string][]string{}
+		}
+		s.imageTags[name] = tags
+	}
+	return append([]string{}, tags...)
+}
+
+// Version counts the changes of the images of the session and their tags, so views
+// of all images, like the checklist, only need to be refreshed when it changed
+func (s *Session) Version() int {
+	return s.version
+}
+
+// forgetTags drops the cached tags of the image name
+func (s *Session) forgetTags(name string) {
+	delete(s.imageTags, name)
+	s.version++
+}
+
+// forgetAllTags drops the cached tags of all images, e.g. when they are read differently
+func (s *Session) forgetAllTags() {
+	s.imageTags = nil
+	s.version++
+}
+
+// readImageTags reads the tags of the image name for ImageTags
+func (s *Session) readImageTags(name string) []string {
+	tags := []string{}
+	if s.storage.inName() {
+		base := filepath.Base(name)
+		stem := strings.TrimSuffix(base, filepath.Ext(base))
+		folder := filepath.Base(filepath.Join(s.dir, filepath.Dir(name)))
+		var ok bool
+		if _, tags, ok = s.template.Match(stem, Fields{Folder: folder, Vars: s.vars}, s.vocabulary); !ok {
+			_, tags = ParseTags(base, s.vocabulary)
+		}
+	}
+	if s.storage.inSidecar() {
+		stored, err := metadata.ReadSidecar(filepath.Join(s.dir, name))
+		if err != nil {
+			s.errs.add(fmt.Errorf("reading %s: %v", metadata.SidecarPath(filepath.Join(s.dir, name)), err))
+		}
+		tags = append(tags, stored...)
+	}
+	return OrderTags(tags, s.vocabulary)
+}
+
+// Checklist reports which of the required tags the images of the session have
+func (s *Session) Checklist(profile string, required []string) *Report {
+	r := &Report{Dir: s.dir, Profile: profile, Generated: time.Now()}
+	index := map[string]int{}
+	for _, tag := range required {
+		if _, ok := index[tag]; ok || tag == "" {
+			continue
+		}
+		index[tag] = len(r.Items)
+		r.Items = append(r.Items, ChecklistItem{Tag: tag})
+	}
+	for _, name := range s.images {
+		for _, tag := range s.ImageTags(name) {
+			if i, ok := index[tag]; ok {
+				r.Items[i].Images = append(r.Items[i].Images, name)
+			}
+		}
+	}
+	return r
+}
+
+// WriteText writes the report in a human readable form
+func (r *Report) WriteText(w io.Writer) error {
+	status := "COMPLETE"
+	if !r.Complete() {
+		status = fmt.Sprintf("INCOMPLETE, %d of %d required shots missing", len(r.Missing()), len(r.Items))
+	}
+	fmt.Fprintf(w, "Completeness report for %s\n", r.Dir)
+	fmt.Fprintf(w, "Profile: %s\n", r.Profile)
+	fmt.Fprintf(w, "Generated: %s\n", r.Generated.Format("2006-01-02 15:04"))
+	fmt.Fprintf(w, "Status: %s\n\n", status)
+	for _, item := range r.Items {
+		if item.Done() {
+			fmt.Fprintf(w, "[x] %s: %s\n", item.Tag, strings.Join(item.Images, ", "))
+		} else {
+			fmt.Fprintf(w, "[ ] %s: MISSING\n", item.Tag)
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes one line per required tag with its status and images
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"tag", "status", "count", "images"})
+	for _, item := range r.Items {
+		status := "missing"
+		if item.Done() {
+			status = "present"
+		}
+		cw.Write([]string{item.Tag, status, strconv.Itoa(len(item.Images)), strings.Join(item.Images, "; ")})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteFile writes the report to path, as CSV for a .csv extension and as text otherwise
+func (r *Report) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		err = r.WriteCSV(f)
+	} else {
+		err = r.WriteText(f)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,139 @@
+package tagger
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/metadata"
+)
+
+func TestChecklist(t *testing.T) {
+	dir := makeDir(t, "IMG_1 FRONT.jpg", "IMG_2 FURNACE DATA.jpg", "IMG_3 FRONT 2.jpg", "IMG_4.jpg")
+	s, err := NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"FRONT", "REAR", "FURNACE", "FURNACE DATA"})
+
+	r := s.Checklist("Audit", []string{"FRONT", "REAR", "FURNACE DATA", "FRONT"})
+	want := []ChecklistItem{
+		{Tag: "FRONT", Images: []string{"IMG_1 FRONT.jpg", "IMG_3 FRONT 2.jpg"}},
+		{Tag: "REAR"},
+		{Tag: "FURNACE DATA", Images: []string{"IMG_2 FURNACE DATA.jpg"}},
+	}
+	if !reflect.DeepEqual(r.Items, want) {
+		t.Errorf("Checklist() = %+v, want %+v", r.Items, want)
+	}
+	if r.Complete() || !reflect.DeepEqual(r.Missing(), []string{"REAR"}) {
+		t.Errorf("Missing() = %v, want [REAR]", r.Missing())
+	}
+
+	var text bytes.Buffer
+	if err := r.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), "[ ] REAR: MISSING") || !strings.Contains(text.String(), "1 of 3 required shots missing") {
+		t.Errorf("WriteText() =\n%s", text.String())
+	}
+	var csv bytes.Buffer
+	if err := r.WriteCSV(&csv); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(csv.String(), "REAR,missing,0,") {
+		t.Errorf("WriteCSV() =\n%s", csv.String())
+	}
+}
+
+func TestChecklistSidecar(t *testing.T) {
+	dir := makeDir(t, "IMG_1.jpg")
+	if err := metadata.WriteSidecar(filepath.Join(dir, "IMG_1.jpg"), []string{"REAR"}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"REAR"})
+	s.SetTagStorage(StorageSidecar)
+	if r := s.Checklist("Audit", []string{"REAR"}); !r.Complete() {
+		t.Errorf("Checklist() with sidecar tags = %+v, want complete", r.Items)
+	}
+}
+
+func TestUnreadableSidecar(t *testing.T) {
+	dir := makeDir(t, "IMG_1 ATTIC.jpg", "IMG_1 ATTIC.jpg.xmp")
+	if err := ioutil.WriteFile(filepath.Join(dir, "IMG_1 ATTIC.jpg.xmp"), []byte("<x:xmpmeta"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"ATTIC"})
+	s.SetTagStorage(StorageBoth)
+	s.Errors()
+
+	// the tags in the name are still read
+	if got := s.ImageTags("IMG_1 ATTIC.jpg"); !reflect.DeepEqual(got, []string{"ATTIC"}) {
+		t.Errorf("ImageTags() = %v", got)
+	}
+	if errs := s.Errors(); len(errs) != 1 {
+		t.Errorf("Errors() = %v, want the unreadable sidecar", errs)
+	}
+}
+
+func TestImageTagsCache(t *testing.T) {
+	dir := makeDir(t, "IMG_1.jpg", "IMG_2.jpg")
+	s, err := NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"ATTIC", "FRONT"})
+	s.SetTagStorage(StorageSidecar)
+	if tags := s.ImageTags("IMG_2.jpg"); len(tags) != 0 {
+		t.Fatalf("ImageTags() = %v", tags)
+	}
+
+	// a sidecar written behind the session's back shows after Refresh
+	if err := metadata.WriteSidecar(filepath.Join(dir, "IMG_2.jpg"), []string{"FRONT"}); err != nil {
+		t.Fatal(err)
+	}
+	if tags := s.ImageTags("IMG_2.jpg"); len(tags) != 0 {
+		t.Errorf("ImageTags() = %v, want the cached tags", tags)
+	}
+	version := s.Version()
+	if err := s.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if tags := s.ImageTags("IMG_2.jpg"); !reflect.DeepEqual(tags, []string{"FRONT"}) {
+		t.Errorf("ImageTags() after Refresh = %v", tags)
+	}
+	if s.Version() == version {
+		t.Error("Refresh didn't change the version")
+	}
+
+	// tagging through the session updates the cache
+	version = s.Version()
+	s.ToggleTag("ATTIC")
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if tags := s.ImageTags("IMG_1.jpg"); !reflect.DeepEqual(tags, []string{"ATTIC"}) {
+		t.Errorf("ImageTags() after Commit = %v", tags)
+	}
+	if s.Version() == version {
+		t.Error("Commit didn't change the version")
+	}
+
+	// navigating doesn't
+	version = s.Version()
+	s.Next()
+	s.Prev()
+	if s.Version() != version {
+		t.Error("navigation changed the version")
+	}
+}
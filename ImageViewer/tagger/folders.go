@@ -0,0 +1,60 @@
+package tagger
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Folder is a folder of a recursive session with the number of its images that have tags
+type Folder struct {
+	// Path is relative to the session directory, "." for the directory itself
+	Path     string
+	Tagged   int
+	Untagged int
+}
+
+// Folders returns the folders of the session that contain images, together with
+// their parent folders, sorted by path. The counts include only the images directly
+// in a folder, not those of its subfolders.
+func (s *Session) Folders() []Folder {
+	index := map[string]int{}
+	folders := []Folder{}
+	add := func(path string) int {
+		if i, ok := index[path]; ok {
+			return i
+		}
+		index[path] = len(folders)
+		folders = append(folders, Folder{Path: path})
+		return len(folders) - 1
+	}
+	add(".")
+	for _, name := range s.images {
+		dir := filepath.Dir(name)
+		for parent := dir; parent != "."; parent = filepath.Dir(parent) {
+			add(parent)
+		}
+		i := add(dir)
+		if len(s.ImageTags(name)) > 0 {
+			folders[i].Tagged++
+		} else {
+			folders[i].Untagged++
+		}
+	}
+	sort.Slice(folders, func(i, j int) bool {
+		return folderLess(folders[i].Path, folders[j].Path)
+	})
+	return folders
+}
+
+// SeekFolder selects the first image in the folder with the path relative to Dir,
+// or in one of its subfolders. It returns false if there is no such image.
+func (s *Session) SeekFolder(path string) bool {
+	path = filepath.Clean(path)
+	for _, name := range s.images {
+		if path == "." || filepath.Dir(name) == path || strings.HasPrefix(name, path+string(filepath.Separator)) {
+			return s.Seek(name)
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package tagger
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// makeTree creates the given files, which may be in subfolders, in a new temporary directory
+func makeTree(t *testing.T, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, f := range files {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(f)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		makeFile(t, dir, f)
+	}
+	return dir
+}
+
+func TestOpenFolder(t *testing.T) {
+	dir := makeTree(t,
+		"b.jpg",
+		filepath.Join("visit2", "c.jpg"),
+		filepath.Join("visit1", "unit b", "e.png"),
+		filepath.Join("visit1", "d.jpg"),
+		filepath.Join("visit1", "notes.txt"),
+		filepath.Join(TrashDirName, "x.jpg"),
+		"a.jpg",
+	)
+	s, err := OpenFolder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"a.jpg",
+		"b.jpg",
+		filepath.Join("visit1", "d.jpg"),
+		filepath.Join("visit1", "unit b", "e.png"),
+		filepath.Join("visit2", "c.jpg"),
+	}
+	if !reflect.DeepEqual(s.Images(), want) {
+		t.Fatalf("Images() = %v, want %v", s.Images(), want)
+	}
+
+	// Next crosses into the subfolders
+	s.Next()
+	s.Next()
+	if s.Current() != want[2] || s.CurrentDir() != filepath.Join(dir, "visit1") || s.Preview() != "d.jpg" {
+		t.Errorf("current = %q in %q, preview %q", s.Current(), s.CurrentDir(), s.Preview())
+	}
+
+	if !s.SeekFolder("visit2") || s.Current() != want[4] {
+		t.Errorf("SeekFolder(visit2) selected %q", s.Current())
+	}
+	if !s.SeekFolder("visit1") || s.Current() != want[2] {
+		t.Errorf("SeekFolder(visit1) selected %q", s.Current())
+	}
+	if s.SeekFolder("visit3") {
+		t.Error("SeekFolder(visit3) = true for a folder without images")
+	}
+}
+
+func TestRecursiveRenameAndDelete(t *testing.T) {
+	dir := makeTree(t, "a.jpg", filepath.Join("visit1", "IMG_1.jpg"), filepath.Join("visit1", "IMG_2.jpg"))
+	s, err := OpenFolder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"FRONT", "REAR"})
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "journal.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetJournal(j)
+	s.Seek(filepath.Join("visit1", "IMG_1.jpg"))
+
+	if got := s.ToggleTag("FRONT"); got != "IMG_1 FRONT.jpg" {
+		t.Fatalf("ToggleTag = %q", got)
+	}
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Current() != filepath.Join("visit1", "IMG_1 FRONT.jpg") {
+		t.Errorf("Current() = %q after rename", s.Current())
+	}
+	if got := listDir(t, filepath.Join(dir, "visit1")); !reflect.DeepEqual(got, []string{"IMG_1 FRONT.jpg", "IMG_2.jpg"}) {
+		t.Errorf("visit1 = %v", got)
+	}
+
+	s.Next()
+	if err := s.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "visit1", TrashDirName)); err != nil {
+		t.Errorf("deleted image isn't in the trash of its folder: %v", err)
+	}
+
+	folders := s.Folders()
+	want := []Folder{{Path: ".", Untagged: 1}, {Path: "visit1", Tagged: 1}}
+	if !reflect.DeepEqual(folders, want) {
+		t.Errorf("Folders() = %+v, want %+v", folders, want)
+	}
+}
+
+func TestFolderOrder(t *testing.T) {
+	dir := makeTree(t,
+		filepath.Join("visit1 a", "c.jpg"),
+		filepath.Join("visit1", "unit b", "b.jpg"),
+		filepath.Join("visit1", "a.jpg"),
+		filepath.Join("visit10", "d.jpg"),
+		filepath.Join("visit2", "e.jpg"),
+	)
+	s, err := OpenFolder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join("visit1", "a.jpg"),
+		filepath.Join("visit1", "unit b", "b.jpg"),
+		filepath.Join("visit1 a", "c.jpg"),
+		filepath.Join("visit2", "e.jpg"),
+		filepath.Join("visit10", "d.jpg"),
+	}
+	if got := s.Images(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Images() = %v, want %v", got, want)
+	}
+	paths := []string{}
+	for _, f := range s.Folders() {
+		paths = append(paths, f.Path)
+	}
+	wantPaths := []string{".", "visit1", filepath.Join("visit1", "unit b"), "visit1 a", "visit2", "visit10"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("Folders() = %v, want %v", paths, wantPaths)
+	}
+}
+
+func TestLocate(t *testing.T) {
+	dir := makeTree(t, filepath.Join("visit1", "a.jpg"))
+	s, err := OpenFolder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := s.Locate(filepath.Join(dir, "visit1"), "a.jpg"); !ok || got != filepath.Join("visit1", "a.jpg") {
+		t.Errorf("Locate(visit1) = %q, %v", got, ok)
+	}
+	if _, ok := s.Locate(filepath.Dir(dir), "a.jpg"); ok {
+		t.Error("Locate accepted the parent folder")
+	}
+}
@@ -0,0 +1,308 @@
+package tagger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// maxJournalLength is the number of operations kept in the journal
+const maxJournalLength = 1000
+
+// ErrNothingToUndo is returned by Undo when the stack is empty
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// ErrNothingToRedo is returned by Redo when the stack is empty
+var ErrNothingToRedo = errors.New("nothing to redo")
+
+// OpKind is the kind of a file operation
+type OpKind string
+
+const (
+	// OpRename renames From to To within Dir
+	OpRename OpKind = "rename"
+	// OpDelete moves From out of Dir into the trash file To
+	OpDelete OpKind = "delete"
+)
+
+// Operation is a file operation recorded in the journal
+type Operation struct {
+	Time time.Time
+	Kind OpKind
+	Dir  string
+	From string
+	To   string
+}
+
+func (op Operation) String() string {
+	switch op.Kind {
+	case OpRename:
+		return fmt.Sprintf("Renamed %s to %s", op.From, op.To)
+	case OpDelete:
+		return fmt.Sprintf("Deleted %s", op.From)
+	}
+	return fmt.Sprintf("%s %s", op.Kind, op.From)
+}
+
+// Journal records renames and deletes on disk so they can be undone and redone,
+// also after restarting the application.
+type Journal struct {
+	path string
+
+	// Done is the history of operations, the last one is undone first
+	Done []Operation
+	// Undone are the undone operations, the last one is redone first
+	Undone []Operation
+
+	// errs are the failed saves, see Errors
+	errs errorList
+}
+
+// OpenJournal loads the journal stored at path. A missing file gives an empty journal.
+func OpenJournal(path string) (*Journal, error) {
+	j := &Journal{path: path}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return j, err
+	}
+	if err := json.Unmarshal(data, j); err != nil {
+		return j, fmt.Errorf("corrupt journal %s: %v", path, err)
+	}
+	return j, nil
+}
+
+// Rename renames from to to within dir and records the operation.
+// It only fails if the file wasn't renamed, see record.
+func (j *Journal) Rename(dir, from, to string) error {
+	if err := moveWithSidecar(filepath.Join(dir, from), filepath.Join(dir, to)); err != nil {
+		return err
+	}
+	j.record(Operation{Time: time.Now(), Kind: OpRename, Dir: dir, From: from, To: to})
+	return nil
+}
+
+// Delete moves name out of dir into the trash of dir and records the operation.
+// It only fails if the file wasn't moved, see record.
+func (j *Journal) Delete(dir, name string) error {
+	path, err := MoveToTrash(dir, name)
+	if err != nil {
+		return err
+	}
+	j.record(Operation{Time: time.Now(), Kind: OpDelete, Dir: dir, From: name, To: path})
+	return nil
+}
+
+// Restore moves item back into its folder like TrashItem.Restore and returns the
+// restored file name. The delete of item is dropped from the history, it is undone already.
+func (j *Journal) Restore(item TrashItem) (string, error) {
+	name, err := item.Restore()
+	if err != nil {
+		return "", err
+	}
+	j.forget(item.Path)
+	return name, nil
+}
+
+// Remove deletes item permanently like TrashItem.Remove. The delete of item is
+// dropped from the history, it can't be undone anymore.
+func (j *Journal) Remove(item TrashItem) error {
+	if err := item.Remove(); err != nil {
+		return err
+	}
+	j.forget(item.Path)
+	return nil
+}
+
+// forget drops the deletes that moved a file to the trash path
+func (j *Journal) forget(path string) {
+	keep := func(ops []Operation) []Operation {
+		kept := []Operation{}
+		for _, op := range ops {
+			if op.Kind != OpDelete || op.To != path {
+				kept = append(kept, op)
+			}
+		}
+		return kept
+	}
+	j.Done, j.Undone = keep(j.Done), keep(j.Undone)
+	j.persist()
+}
+
+// Undo reverts the last operation and returns it
+func (j *Journal) Undo() (Operation, error) {
+	if len(j.Done) == 0 {
+		return Operation{}, ErrNothingToUndo
+	}
+	op := j.Done[len(j.Done)-1]
+	if err := op.revert(); err != nil {
+		return op, err
+	}
+	j.Done = j.Done[:len(j.Done)-1]
+	j.Undone = append(j.Undone, op)
+	j.persist()
+	return op, nil
+}
+
+// Redo applies the last undone operation again and returns it
+func (j *Journal) Redo() (Operation, error) {
+	if len(j.Undone) == 0 {
+		return Operation{}, ErrNothingToRedo
+	}
+	op := j.Undone[len(j.Undone)-1]
+	if err := op.apply(); err != nil {
+		return op, err
+	}
+	j.Undone = j.Undone[:len(j.Undone)-1]
+	j.Done = append(j.Done, op)
+	j.persist()
+	return op, nil
+}
+
+// History returns the done operations in dir, oldest first
+func (j *Journal) History(dir string) []Operation {
+	ops := []Operation{}
+	for _, op := range j.Done {
+		if op.Dir == dir {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+// OriginalName follows the recorded renames of name in dir back to its first name
+func (j *Journal) OriginalName(dir, name string) string {
+	for i := len(j.Done) - 1; i >= 0; i-- {
+		op := j.Done[i]
+		if op.Kind == OpRename && op.Dir == dir && op.To == name {
+			name = op.From
+		}
+	}
+	return name
+}
+
+// record adds op, which already happened on disk, to the history
+func (j *Journal) record(op Operation) {
+	j.Done = append(j.Done, op)
+	// a new operation invalidates the redo stack. Files deleted by dropped
+	// operations stay in the trash until PurgeTrash removes them by age.
+	j.Undone = nil
+	if len(j.Done) > maxJournalLength {
+		j.Done = j.Done[len(j.Done)-maxJournalLength:]
+	}
+	j.persist()
+}
+
+// persist saves the journal after the files changed. A failure can't make the change
+// undone, so it is collected for Errors and the operations stay in memory, to be undone
+// until the application quits and saved with the next operation.
+func (j *Journal) persist() {
+	if err := j.save(); err != nil {
+		j.errs.add(fmt.Errorf("saving the journal %s: %v", j.path, err))
+	}
+}
+
+// Errors returns the failed saves of the journal since the last call
+func (j *Journal) Errors() []error {
+	return j.errs.take()
+}
+
+func (j *Journal) save() error {
+	if err := os.MkdirAll(filepath.Dir(j.path), os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write to a temporary file first so a crash can't leave a truncated journal
+	tmp := j.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+func (op Operation) apply() error {
+	switch op.Kind {
+	case OpRename:
+		return renameNoReplace(filepath.Join(op.Dir, op.From), filepath.Join(op.Dir, op.To))
+	case OpDelete:
+		if err := os.MkdirAll(filepath.Dir(op.To), os.ModePerm); err != nil {
+			return err
+		}
+		return moveWithSidecar(filepath.Join(op.Dir, op.From), op.To)
+	}
+	return fmt.Errorf("unknown operation %q", op.Kind)
+}
+
+func (op Operation) revert() error {
+	switch op.Kind {
+	case OpRename:
+		return renameNoReplace(filepath.Join(op.Dir, op.To), filepath.Join(op.Dir, op.From))
+	case OpDelete:
+		if exists(filepath.Join(op.Dir, op.From)) {
+			return fmt.Errorf("can't restore %s, the name is taken", op.From)
+		}
+		if !exists(op.To) {
+			return fmt.Errorf("can't restore %s, it was removed from the trash", op.From)
+		}
+		if err := moveWithSidecar(op.To, filepath.Join(op.Dir, op.From)); err != nil {
+			return err
+		}
+		removeEmptyTrash(op.Dir)
+		return nil
+	}
+	return fmt.Errorf("unknown operation %q", op.Kind)
+}
+
+// renameNoReplace renames like moveWithSidecar but fails if newpath exists
+func renameNoReplace(oldpath, newpath string) error {
+	if exists(newpath) {
+		return fmt.Errorf("can't rename to %s, the name is taken", filepath.Base(newpath))
+	}
+	return moveWithSidecar(oldpath, newpath)
+}
+
+// moveFile renames oldpath to newpath, copying the file if they are on different devices
+func moveFile(oldpath, newpath string) error {
+	err := os.Rename(oldpath, newpath)
+	if !crossDevice(err) {
+		return err
+	}
+
+	src, err := os.Open(oldpath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(newpath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(newpath)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(newpath)
+		return err
+	}
+	src.Close()
+	return os.Remove(oldpath)
+}
+
+// crossDevice reports whether err is the error of a rename to another file system
+func crossDevice(err error) bool {
+	var linkErr *os.LinkError
+	return errors.As(err, &linkErr) && linkErr.Err == syscall.EXDEV
+}
@@ -0,0 +1,200 @@
+package tagger
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestJournalUndoRedo(t *testing.T) {
+	tests := []struct {
+		name       string
+		do         func(s *Session) error
+		wantDone   []string
+		wantUndone []string
+	}{
+		{
+			name:       "rename",
+			do:         func(s *Session) error { return s.Rename("a FRONT.jpg") },
+			wantDone:   []string{"a FRONT.jpg", "b.jpg"},
+			wantUndone: []string{"a.jpg", "b.jpg"},
+		},
+		{
+			name:       "delete",
+			do:         func(s *Session) error { return s.Delete() },
+			wantDone:   []string{TrashDirName, "b.jpg"},
+			wantUndone: []string{"a.jpg", "b.jpg"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := makeDir(t, "a.jpg", "b.jpg")
+			journalPath := filepath.Join(t.TempDir(), "journal.json")
+			j, err := OpenJournal(journalPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := Open(filepath.Join(dir, "a.jpg"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.SetJournal(j)
+
+			if err := tt.do(s); err != nil {
+				t.Fatal(err)
+			}
+			if got := listDir(t, dir); !reflect.DeepEqual(got, tt.wantDone) {
+				t.Errorf("after operation files = %v, want %v", got, tt.wantDone)
+			}
+
+			// undo from a journal loaded again, as after a restart
+			j, err = OpenJournal(journalPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(j.History(dir)) != 1 {
+				t.Fatalf("History() = %v, want one operation", j.History(dir))
+			}
+			if _, err := j.Undo(); err != nil {
+				t.Fatal(err)
+			}
+			if got := listDir(t, dir); !reflect.DeepEqual(got, tt.wantUndone) {
+				t.Errorf("after undo files = %v, want %v", got, tt.wantUndone)
+			}
+			if _, err := j.Undo(); err != ErrNothingToUndo {
+				t.Errorf("second Undo() = %v, want ErrNothingToUndo", err)
+			}
+
+			if _, err := j.Redo(); err != nil {
+				t.Fatal(err)
+			}
+			if got := listDir(t, dir); !reflect.DeepEqual(got, tt.wantDone) {
+				t.Errorf("after redo files = %v, want %v", got, tt.wantDone)
+			}
+			if _, err := j.Redo(); err != ErrNothingToRedo {
+				t.Errorf("second Redo() = %v, want ErrNothingToRedo", err)
+			}
+		})
+	}
+}
+
+func TestJournalUndoRefusesTakenName(t *testing.T) {
+	dir := makeDir(t, "a.jpg")
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "journal.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Rename(dir, "a.jpg", "b.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	// another file took the old name in the meantime
+	makeFile(t, dir, "a.jpg")
+	if _, err := j.Undo(); err == nil {
+		t.Error("Undo() overwrote an existing file")
+	}
+	if len(j.Done) != 1 {
+		t.Errorf("failed undo changed the journal: %v", j.Done)
+	}
+}
+
+func TestJournalNewOperationClearsRedo(t *testing.T) {
+	dir := makeDir(t, "a.jpg")
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "journal.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Rename(dir, "a.jpg", "b.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Rename(dir, "a.jpg", "c.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if len(j.Undone) != 0 {
+		t.Errorf("Undone = %v, want empty", j.Undone)
+	}
+}
+
+func TestJournalSaveFails(t *testing.T) {
+	dir := makeDir(t, "a.jpg", "b.jpg")
+	// the journal can't be written below a file
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	j := &Journal{path: filepath.Join(blocker, "journal.json")}
+	s, err := Open(filepath.Join(dir, "a.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetJournal(j)
+
+	if err := s.Rename("a FRONT.jpg"); err != nil {
+		t.Fatalf("Rename() = %v, the file was renamed", err)
+	}
+	if err := s.Delete(); err != nil {
+		t.Fatalf("Delete() = %v, the file was moved", err)
+	}
+	if got := s.Images(); !reflect.DeepEqual(got, []string{"b.jpg"}) {
+		t.Errorf("images = %v", got)
+	}
+	if got := listDir(t, dir); !reflect.DeepEqual(got, []string{TrashDirName, "b.jpg"}) {
+		t.Errorf("files = %v", got)
+	}
+	if errs := j.Errors(); len(errs) != 2 {
+		t.Errorf("Errors() = %v, want a failed save for each operation", errs)
+	}
+	if errs := j.Errors(); len(errs) != 0 {
+		t.Errorf("Errors() again = %v", errs)
+	}
+
+	// the operations can still be undone until the application quits
+	for range []int{1, 2} {
+		if _, err := j.Undo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := listDir(t, dir); !reflect.DeepEqual(got, []string{"a.jpg", "b.jpg"}) {
+		t.Errorf("files after undo = %v", got)
+	}
+}
+
+func TestJournalRestoreFromTrash(t *testing.T) {
+	dir := makeDir(t, "a.jpg", "b.jpg")
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "journal.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		if err := j.Delete(dir, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	items, err := ListTrash(dir)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("ListTrash() = %v, %v", items, err)
+	}
+
+	// restoring a.jpg in the trash view leaves only the delete of b.jpg to undo
+	for _, item := range items {
+		if item.Name == "a.jpg" {
+			if _, err := j.Restore(item); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := j.Remove(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(j.History(dir)) != 0 {
+		t.Errorf("History() = %v, want the restored and removed deletes dropped", j.History(dir))
+	}
+	if _, err := j.Undo(); err != ErrNothingToUndo {
+		t.Errorf("Undo() = %v, want ErrNothingToUndo", err)
+	}
+	if got := listDir(t, dir); !reflect.DeepEqual(got, []string{"a.jpg"}) {
+		t.Errorf("files = %v", got)
+	}
+}
@@ -0,0 +1,210 @@
+package tagger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Tag is a tag button of a profile
+type Tag struct {
+	Name   string `json:"name" yaml:"name"`
+	Hotkey string `json:"hotkey,omitempty" yaml:"hotkey,omitempty"`
+	// Group is the section the button is shown in, e.g. "Equipment". Buttons without a group come first.
+	Group string `json:"group,omitempty" yaml:"group,omitempty"`
+}
+
+// Profile is a named set of tag buttons for one kind of job, e.g. energy audits or solar site surveys
+type Profile struct {
+	Name    string `json:"name" yaml:"name"`
+	Buttons []Tag  `json:"buttons" yaml:"buttons"`
+	// Tags and Hotkeys are the fixed size button slots of older configs, see Migrate
+	Tags    []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Hotkeys []string `json:"hotkeys,omitempty" yaml:"hotkeys,omitempty"`
+	// Folders select the profile automatically when an image in them is opened.
+	// They are folder paths, which include their subfolders, or patterns like "/jobs/solar-*".
+	Folders []string `json:"folders,omitempty" yaml:"folders,omitempty"`
+	// Required are the tags every job folder needs a photo of, e.g. FRONT or FURNACE DATA
+	Required []string `json:"required,omitempty" yaml:"required,omitempty"`
+}
+
+// Migrate turns the button slots of older configs into Buttons, dropping empty slots
+func (p *Profile) Migrate() {
+	if len(p.Buttons) == 0 {
+		for i, name := range p.Tags {
+			if strings.TrimSpace(name) == "" {
+				continue
+			}
+			t := Tag{Name: name}
+			if i < len(p.Hotkeys) {
+				t.Hotkey = p.Hotkeys[i]
+			}
+			p.Buttons = append(p.Buttons, t)
+		}
+	}
+	p.Tags, p.Hotkeys = nil, nil
+}
+
+// Vocabulary returns the tag names of the buttons, in button order
+func (p *Profile) Vocabulary() []string {
+	names := []string{}
+	for _, t := range p.Buttons {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// Groups returns the groups of the buttons in the order they first appear, "" first if used
+func (p *Profile) Groups() []string {
+	groups := []string{}
+	seen := map[string]bool{}
+	for _, t := range p.Buttons {
+		if !seen[t.Group] {
+			seen[t.Group] = true
+			if t.Group != "" {
+				groups = append(groups, t.Group)
+			}
+		}
+	}
+	if seen[""] {
+		groups = append([]string{""}, groups...)
+	}
+	return groups
+}
+
+// IsRequired reports whether tag is one of the required tags
+func (p *Profile) IsRequired(tag string) bool {
+	for _, r := range p.Required {
+		if r == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// FindProfile returns the index of the profile with the given name, or -1
+func FindProfile(profiles []Profile, name string) int {
+	for i, p := range profiles {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// MatchProfile returns the index of the profile whose folders contain dir, or -1.
+// If several profiles match, the one with the longest folder wins.
+func MatchProfile(profiles []Profile, dir string) int {
+	best, bestLen := -1, -1
+	for i, p := range profiles {
+		for _, folder := range p.Folders {
+			if matchFolder(folder, dir) && len(folder) > bestLen {
+				best, bestLen = i, len(folder)
+			}
+		}
+	}
+	return best
+}
+
+func matchFolder(folder, dir string) bool {
+	folder = filepath.Clean(folder)
+	dir = filepath.Clean(dir)
+	if ok, _ := filepath.Match(folder, dir); ok {
+		return true
+	}
+	return dir == folder || strings.HasPrefix(dir, folder+string(filepath.Separator))
+}
+
+// ValidateProfiles checks that every profile has a unique, non-empty name and no tag twice
+func ValidateProfiles(profiles []Profile) error {
+	seen := map[string]bool{}
+	for _, p := range profiles {
+		name := strings.TrimSpace(p.Name)
+		if name == "" {
+			return fmt.Errorf("profile without a name")
+		}
+		if seen[name] {
+			return fmt.Errorf("profile %q exists twice", name)
+		}
+		seen[name] = true
+		if err := ValidateButtons(p.Buttons); err != nil {
+			return fmt.Errorf("profile %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// ValidateButtons checks that every button has a tag and no tag is used twice
+func ValidateButtons(buttons []Tag) error {
+	seen := map[string]bool{}
+	for i, t := range buttons {
+		if strings.TrimSpace(t.Name) == "" {
+			return fmt.Errorf("tag button %d has no tag", i+1)
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("tag %q exists twice", t.Name)
+		}
+		seen[t.Name] = true
+	}
+	return nil
+}
+
+// MergeProfiles returns profiles with imported added. Imported profiles replace existing profiles of the same name.
+func MergeProfiles(profiles, imported []Profile) []Profile {
+	merged := append([]Profile{}, profiles...)
+	for _, p := range imported {
+		if i := FindProfile(merged, p.Name); i >= 0 {
+			merged[i] = p
+		} else {
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+// ReadProfiles reads a list of profiles from a JSON file, or from a YAML file for any other extension
+func ReadProfiles(path string) ([]Profile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	profiles := []Profile{}
+	if isJSON(path) {
+		err = json.Unmarshal(data, &profiles)
+	} else {
+		err = yaml.Unmarshal(data, &profiles)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile file %s: %v", path, err)
+	}
+	for i := range profiles {
+		profiles[i].Migrate()
+	}
+	if err := ValidateProfiles(profiles); err != nil {
+		return nil, fmt.Errorf("invalid profile file %s: %v", path, err)
+	}
+	return profiles, nil
+}
+
+// WriteProfiles writes profiles to a JSON file, or to a YAML file for any other extension
+func WriteProfiles(path string, profiles []Profile) error {
+	var data []byte
+	var err error
+	if isJSON(path) {
+		data, err = json.MarshalIndent(profiles, "", "  ")
+	} else {
+		data, err = yaml.Marshal(profiles)
+	}
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func isJSON(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".json"
+}
@@ -0,0 +1,124 @@
+package tagger
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatchProfile(t *testing.T) {
+	profiles := []Profile{
+		{Name: "Audit", Folders: []string{"/jobs"}},
+		{Name: "Solar", Folders: []string{"/jobs/solar-*"}},
+		{Name: "Insulation", Folders: []string{"/jobs/insulation"}},
+	}
+	tests := []struct {
+		dir  string
+		want int
+	}{
+		{"/jobs/12 Main St", 0},
+		{"/jobs/solar-12 Main St", 1},
+		{"/jobs/insulation/attic", 2},
+		{"/home/pictures", -1},
+		{"/jobsite", -1},
+	}
+	for _, tt := range tests {
+		if got := MatchProfile(profiles, tt.dir); got != tt.want {
+			t.Errorf("MatchProfile(%q) = %d, want %d", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestProfilesRoundTrip(t *testing.T) {
+	profiles := []Profile{
+		{Name: "Audit", Buttons: []Tag{{Name: "FURNACE", Hotkey: "1", Group: "Equipment"}, {Name: "FRONT"}}},
+		{Name: "Solar", Buttons: []Tag{{Name: "ROOF"}, {Name: "PANEL"}}, Folders: []string{"/jobs/solar-*"}},
+	}
+	for _, name := range []string{"profiles.yaml", "profiles.json"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := WriteProfiles(path, profiles); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadProfiles(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, profiles) {
+			t.Errorf("%s: ReadProfiles() = %+v, want %+v", name, got, profiles)
+		}
+	}
+}
+
+func TestReadProfilesInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.yaml")
+	if err := WriteProfiles(path, []Profile{{Name: "A"}, {Name: "A"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadProfiles(path); err == nil {
+		t.Error("ReadProfiles() with duplicate names succeeded")
+	}
+	if err := WriteProfiles(path, []Profile{{Name: "A", Buttons: []Tag{{Name: "AC"}, {Name: "AC"}}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadProfiles(path); err == nil {
+		t.Error("ReadProfiles() with duplicate tags succeeded")
+	}
+}
+
+func TestMergeProfiles(t *testing.T) {
+	profiles := []Profile{{Name: "Audit", Buttons: []Tag{{Name: "AC"}}}, {Name: "Solar"}}
+	merged := MergeProfiles(profiles, []Profile{{Name: "Audit", Buttons: []Tag{{Name: "TANK"}}}, {Name: "Insulation"}})
+	names := []string{}
+	for _, p := range merged {
+		names = append(names, p.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"Audit", "Solar", "Insulation"}) {
+		t.Errorf("merged names = %v", names)
+	}
+	if !reflect.DeepEqual(merged[0].Vocabulary(), []string{"TANK"}) {
+		t.Errorf("merged Audit tags = %v, want [TANK]", merged[0].Vocabulary())
+	}
+	if !reflect.DeepEqual(profiles[0].Vocabulary(), []string{"AC"}) {
+		t.Error("MergeProfiles changed its input")
+	}
+}
+
+func TestProfileMigrate(t *testing.T) {
+	p := Profile{
+		Name:    "Default",
+		Tags:    []string{"AC", "", "ATTIC", ""},
+		Hotkeys: []string{"1", "2"},
+	}
+	p.Migrate()
+	want := []Tag{{Name: "AC", Hotkey: "1"}, {Name: "ATTIC"}}
+	if !reflect.DeepEqual(p.Buttons, want) || p.Tags != nil || p.Hotkeys != nil {
+		t.Errorf("Migrate() = %+v, want buttons %+v", p, want)
+	}
+
+	// a profile file from before buttons existed
+	path := filepath.Join(t.TempDir(), "profiles.yaml")
+	old := "- name: Audit\n  tags: [FURNACE, \"\", TANK]\n  hotkeys: [\"1\"]\n"
+	if err := ioutil.WriteFile(path, []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := ReadProfiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Tag{{Name: "FURNACE", Hotkey: "1"}, {Name: "TANK"}}; !reflect.DeepEqual(profiles[0].Buttons, want) {
+		t.Errorf("migrated buttons = %+v, want %+v", profiles[0].Buttons, want)
+	}
+}
+
+func TestProfileGroups(t *testing.T) {
+	p := Profile{Buttons: []Tag{
+		{Name: "FURNACE", Group: "Equipment"},
+		{Name: "FRONT"},
+		{Name: "ATTIC", Group: "Location"},
+		{Name: "AC", Group: "Equipment"},
+	}}
+	if got, want := p.Groups(), []string{"", "Equipment", "Location"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Groups() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,826 @@
+// Package tagger implements the tagging and renaming of images in a folder
+// without depending on the user interface.
+package tagger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/metadata"
+)
+
+// ErrNoImage is returned by operations that need a current image when the session is empty
+var ErrNoImage = errors.New("no image selected")
+
+// CollisionPolicy decides what happens when a rename target already exists
+type CollisionPolicy string
+
+const (
+	// CollisionSequence appends a counter to the name, e.g. "FURNACE DATA 2.jpg"
+	CollisionSequence CollisionPolicy = "sequence"
+	// CollisionPrompt returns an ExistsError so the caller can ask the user
+	CollisionPrompt CollisionPolicy = "prompt"
+	// CollisionRefuse returns an ExistsError
+	CollisionRefuse CollisionPolicy = "refuse"
+)
+
+// CollisionPolicies lists all policies in the order they are offered to the user
+var CollisionPolicies = []CollisionPolicy{CollisionSequence, CollisionPrompt, CollisionRefuse}
+
+// ParseCollisionPolicy returns the policy named s
+func ParseCollisionPolicy(s string) (CollisionPolicy, error) {
+	for _, p := range CollisionPolicies {
+		if string(p) == strings.ToLower(s) {
+			return p, nil
+		}
+	}
+	return CollisionSequence, fmt.Errorf("unknown collision policy %q", s)
+}
+
+// ExistsError is returned by Rename when the target exists and the policy doesn't allow numbering
+type ExistsError struct {
+	Name string
+	// Suggestion is the next free numbered name
+	Suggestion string
+}
+
+func (e *ExistsError) Error() string {
+	return fmt.Sprintf("%s already exists", e.Name)
+}
+
+// errorList collects the problems that don't stop an operation, like an unreadable
+// sidecar, until the caller asks for them
+type errorList struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (l *errorList) add(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errs = append(l.errs, err)
+}
+
+// take returns the collected errors and forgets them
+func (l *errorList) take() []error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	errs := l.errs
+	l.errs = nil
+	return errs
+}
+
+// imageExtensions are the file extensions the viewer can decode
+var imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif"}
+
+// IsImage reports whether the file name has a supported image extension
+func IsImage(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, v := range imageExtensions {
+		if ext == v {
+			return true
+		}
+	}
+	return false
+}
+
+// SequenceName returns name if it doesn't exist in dir, otherwise the first free
+// name with a counter appended to the stem, starting at 2.
+func SequenceName(dir, name string) string {
+	if !exists(filepath.Join(dir, name)) {
+		return name
+	}
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s %d%s", stem, n, ext)
+		if !exists(filepath.Join(dir, candidate)) {
+			return candidate
+		}
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// Session iterates over the images of one directory and renames them.
+// The current image has a pending name (the preview) that is written by Commit.
+type Session struct {
+	dir string
+	// images are the paths of the images relative to dir, which are plain
+	// file names unless the session is recursive
+	images    []string
+	recursive bool
+	order     SortOrder
+	// infos caches the sort keys of the images by their path relative to dir
+	infos   map[string]*fileInfo
+	index   int
+	preview string
+	policy  CollisionPolicy
+	journal *Journal
+
+	// vocabulary are the known tags, in the order they appear in names
+	vocabulary []string
+	template   *Template
+	vars       map[string]string
+
+	// date is the capture date of datePath, read on demand for {exif.date}
+	date     time.Time
+	datePath string
+
+	permanentDelete bool
+
+	storage TagStorage
+	// tags are the pending tags with StorageSidecar, stored are the tags read from the sidecar
+	tags   []string
+	stored []string
+
+	// embed writes the tags into the image metadata, embedded are the tags read from it
+	embed    bool
+	embedded []string
+
+	// reserved are the paths a batch plans to rename images to, which count as taken
+	reserved map[string]bool
+	// copying makes only the reserved paths count as taken, see PlanCopies
+	copying bool
+
+	// imageTags caches ImageTags by the path of the image relative to dir.
+	// version counts the changes of the images and their tags.
+	imageTags map[string][]string
+	version   int
+
+	// errs are the problems found while reading tags, see Errors
+	errs errorList
+}
+
+// NewSession creates a session over all images in dir, positioned on the first one
+func NewSession(dir string) (*Session, error) {
+	return newSession(dir, false)
+}
+
+// OpenFolder creates a session over all images in dir and its subfolders, positioned
+// on the first one. Images are ordered by folder, so Next and Prev cross folder boundaries.
+func OpenFolder(dir string) (*Session, error) {
+	return newSession(dir, true)
+}
+
+func newSession(dir string, recursive bool) (*Session, error) {
+	t, err := ParseTemplate(DefaultTemplate, nil)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{dir: dir, policy: CollisionSequence, template: t, storage: StorageRename, recursive: recursive, order: SortNatural}
+	if err := s.Refresh(); err != nil {
+		return nil, err
+	}
+	s.index = 0
+	s.resetPreview()
+	return s, nil
+}
+
+// Open creates a session over the directory of the image at path, positioned on that image
+func Open(path string) (*Session, error) {
+	s, err := NewSession(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	if !s.Seek(filepath.Base(path)) {
+		return nil, fmt.Errorf("%s is not a supported image", path)
+	}
+	return s, nil
+}
+
+// Dir returns the directory of the session
+func (s *Session) Dir() string {
+	return s.dir
+}
+
+// Recursive reports whether the session includes the images of subfolders
+func (s *Session) Recursive() bool {
+	return s.recursive
+}
+
+// Images returns the paths of all images relative to Dir, sorted by folder and the sort order
+func (s *Session) Images() []string {
+	return s.images
+}
+
+// Len returns the number of images
+func (s *Session) Len() int {
+	return len(s.images)
+}
+
+// Index returns the position of the current image in Images
+func (s *Session) Index() int {
+	return s.index
+}
+
+// Current returns the path of the current image relative to Dir, or "" if there is none.
+// It is the file name unless the session is recursive.
+func (s *Session) Current() string {
+	if s.index < 0 || s.index >= len(s.images) {
+		return ""
+	}
+	return s.images[s.index]
+}
+
+// CurrentPath returns the full path of the current image, or "" if there is none
+func (s *Session) CurrentPath() string {
+	if s.Current() == "" {
+		return ""
+	}
+	return filepath.Join(s.dir, s.Current())
+}
+
+// CurrentDir returns the folder of the current image, or Dir if there is none
+func (s *Session) CurrentDir() string {
+	return filepath.Join(s.dir, filepath.Dir(s.Current()))
+}
+
+// name returns the file name of the current image, or "" if there is none
+func (s *Session) name() string {
+	if s.Current() == "" {
+		return ""
+	}
+	return filepath.Base(s.Current())
+}
+
+// Locate returns the path relative to Dir of the file name in dir,
+// and false if dir isn't part of the session.
+func (s *Session) Locate(dir, name string) (string, bool) {
+	if filepath.Clean(dir) == filepath.Clean(s.dir) {
+		return name, true
+	}
+	if !s.recursive {
+		return "", false
+	}
+	rel, err := filepath.Rel(s.dir, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.Join(rel, name), true
+}
+
+// OriginalName returns the file name the image name, a path relative to Dir,
+// had before the renames recorded in the journal
+func (s *Session) OriginalName(name string) string {
+	base := filepath.Base(name)
+	if s.journal == nil {
+		return base
+	}
+	return s.journal.OriginalName(filepath.Join(s.dir, filepath.Dir(name)), base)
+}
+
+// Refresh reads the directory again. The current image stays selected if it still exists.
+// The tags of the images are read again too, they may have changed outside the session.
+func (s *Session) Refresh() error {
+	s.forgetAllTags()
+	return s.refresh()
+}
+
+// refresh reads the directory again like Refresh, keeping the cached tags
+func (s *Session) refresh() error {
+	s.version++
+	current := s.Current()
+
+	var images []string
+	var err error
+	if s.recursive {
+		images, err = walkImages(s.dir)
+	} else {
+		images, err = readImages(s.dir)
+	}
+	if err != nil {
+		return err
+	}
+	s.images = images
+	s.sortImages()
+
+	if i := s.find(current); i >= 0 {
+		s.index = i
+		return nil
+	}
+	if s.index >= len(s.images) {
+		s.index = len(s.images) - 1
+	}
+	if s.index < 0 && len(s.images) > 0 {
+		s.index = 0
+	}
+	s.resetPreview()
+	return nil
+}
+
+// Update reads the directory again like Refresh and returns the images that weren't
+// in the session before, as paths relative to Dir
+func (s *Session) Update() ([]string, error) {
+	known := map[string]bool{}
+	for _, name := range s.images {
+		known[name] = true
+	}
+	if err := s.Refresh(); err != nil {
+		return nil, err
+	}
+	added := []string{}
+	for _, name := range s.images {
+		if !known[name] {
+			added = append(added, name)
+		}
+	}
+	return added, nil
+}
+
+// readImages returns the names of the images in dir
+func readImages(dir string) ([]string, error) {
+	folder, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer folder.Close()
+	names, err := folder.Readdirnames(0)
+	if err != nil {
+		return nil, err
+	}
+
+	// filter image files
+	images := []string{}
+	for _, v := range names {
+		if IsImage(v) {
+			images = append(images, v)
+		}
+	}
+	return images, nil
+}
+
+// walkImages returns the paths relative to dir of the images in dir and its subfolders.
+// Hidden folders, like the trash, are skipped.
+func walkImages(dir string) ([]string, error) {
+	images := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			// an unreadable subfolder shouldn't hide the rest of the job
+			return nil
+		}
+		if info.IsDir() {
+			if path != dir && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if IsImage(info.Name()) {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			images = append(images, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// Seek selects the image with the given path relative to Dir. It returns false if there is no such image.
+func (s *Session) Seek(name string) bool {
+	i := s.find(name)
+	if i < 0 {
+		return false
+	}
+	s.index = i
+	s.resetPreview()
+	return true
+}
+
+func (s *Session) find(name string) int {
+	if name == "" {
+		return -1
+	}
+	for i, v := range s.images {
+		if v == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Next moves to the next image. It returns false if the current image is the last one.
+func (s *Session) Next() bool {
+	if s.index >= len(s.images)-1 {
+		return false
+	}
+	s.index++
+	s.resetPreview()
+	return true
+}
+
+// Prev moves to the previous image. It returns false if the current image is the first one.
+func (s *Session) Prev() bool {
+	if s.index <= 0 {
+		return false
+	}
+	s.index--
+	s.resetPreview()
+	return true
+}
+
+// Preview returns the pending file name of the current image
+func (s *Session) Preview() string {
+	return s.preview
+}
+
+// SetPreview sets the pending file name of the current image
+func (s *Session) SetPreview(name string) {
+	s.preview = name
+}
+
+// SetVocabulary sets the known tags. Their order is the order of tags in generated names.
+func (s *Session) SetVocabulary(tags []string) {
+	s.vocabulary = tags
+	s.forgetAllTags()
+}
+
+// SetTemplate sets the template generated names follow and the job variables it may use.
+// A pending preview is regenerated with the new template, keeping its stem and tags.
+func (s *Session) SetTemplate(t *Template, vars map[string]string) {
+	pending := s.preview != s.name()
+	stem, tags := s.parse(s.preview)
+	s.template = t
+	s.vars = vars
+	s.forgetAllTags()
+	if pending {
+		s.preview = s.render(stem, tags)
+		if s.policy == CollisionSequence {
+			s.preview, _ = s.Resolve(s.preview)
+		}
+	}
+}
+
+// Template returns the template generated names follow
+func (s *Session) Template() *Template {
+	return s.template
+}
+
+// Tags returns the pending tags: the tags of the preview, or with StorageSidecar
+// the tags that are written to the sidecar by Commit.
+func (s *Session) Tags() []string {
+	if !s.storage.inName() {
+		return append([]string{}, s.tags...)
+	}
+	_, tags := s.parse(s.preview)
+	return tags
+}
+
+// parse splits name into the original stem and its tags. Names that don't fit the
+// template take their stem from the journal, or from the name without its tags.
+func (s *Session) parse(name string) (string, []string) {
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	original, tags, ok := s.template.Match(stem, s.fields(nil), s.vocabulary)
+	if ok && s.template.has(tokenStem) {
+		return original, tags
+	}
+	if !ok {
+		original, tags = ParseTags(name, s.vocabulary)
+	}
+	if s.journal != nil && s.Current() != "" {
+		first := s.journal.OriginalName(s.CurrentDir(), s.name())
+		if first != s.name() {
+			original, _ = ParseTags(first, s.vocabulary)
+		}
+	}
+	return original, tags
+}
+
+// fields returns the values to render the current image with
+func (s *Session) fields(tags []string) Fields {
+	return Fields{
+		Tags:   tags,
+		Folder: filepath.Base(s.CurrentDir()),
+		Vars:   s.vars,
+		Date:   s.captureDate(),
+	}
+}
+
+// captureDate returns the EXIF capture date of the current image, or its modification time
+func (s *Session) captureDate() time.Time {
+	if s.datePath == s.CurrentPath() {
+		return s.date
+	}
+	s.datePath = s.CurrentPath()
+	s.date = time.Time{}
+	if s.datePath == "" {
+		return s.date
+	}
+	if e, err := metadata.ReadEXIFFile(s.datePath); err == nil {
+		s.date = e.Date()
+	}
+	if s.date.IsZero() {
+		if info, err := os.Stat(s.datePath); err == nil {
+			s.date = info.ModTime()
+		}
+	}
+	return s.date
+}
+
+// ToggleTag adds tag to the preview or removes it if it is already there,
+// and returns the new preview.
+func (s *Session) ToggleTag(tag string) string {
+	if tag == "" {
+		return s.preview
+	}
+	tags := s.Tags()
+	if HasTag(tags, tag) {
+		kept := []string{}
+		for _, t := range tags {
+			if t != tag {
+				kept = append(kept, t)
+			}
+		}
+		tags = kept
+	} else {
+		tags = append(tags, tag)
+	}
+	return s.SetTags(tags)
+}
+
+// SetTags regenerates the preview from the template with tags, and returns it.
+// With CollisionSequence the preview is already numbered if the name is taken.
+// With StorageSidecar the preview is left alone and the tags wait for Commit.
+func (s *Session) SetTags(tags []string) string {
+	if !s.storage.inName() {
+		s.tags = OrderTags(tags, s.vocabulary)
+		return s.preview
+	}
+	s.preview = s.Render(tags)
+	if s.policy == CollisionSequence {
+		s.preview, _ = s.Resolve(s.preview)
+	}
+	return s.preview
+}
+
+// Render returns the name the template gives the current image with tags.
+// {seq} gets the lowest number that isn't taken by another file.
+func (s *Session) Render(tags []string) string {
+	stem, _ := s.parse(s.preview)
+	return s.render(stem, tags)
+}
+
+func (s *Session) render(stem string, tags []string) string {
+	f := s.fields(OrderTags(tags, s.vocabulary))
+	f.Stem = stem
+	ext := filepath.Ext(s.Current())
+	if !s.template.HasSeq() {
+		return s.template.Render(f, ext)
+	}
+	for f.Seq = 1; ; f.Seq++ {
+		name := s.template.Render(f, ext)
+		if (name == s.name() && !s.copying) || !s.collides(name) {
+			return name
+		}
+	}
+}
+
+// CollisionPolicy returns the policy used by Rename
+func (s *Session) CollisionPolicy() CollisionPolicy {
+	return s.policy
+}
+
+// SetCollisionPolicy sets the policy used by Rename
+func (s *Session) SetCollisionPolicy(p CollisionPolicy) {
+	s.policy = p
+}
+
+// SetJournal records all following renames and deletes in j, so they can be undone
+func (s *Session) SetJournal(j *Journal) {
+	s.journal = j
+}
+
+// TagStorage returns where the tags are kept
+func (s *Session) TagStorage() TagStorage {
+	return s.storage
+}
+
+// SetTagStorage sets where the tags are kept and reads the tags of the current image again
+func (s *Session) SetTagStorage(t TagStorage) {
+	s.storage = t
+	s.forgetAllTags()
+	s.loadTags()
+}
+
+// SetEmbedTags makes Commit write the tags into the metadata of JPEG and PNG images too
+func (s *Session) SetEmbedTags(embed bool) {
+	s.embed = embed
+	s.loadTags()
+}
+
+// SetPermanentDelete makes Delete remove files for good instead of moving them to the trash
+func (s *Session) SetPermanentDelete(permanent bool) {
+	s.permanentDelete = permanent
+}
+
+// Resolve returns the name the current image would get when renamed to name,
+// and whether name collides with another file.
+func (s *Session) Resolve(name string) (string, bool) {
+	if name == s.name() || !s.collides(name) {
+		return name, false
+	}
+	if s.policy == CollisionSequence {
+		return s.sequenceName(name), true
+	}
+	return name, true
+}
+
+// sequenceName returns the first name that doesn't collide, like SequenceName
+func (s *Session) sequenceName(name string) string {
+	if !s.collides(name) {
+		return name
+	}
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s %d%s", stem, n, ext)
+		if !s.collides(candidate) {
+			return candidate
+		}
+	}
+}
+
+// collides reports whether name exists and isn't the current image itself,
+// which happens when only the case changes on a case-insensitive file system.
+func (s *Session) collides(name string) bool {
+	if s.reserved[filepath.Join(s.CurrentDir(), name)] {
+		return true
+	}
+	if s.copying {
+		return false
+	}
+	target, err := os.Stat(filepath.Join(s.CurrentDir(), name))
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(s.CurrentPath())
+	return err != nil || !os.SameFile(target, current)
+}
+
+// Commit renames the current image to the preview and writes the pending tags
+// to the sidecar and the image metadata, depending on the storage settings
+func (s *Session) Commit() error {
+	return s.Rename(s.preview)
+}
+
+// Rename renames the current image to name within its folder.
+// If name is taken, the collision policy decides between numbering and an ExistsError.
+// The sidecar moves with the image, and with StorageSidecar or StorageBoth it gets the pending tags.
+// After SetEmbedTags the tags are also written into the image.
+func (s *Session) Rename(name string) error {
+	tags := s.tags
+	if s.storage.inName() {
+		_, tags = s.parse(name)
+	}
+	return s.renameTags(name, tags)
+}
+
+// renameTags renames the current image to name like Rename, and writes tags to the
+// sidecar and the image metadata instead of the tags name has by the template
+func (s *Session) renameTags(name string, tags []string) error {
+	if s.Current() == "" {
+		return ErrNoImage
+	}
+	if name == "" || name != filepath.Base(name) {
+		return fmt.Errorf("invalid file name %q", name)
+	}
+	if name == s.name() {
+		return s.saveTags(tags)
+	}
+	name, conflict := s.Resolve(name)
+	if conflict && s.policy != CollisionSequence {
+		return &ExistsError{Name: name, Suggestion: s.sequenceName(name)}
+	}
+	dir := s.CurrentDir()
+	s.forgetTags(s.Current())
+	if s.journal != nil {
+		if err := s.journal.Rename(dir, s.name(), name); err != nil {
+			return err
+		}
+	} else if err := moveWithSidecar(s.CurrentPath(), filepath.Join(dir, name)); err != nil {
+		return err
+	}
+	name = filepath.Join(filepath.Dir(s.Current()), name)
+	s.images[s.index] = name
+	if err := s.saveTags(tags); err != nil {
+		return err
+	}
+	if err := s.refresh(); err != nil {
+		return err
+	}
+	if !s.Seek(name) {
+		// renamed to something that isn't an image anymore
+		s.resetPreview()
+	}
+	return nil
+}
+
+// Delete moves the current image to the trash of the folder and selects the next one,
+// or the previous one if the deleted image was the last. With a journal the delete
+// can be undone. After SetPermanentDelete the file is removed and can't be restored.
+func (s *Session) Delete() error {
+	if s.Current() == "" {
+		return ErrNoImage
+	}
+	var err error
+	switch {
+	case s.permanentDelete:
+		err = removeWithSidecar(s.CurrentPath())
+	case s.journal != nil:
+		err = s.journal.Delete(s.CurrentDir(), s.name())
+	default:
+		_, err = MoveToTrash(s.CurrentDir(), s.name())
+	}
+	if err != nil {
+		return err
+	}
+	s.forgetTags(s.Current())
+	s.images = append(s.images[:s.index], s.images[s.index+1:]...)
+	if s.index >= len(s.images) {
+		s.index = len(s.images) - 1
+	}
+	s.resetPreview()
+	return nil
+}
+
+func (s *Session) resetPreview() {
+	s.preview = s.name()
+	s.loadTags()
+}
+
+// Errors returns the problems found while reading tags since the last call, e.g.
+// unreadable sidecars. The tags read so far are used without them.
+func (s *Session) Errors() []error {
+	return s.errs.take()
+}
+
+// loadTags reads the tags of the current image from its sidecar and its metadata
+func (s *Session) loadTags() {
+	s.tags, s.stored, s.embedded = nil, nil, nil
+	if s.Current() == "" {
+		return
+	}
+	if s.embed {
+		if embedded, err := metadata.ReadKeywords(s.CurrentPath()); err == nil {
+			s.embedded = embedded
+		}
+	}
+	if !s.storage.inSidecar() {
+		return
+	}
+	stored, err := metadata.ReadSidecar(s.CurrentPath())
+	if err != nil {
+		s.errs.add(fmt.Errorf("reading %s: %v", metadata.SidecarPath(s.CurrentPath()), err))
+		return
+	}
+	s.stored = stored
+	s.tags = append([]string{}, stored...)
+}
+
+// saveTags writes tags to the sidecar and the metadata of the current image if they changed
+func (s *Session) saveTags(tags []string) error {
+	s.forgetTags(s.Current())
+	if s.embed && !sameTags(tags, s.embedded) {
+		err := metadata.WriteKeywords(s.CurrentPath(), tags)
+		if err != nil && err != metadata.ErrUnsupportedFormat {
+			return err
+		}
+		s.embedded = append([]string{}, tags...)
+	}
+	if !s.storage.inSidecar() || sameTags(tags, s.stored) {
+		return nil
+	}
+	if err := metadata.WriteSidecar(s.CurrentPath(), tags); err != nil {
+		return err
+	}
+	s.stored = append([]string{}, tags...)
+	return nil
+}
+
+// sameTags reports whether a and b contain the same tags in the same order
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
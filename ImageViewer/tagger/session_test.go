@@ -0,0 +1,481 @@
+package tagger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// makeDir creates the given files in a new temporary directory
+func makeDir(t *testing.T, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, f := range files {
+		makeFile(t, dir, f)
+	}
+	return dir
+}
+
+// makeFile creates a file in dir with its name as content
+func makeFile(t *testing.T, dir, name string) {
+	t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// listDir returns the sorted file names in dir
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	f, err := os.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestIsImage(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"a.jpg", true},
+		{"a.JPG", true},
+		{"a.jpeg", true},
+		{"a.png", true},
+		{"a.gif", true},
+		{"a.txt", false},
+		{"jpg", false},
+		{"a.jpg.xmp", false},
+	}
+	for _, tt := range tests {
+		if got := IsImage(tt.name); got != tt.want {
+			t.Errorf("IsImage(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     []string
+		open      string
+		wantErr   bool
+		wantIndex int
+		wantList  []string
+	}{
+		{
+			name:      "filters and sorts images",
+			files:     []string{"c.jpg", "notes.txt", "a.png", "b.gif"},
+			open:      "b.gif",
+			wantIndex: 1,
+			wantList:  []string{"a.png", "b.gif", "c.jpg"},
+		},
+		{
+			name:    "not an image",
+			files:   []string{"a.jpg", "notes.txt"},
+			open:    "notes.txt",
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			files:   []string{"a.jpg"},
+			open:    "b.jpg",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := makeDir(t, tt.files...)
+			s, err := Open(filepath.Join(dir, tt.open))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if s.Index() != tt.wantIndex {
+				t.Errorf("Index() = %d, want %d", s.Index(), tt.wantIndex)
+			}
+			if !reflect.DeepEqual(s.Images(), tt.wantList) {
+				t.Errorf("Images() = %v, want %v", s.Images(), tt.wantList)
+			}
+			if s.Preview() != tt.open {
+				t.Errorf("Preview() = %q, want %q", s.Preview(), tt.open)
+			}
+		})
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	dir := makeDir(t, "a.jpg", "b.jpg", "c.jpg")
+	s, err := NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		op      string
+		wantOK  bool
+		wantCur string
+	}{
+		{"prev", false, "a.jpg"},
+		{"next", true, "b.jpg"},
+		{"next", true, "c.jpg"},
+		{"next", false, "c.jpg"},
+		{"prev", true, "b.jpg"},
+	}
+	for i, step := range steps {
+		var ok bool
+		if step.op == "next" {
+			ok = s.Next()
+		} else {
+			ok = s.Prev()
+		}
+		if ok != step.wantOK || s.Current() != step.wantCur {
+			t.Errorf("step %d %s: got (%v, %q), want (%v, %q)", i, step.op, ok, s.Current(), step.wantOK, step.wantCur)
+		}
+		if s.Preview() != s.Current() {
+			t.Errorf("step %d: preview %q not reset to %q", i, s.Preview(), s.Current())
+		}
+	}
+}
+
+func TestToggleTagAndCommit(t *testing.T) {
+	vocabulary := []string{"AC", "FURNACE", "FURNACE DATA", "HP DATA"}
+	tests := []struct {
+		name      string
+		files     []string
+		open      string
+		tags      []string
+		wantName  string
+		wantFiles []string
+	}{
+		{
+			name:      "single tag",
+			files:     []string{"IMG_1.jpg", "IMG_2.jpg"},
+			open:      "IMG_1.jpg",
+			tags:      []string{"FURNACE"},
+			wantName:  "IMG_1 FURNACE.jpg",
+			wantFiles: []string{"IMG_1 FURNACE.jpg", "IMG_2.jpg"},
+		},
+		{
+			name:      "tags in vocabulary order",
+			files:     []string{"IMG_1.jpg"},
+			open:      "IMG_1.jpg",
+			tags:      []string{"HP DATA", "AC"},
+			wantName:  "IMG_1 AC HP DATA.jpg",
+			wantFiles: []string{"IMG_1 AC HP DATA.jpg"},
+		},
+		{
+			name:      "pressing twice removes the tag",
+			files:     []string{"IMG_1.jpg"},
+			open:      "IMG_1.jpg",
+			tags:      []string{"FURNACE", "FURNACE"},
+			wantName:  "IMG_1.jpg",
+			wantFiles: []string{"IMG_1.jpg"},
+		},
+		{
+			name:      "removes an existing tag",
+			files:     []string{"IMG_1 AC FURNACE DATA.jpg"},
+			open:      "IMG_1 AC FURNACE DATA.jpg",
+			tags:      []string{"AC"},
+			wantName:  "IMG_1 FURNACE DATA.jpg",
+			wantFiles: []string{"IMG_1 FURNACE DATA.jpg"},
+		},
+		{
+			name:      "no tag is a no-op",
+			files:     []string{"IMG_1.jpg"},
+			open:      "IMG_1.jpg",
+			wantName:  "IMG_1.jpg",
+			wantFiles: []string{"IMG_1.jpg"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := makeDir(t, tt.files...)
+			s, err := Open(filepath.Join(dir, tt.open))
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.SetVocabulary(vocabulary)
+			for _, tag := range tt.tags {
+				s.ToggleTag(tag)
+			}
+			if s.Preview() != tt.wantName {
+				t.Errorf("Preview() = %q, want %q", s.Preview(), tt.wantName)
+			}
+			if err := s.Commit(); err != nil {
+				t.Fatal(err)
+			}
+			if s.Current() != tt.wantName {
+				t.Errorf("Current() = %q, want %q", s.Current(), tt.wantName)
+			}
+			if got := listDir(t, dir); !reflect.DeepEqual(got, tt.wantFiles) {
+				t.Errorf("files = %v, want %v", got, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestToggleKeepsStem(t *testing.T) {
+	for _, name := range []string{"_DSC1234.jpg", "My  photo.jpg", "scan-.jpg"} {
+		dir := makeDir(t, name)
+		s, err := Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SetVocabulary([]string{"ATTIC"})
+		stem := strings.TrimSuffix(name, ".jpg")
+		for _, want := range []string{stem + " ATTIC.jpg", name} {
+			s.ToggleTag("ATTIC")
+			if err := s.Commit(); err != nil {
+				t.Fatal(err)
+			}
+			if s.Current() != want {
+				t.Errorf("toggling ATTIC on %q gives %q, want %q", name, s.Current(), want)
+			}
+		}
+	}
+}
+
+func TestRename(t *testing.T) {
+	tests := []struct {
+		name     string
+		newName  string
+		wantErr  bool
+		wantCur  string
+		wantList []string
+	}{
+		{"rename keeps selection", "z.jpg", false, "z.jpg", []string{"b.jpg", "z.jpg"}},
+		{"same name", "a.jpg", false, "a.jpg", []string{"a.jpg", "b.jpg"}},
+		{"empty name", "", true, "a.jpg", []string{"a.jpg", "b.jpg"}},
+		{"path separator", "sub/a.jpg", true, "a.jpg", []string{"a.jpg", "b.jpg"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := makeDir(t, "a.jpg", "b.jpg")
+			s, err := Open(filepath.Join(dir, "a.jpg"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = s.Rename(tt.newName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Rename() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if s.Current() != tt.wantCur {
+				t.Errorf("Current() = %q, want %q", s.Current(), tt.wantCur)
+			}
+			if !reflect.DeepEqual(s.Images(), tt.wantList) {
+				t.Errorf("Images() = %v, want %v", s.Images(), tt.wantList)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		open     string
+		wantCur  string
+		wantList []string
+	}{
+		{"first selects next", "a.jpg", "b.jpg", []string{TrashDirName, "b.jpg", "c.jpg"}},
+		{"middle selects next", "b.jpg", "c.jpg", []string{TrashDirName, "a.jpg", "c.jpg"}},
+		{"last selects previous", "c.jpg", "b.jpg", []string{TrashDirName, "a.jpg", "b.jpg"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := makeDir(t, "a.jpg", "b.jpg", "c.jpg")
+			s, err := Open(filepath.Join(dir, tt.open))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Delete(); err != nil {
+				t.Fatal(err)
+			}
+			if s.Current() != tt.wantCur {
+				t.Errorf("Current() = %q, want %q", s.Current(), tt.wantCur)
+			}
+			if got := listDir(t, dir); !reflect.DeepEqual(got, tt.wantList) {
+				t.Errorf("files = %v, want %v", got, tt.wantList)
+			}
+		})
+	}
+
+	t.Run("only image", func(t *testing.T) {
+		dir := makeDir(t, "a.jpg")
+		s, err := Open(filepath.Join(dir, "a.jpg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Delete(); err != nil {
+			t.Fatal(err)
+		}
+		if s.Len() != 0 || s.Current() != "" {
+			t.Errorf("session not empty: %v", s.Images())
+		}
+		if err := s.Delete(); err != ErrNoImage {
+			t.Errorf("Delete() on empty session = %v, want ErrNoImage", err)
+		}
+	})
+
+	t.Run("permanent", func(t *testing.T) {
+		dir := makeDir(t, "a.jpg", "b.jpg")
+		s, err := Open(filepath.Join(dir, "a.jpg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SetPermanentDelete(true)
+		if err := s.Delete(); err != nil {
+			t.Fatal(err)
+		}
+		if got := listDir(t, dir); !reflect.DeepEqual(got, []string{"b.jpg"}) {
+			t.Errorf("files = %v, want [b.jpg]", got)
+		}
+	})
+}
+
+func TestRefreshKeepsSelectionAndPreview(t *testing.T) {
+	dir := makeDir(t, "b.jpg", "c.jpg")
+	s, err := Open(filepath.Join(dir, "c.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"AC"})
+	s.ToggleTag("AC")
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.jpg"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Current() != "c.jpg" || s.Index() != 2 {
+		t.Errorf("Current() = %q at %d, want c.jpg at 2", s.Current(), s.Index())
+	}
+	if s.Preview() != "c AC.jpg" {
+		t.Errorf("Preview() = %q, want %q", s.Preview(), "c AC.jpg")
+	}
+}
+
+func TestSequenceName(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		in    string
+		want  string
+	}{
+		{"free", []string{"a.jpg"}, "FURNACE DATA.jpg", "FURNACE DATA.jpg"},
+		{"taken", []string{"FURNACE DATA.jpg"}, "FURNACE DATA.jpg", "FURNACE DATA 2.jpg"},
+		{"counter taken", []string{"FURNACE DATA.jpg", "FURNACE DATA 2.jpg"}, "FURNACE DATA.jpg", "FURNACE DATA 3.jpg"},
+		{"no extension", []string{"notes"}, "notes", "notes 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := makeDir(t, tt.files...)
+			if got := SequenceName(dir, tt.in); got != tt.want {
+				t.Errorf("SequenceName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameCollision(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    CollisionPolicy
+		wantErr   bool
+		wantCur   string
+		wantFiles []string
+	}{
+		{
+			name:      "sequence",
+			policy:    CollisionSequence,
+			wantCur:   "TANK 2.jpg",
+			wantFiles: []string{"TANK 2.jpg", "TANK.jpg"},
+		},
+		{
+			name:      "prompt",
+			policy:    CollisionPrompt,
+			wantErr:   true,
+			wantCur:   "b.jpg",
+			wantFiles: []string{"TANK.jpg", "b.jpg"},
+		},
+		{
+			name:      "refuse",
+			policy:    CollisionRefuse,
+			wantErr:   true,
+			wantCur:   "b.jpg",
+			wantFiles: []string{"TANK.jpg", "b.jpg"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := makeDir(t, "TANK.jpg", "b.jpg")
+			s, err := Open(filepath.Join(dir, "b.jpg"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.SetCollisionPolicy(tt.policy)
+			err = s.Rename("TANK.jpg")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Rename() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				exists, ok := err.(*ExistsError)
+				if !ok {
+					t.Fatalf("Rename() error = %T, want *ExistsError", err)
+				}
+				if exists.Suggestion != "TANK 2.jpg" {
+					t.Errorf("Suggestion = %q, want %q", exists.Suggestion, "TANK 2.jpg")
+				}
+			}
+			if s.Current() != tt.wantCur {
+				t.Errorf("Current() = %q, want %q", s.Current(), tt.wantCur)
+			}
+			if got := listDir(t, dir); !reflect.DeepEqual(got, tt.wantFiles) {
+				t.Errorf("files = %v, want %v", got, tt.wantFiles)
+			}
+			if got, _ := ioutil.ReadFile(filepath.Join(dir, "TANK.jpg")); string(got) != "TANK.jpg" {
+				t.Errorf("TANK.jpg was overwritten with %q", got)
+			}
+		})
+	}
+}
+
+func TestToggleTagResolvesCollision(t *testing.T) {
+	dir := makeDir(t, "a AC.jpg", "a.jpg")
+	s, err := Open(filepath.Join(dir, "a.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"AC", "HP"})
+	if got := s.ToggleTag("AC"); got != "a AC 2.jpg" {
+		t.Errorf("ToggleTag() with sequence = %q, want %q", got, "a AC 2.jpg")
+	}
+	// the counter isn't mistaken for part of the base name
+	if got := s.ToggleTag("HP"); got != "a AC HP.jpg" {
+		t.Errorf("ToggleTag() of a numbered name = %q, want %q", got, "a AC HP.jpg")
+	}
+	s.SetPreview("a.jpg")
+	s.SetCollisionPolicy(CollisionRefuse)
+	if got := s.ToggleTag("AC"); got != "a AC.jpg" {
+		t.Errorf("ToggleTag() with refuse = %q, want %q", got, "a AC.jpg")
+	}
+	if _, conflict := s.Resolve("a AC.jpg"); !conflict {
+		t.Error("Resolve() reported no conflict")
+	}
+	if _, conflict := s.Resolve("a.jpg"); conflict {
+		t.Error("Resolve() of the current name reported a conflict")
+	}
+}
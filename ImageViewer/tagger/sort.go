@@ -0,0 +1,206 @@
+package tagger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/metadata"
+)
+
+// SortOrder decides the order of the images, which Next and Prev follow
+type SortOrder string
+
+const (
+	// SortNatural sorts by name with numbers compared by value, so IMG_9 comes before IMG_10
+	SortNatural SortOrder = "natural"
+	// SortName sorts by name character by character, so IMG_10 comes before IMG_9
+	SortName SortOrder = "name"
+	// SortDate sorts by the EXIF capture date, images without one come last
+	SortDate SortOrder = "date"
+	// SortModified sorts by modification time
+	SortModified SortOrder = "modified"
+	// SortSize sorts by file size, smallest first
+	SortSize SortOrder = "size"
+	// SortCapture sorts by the EXIF capture date and then by the name before any rename,
+	// so the order doesn't change when images are tagged
+	SortCapture SortOrder = "capture"
+)
+
+// SortOrders are all orders, in the order they are offered to the user
+var SortOrders = []SortOrder{SortNatural, SortName, SortDate, SortModified, SortSize, SortCapture}
+
+// ParseSortOrder parses an order name from the config. Unknown names give SortNatural and an error.
+func ParseSortOrder(s string) (SortOrder, error) {
+	for _, v := range SortOrders {
+		if string(v) == strings.ToLower(s) {
+			return v, nil
+		}
+	}
+	return SortNatural, fmt.Errorf("unknown sort order %q", s)
+}
+
+// fileInfo is what the orders besides the name orders sort by
+type fileInfo struct {
+	modified time.Time
+	size     int64
+	// date is the EXIF capture date, zero if unknown or not read yet
+	date     time.Time
+	dateRead bool
+}
+
+// SortOrder returns the order of the images
+func (s *Session) SortOrder() SortOrder {
+	return s.order
+}
+
+// SetSortOrder sorts the images by o. The current image stays selected.
+func (s *Session) SetSortOrder(o SortOrder) {
+	s.order = o
+	current := s.Current()
+	s.sortImages()
+	if i := s.find(current); i >= 0 {
+		s.index = i
+	}
+}
+
+// sortImages sorts the images by folder and then by the sort order
+func (s *Session) sortImages() {
+	keys := map[string]*fileInfo{}
+	if s.order != SortNatural && s.order != SortName {
+		for _, name := range s.images {
+			keys[name] = s.fileInfo(name)
+		}
+		// drop what belongs to renamed or removed images
+		for name := range s.infos {
+			if keys[name] == nil {
+				delete(s.infos, name)
+			}
+		}
+	}
+	originals := map[string]string{}
+	if s.order == SortCapture {
+		for _, name := range s.images {
+			originals[name] = name
+			if s.journal != nil {
+				dir := filepath.Join(s.dir, filepath.Dir(name))
+				originals[name] = s.journal.OriginalName(dir, filepath.Base(name))
+			}
+		}
+	}
+
+	sort.SliceStable(s.images, func(i, j int) bool {
+		a, b := s.images[i], s.images[j]
+		da, db := filepath.Dir(a), filepath.Dir(b)
+		if da != db {
+			return folderLess(da, db)
+		}
+		ka, kb := keys[a], keys[b]
+		switch s.order {
+		case SortName:
+			return filepath.Base(a) < filepath.Base(b)
+		case SortDate:
+			if !ka.date.Equal(kb.date) {
+				return !ka.date.IsZero() && (kb.date.IsZero() || ka.date.Before(kb.date))
+			}
+		case SortModified:
+			if !ka.modified.Equal(kb.modified) {
+				return ka.modified.Before(kb.modified)
+			}
+		case SortSize:
+			if ka.size != kb.size {
+				return ka.size < kb.size
+			}
+		case SortCapture:
+			if !ka.date.Equal(kb.date) {
+				return !ka.date.IsZero() && (kb.date.IsZero() || ka.date.Before(kb.date))
+			}
+			if originals[a] != originals[b] {
+				return NaturalLess(originals[a], originals[b])
+			}
+		}
+		return NaturalLess(filepath.Base(a), filepath.Base(b))
+	})
+}
+
+// fileInfo returns the sort keys of the image name. The EXIF date is read only
+// once per file and again if the file was changed.
+func (s *Session) fileInfo(name string) *fileInfo {
+	if s.infos == nil {
+		s.infos = map[string]*fileInfo{}
+	}
+	path := filepath.Join(s.dir, name)
+	info := &fileInfo{}
+	if stat, err := os.Stat(path); err == nil {
+		info.modified, info.size = stat.ModTime(), stat.Size()
+	}
+	if cached, ok := s.infos[name]; ok && cached.modified.Equal(info.modified) && cached.size == info.size {
+		info = cached
+	}
+	if !info.dateRead && (s.order == SortDate || s.order == SortCapture) {
+		if e, err := metadata.ReadEXIFFile(path); err == nil {
+			info.date = e.DateTimeOriginal
+		}
+		info.dateRead = true
+	}
+	s.infos[name] = info
+	return info
+}
+
+// folderLess reports whether the folder a comes before b: the session folder
+// first and subfolders right after their parent, e.g. "visit1/unit b" before "visit1 a"
+func folderLess(a, b string) bool {
+	if a == "." || b == "." {
+		return a == "." && b != "."
+	}
+	pa := strings.Split(a, string(filepath.Separator))
+	pb := strings.Split(b, string(filepath.Separator))
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		if pa[i] != pb[i] {
+			return NaturalLess(pa[i], pb[i])
+		}
+	}
+	return len(pa) < len(pb)
+}
+
+// NaturalLess reports whether a comes before b when runs of digits are compared by
+// their value and letters regardless of case, e.g. "IMG_9" before "img_10"
+func NaturalLess(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		if unicode.IsDigit(ra[i]) && unicode.IsDigit(rb[j]) {
+			si, sj := i, j
+			for i < len(ra) && unicode.IsDigit(ra[i]) {
+				i++
+			}
+			for j < len(rb) && unicode.IsDigit(rb[j]) {
+				j++
+			}
+			na := strings.TrimLeft(string(ra[si:i]), "0")
+			nb := strings.TrimLeft(string(rb[sj:j]), "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			continue
+		}
+		ca, cb := unicode.ToLower(ra[i]), unicode.ToLower(rb[j])
+		if ca != cb {
+			return ca < cb
+		}
+		i++
+		j++
+	}
+	if len(ra)-i != len(rb)-j {
+		return len(ra)-i < len(rb)-j
+	}
+	// equal apart from case and leading zeros
+	return a < b
+}
@@ -0,0 +1,119 @@
+package tagger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"IMG_9.jpg", "IMG_10.jpg", true},
+		{"IMG_10.jpg", "IMG_9.jpg", false},
+		{"img_2.jpg", "IMG_10.jpg", true},
+		{"IMG_009.jpg", "IMG_10.jpg", true},
+		{"a.jpg", "b.jpg", true},
+		{"IMG_1.jpg", "IMG_1 FRONT.jpg", false},
+		{"IMG_1", "IMG_1 FRONT", true},
+		{"IMG_1.jpg", "IMG_1.jpg", false},
+	}
+	for _, tt := range tests {
+		if got := NaturalLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("NaturalLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSortOrders(t *testing.T) {
+	dir := makeDir(t)
+	files := []struct {
+		name string
+		size int
+		age  time.Duration
+	}{
+		{"IMG_10.jpg", 30, 1 * time.Hour},
+		{"IMG_9.jpg", 10, 3 * time.Hour},
+		{"IMG_100.jpg", 20, 2 * time.Hour},
+	}
+	now := time.Now()
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := ioutil.WriteFile(path, make([]byte, f.size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now, now.Add(-f.age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Seek("IMG_100.jpg")
+
+	tests := []struct {
+		order SortOrder
+		want  []string
+	}{
+		{SortNatural, []string{"IMG_9.jpg", "IMG_10.jpg", "IMG_100.jpg"}},
+		{SortName, []string{"IMG_10.jpg", "IMG_100.jpg", "IMG_9.jpg"}},
+		{SortModified, []string{"IMG_9.jpg", "IMG_100.jpg", "IMG_10.jpg"}},
+		{SortSize, []string{"IMG_9.jpg", "IMG_100.jpg", "IMG_10.jpg"}},
+		// no EXIF dates, so the names decide
+		{SortDate, []string{"IMG_9.jpg", "IMG_10.jpg", "IMG_100.jpg"}},
+	}
+	for _, tt := range tests {
+		s.SetSortOrder(tt.order)
+		if !reflect.DeepEqual(s.Images(), tt.want) {
+			t.Errorf("%s: Images() = %v, want %v", tt.order, s.Images(), tt.want)
+		}
+		if s.Current() != "IMG_100.jpg" {
+			t.Errorf("%s: Current() = %q, the selection moved", tt.order, s.Current())
+		}
+	}
+}
+
+func TestSortCaptureIsStable(t *testing.T) {
+	dir := makeDir(t, "IMG_1.jpg", "IMG_2.jpg", "IMG_3.jpg")
+	s, err := NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "journal.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetJournal(j)
+	s.SetVocabulary([]string{"ATTIC", "ZONE"})
+	tmpl, err := ParseTemplate("{tags}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetTemplate(tmpl, nil)
+	s.SetSortOrder(SortCapture)
+
+	// renamed to names that sort the other way round
+	s.Seek("IMG_1.jpg")
+	s.ToggleTag("ZONE")
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	s.Seek("IMG_3.jpg")
+	s.ToggleTag("ATTIC")
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ZONE.jpg", "IMG_2.jpg", "ATTIC.jpg"}
+	if !reflect.DeepEqual(s.Images(), want) {
+		t.Errorf("Images() = %v, want %v", s.Images(), want)
+	}
+	if s.Current() != "ATTIC.jpg" {
+		t.Errorf("Current() = %q", s.Current())
+	}
+}
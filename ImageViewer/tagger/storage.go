@@ -0,0 +1,73 @@
+package tagger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/metadata"
+)
+
+// TagStorage decides where the tags of an image are kept
+type TagStorage string
+
+const (
+	// StorageRename keeps the tags in the file name
+	StorageRename TagStorage = "rename"
+	// StorageSidecar keeps the tags in an XMP sidecar, e.g. "photo.jpg.xmp", and leaves the name alone
+	StorageSidecar TagStorage = "sidecar"
+	// StorageBoth keeps the tags in the file name and the XMP sidecar
+	StorageBoth TagStorage = "both"
+)
+
+// TagStorages are all storages, in the order they are offered to the user
+var TagStorages = []TagStorage{StorageRename, StorageSidecar, StorageBoth}
+
+// ParseTagStorage parses a storage name from the config. Unknown names give StorageRename and an error.
+func ParseTagStorage(s string) (TagStorage, error) {
+	for _, v := range TagStorages {
+		if string(v) == s {
+			return v, nil
+		}
+	}
+	return StorageRename, fmt.Errorf("unknown tag storage %q", s)
+}
+
+// inName reports whether the tags are part of the file name
+func (t TagStorage) inName() bool {
+	return t != StorageSidecar
+}
+
+// inSidecar reports whether the tags are written to the XMP sidecar
+func (t TagStorage) inSidecar() bool {
+	return t == StorageSidecar || t == StorageBoth
+}
+
+// moveWithSidecar moves oldpath to newpath like moveFile, together with its XMP sidecar if there is one.
+// If the sidecar can't be moved, the image is moved back, so the journal has nothing to record.
+func moveWithSidecar(oldpath, newpath string) error {
+	if err := moveFile(oldpath, newpath); err != nil {
+		return err
+	}
+	if !exists(metadata.SidecarPath(oldpath)) {
+		return nil
+	}
+	if err := moveFile(metadata.SidecarPath(oldpath), metadata.SidecarPath(newpath)); err != nil {
+		if undo := moveFile(newpath, oldpath); undo != nil {
+			return fmt.Errorf("%v, and %s couldn't be moved back: %v", err, filepath.Base(oldpath), undo)
+		}
+		return err
+	}
+	return nil
+}
+
+// removeWithSidecar removes path and its XMP sidecar if there is one
+func removeWithSidecar(path string) error {
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	if err := os.Remove(metadata.SidecarPath(path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
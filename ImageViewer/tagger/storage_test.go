@@ -0,0 +1,167 @@
+package tagger
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/metadata"
+)
+
+func TestSidecarStorage(t *testing.T) {
+	dir := makeDir(t, "IMG_1.jpg", "IMG_2.jpg")
+	s, err := Open(filepath.Join(dir, "IMG_1.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"AC", "TANK"})
+	s.SetTagStorage(StorageSidecar)
+
+	// the name stays, the tags wait for Commit
+	if got := s.ToggleTag("TANK"); got != "IMG_1.jpg" {
+		t.Errorf("ToggleTag(TANK) = %q, want IMG_1.jpg", got)
+	}
+	s.ToggleTag("AC")
+	if !reflect.DeepEqual(s.Tags(), []string{"AC", "TANK"}) {
+		t.Errorf("Tags() = %v, want [AC TANK]", s.Tags())
+	}
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := metadata.ReadSidecar(filepath.Join(dir, "IMG_1.jpg"))
+	if err != nil || !reflect.DeepEqual(tags, []string{"AC", "TANK"}) {
+		t.Errorf("sidecar tags = %v, %v", tags, err)
+	}
+
+	// the tags are read back when the image is selected again
+	s.Next()
+	if len(s.Tags()) != 0 {
+		t.Errorf("Tags() of IMG_2 = %v, want none", s.Tags())
+	}
+	s.Prev()
+	if !reflect.DeepEqual(s.Tags(), []string{"AC", "TANK"}) {
+		t.Errorf("Tags() after Prev = %v, want [AC TANK]", s.Tags())
+	}
+
+	// the sidecar moves with its image
+	if err := s.Rename("Kitchen.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"IMG_2.jpg", "Kitchen.jpg", "Kitchen.jpg.xmp"}
+	if got := listDir(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("files after rename = %v, want %v", got, want)
+	}
+}
+
+func TestBothStorage(t *testing.T) {
+	dir := makeDir(t, "IMG_1.jpg")
+	s, err := Open(filepath.Join(dir, "IMG_1.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"AC"})
+	s.SetTagStorage(StorageBoth)
+
+	if got := s.ToggleTag("AC"); got != "IMG_1 AC.jpg" {
+		t.Errorf("ToggleTag(AC) = %q", got)
+	}
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := metadata.ReadSidecar(filepath.Join(dir, "IMG_1 AC.jpg"))
+	if err != nil || !reflect.DeepEqual(tags, []string{"AC"}) {
+		t.Errorf("sidecar tags = %v, %v", tags, err)
+	}
+}
+
+func TestSidecarFollowsTrashAndUndo(t *testing.T) {
+	dir := makeDir(t, "IMG_1.jpg", "IMG_1.jpg.xmp")
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "journal.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Rename(dir, "IMG_1.jpg", "A.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Delete(dir, "A.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	items, err := ListTrash(dir)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("ListTrash() = %v, %v, want one item", items, err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := j.Undo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"IMG_1.jpg", "IMG_1.jpg.xmp"}
+	if got := listDir(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("files after undo = %v, want %v", got, want)
+	}
+}
+
+func TestMoveWithSidecarRollsBack(t *testing.T) {
+	dir := makeDir(t, "IMG_1.jpg", "IMG_1.jpg.xmp")
+	// a folder in the way of the sidecar makes its move fail
+	blocked := filepath.Join(dir, "Kitchen.jpg.xmp", "keep")
+	if err := os.MkdirAll(blocked, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := moveWithSidecar(filepath.Join(dir, "IMG_1.jpg"), filepath.Join(dir, "Kitchen.jpg")); err == nil {
+		t.Fatal("moveWithSidecar succeeded with the sidecar name taken")
+	}
+	want := []string{"IMG_1.jpg", "IMG_1.jpg.xmp", "Kitchen.jpg.xmp"}
+	if got := listDir(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("files after the failed move = %v, want %v", got, want)
+	}
+}
+
+func TestCrossDevice(t *testing.T) {
+	if !crossDevice(&os.LinkError{Op: "rename", Old: "a", New: "b", Err: syscall.EXDEV}) {
+		t.Error("EXDEV isn't a cross device error")
+	}
+	if crossDevice(&os.LinkError{Op: "rename", Old: "a", New: "b", Err: syscall.EACCES}) || crossDevice(nil) {
+		t.Error("other errors are cross device errors")
+	}
+}
+
+func TestEmbedTags(t *testing.T) {
+	dir := t.TempDir()
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, image.NewGray(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "IMG_1.jpg"), b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	makeFile(t, dir, "IMG_2.gif")
+
+	s, err := Open(filepath.Join(dir, "IMG_1.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"AC", "TANK"})
+	s.SetEmbedTags(true)
+	s.ToggleTag("TANK")
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	keywords, err := metadata.ReadKeywords(filepath.Join(dir, "IMG_1 TANK.jpg"))
+	if err != nil || !reflect.DeepEqual(keywords, []string{"TANK"}) {
+		t.Errorf("embedded keywords = %v, %v", keywords, err)
+	}
+
+	// formats without metadata support are only renamed
+	s.Next()
+	s.ToggleTag("AC")
+	if err := s.Commit(); err != nil {
+		t.Errorf("Commit() of a GIF: %v", err)
+	}
+}
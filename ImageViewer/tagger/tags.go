@@ -0,0 +1,112 @@
+package tagger
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ParseTags splits the stem of name into its base and the known tags at its end,
+// e.g. "IMG_1 FURNACE DATA.jpg" gives "IMG_1" and [FURNACE DATA] if "FURNACE DATA"
+// is in vocabulary. Longer tags win over shorter ones, and a counter appended by
+// SequenceName after the tags is ignored. The tags are returned in vocabulary order
+// without duplicates.
+func ParseTags(name string, vocabulary []string) (string, []string) {
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+
+	base, tags := trimTags(stem, vocabulary)
+	if len(tags) == 0 {
+		// "IMG_1 FURNACE 2" was numbered because the name was taken
+		if i := strings.LastIndexByte(stem, ' '); i > 0 && isNumber(stem[i+1:]) {
+			base, tags = trimTags(stem[:i], vocabulary)
+			if len(tags) == 0 {
+				base = stem
+			}
+		}
+	}
+	return base, OrderTags(tags, vocabulary)
+}
+
+// trimTags strips known tags from the end of stem
+func trimTags(stem string, vocabulary []string) (string, []string) {
+	// longest first, so "TANK DATA" isn't parsed as "DATA"
+	sorted := make([]string, 0, len(vocabulary))
+	for _, v := range vocabulary {
+		if v != "" {
+			sorted = append(sorted, v)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	tags := []string{}
+	for {
+		found := false
+		for _, tag := range sorted {
+			if strings.HasSuffix(stem, " "+tag) {
+				stem = strings.TrimSuffix(stem, " "+tag)
+				tags = append(tags, tag)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return stem, tags
+		}
+	}
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// OrderTags returns tags without duplicates, in the order of vocabulary.
+// Tags that aren't in vocabulary follow in their original order.
+func OrderTags(tags, vocabulary []string) []string {
+	active := map[string]bool{}
+	for _, t := range tags {
+		active[t] = true
+	}
+	ordered := []string{}
+	for _, v := range vocabulary {
+		if v != "" && active[v] {
+			ordered = append(ordered, v)
+			delete(active, v)
+		}
+	}
+	for _, t := range tags {
+		if active[t] {
+			ordered = append(ordered, t)
+			delete(active, t)
+		}
+	}
+	return ordered
+}
+
+// BuildName joins base and tags with spaces and appends ext
+func BuildName(base string, tags []string, ext string) string {
+	parts := []string{}
+	if base != "" {
+		parts = append(parts, base)
+	}
+	parts = append(parts, tags...)
+	return strings.Join(parts, " ") + ext
+}
+
+// HasTag reports whether tags contains tag
+func HasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
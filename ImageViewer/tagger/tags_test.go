@@ -0,0 +1,68 @@
+package tagger
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	vocabulary := []string{"AC", "FURNACE", "FURNACE DATA", "TANK", "TANK DATA", ""}
+	tests := []struct {
+		name     string
+		wantBase string
+		wantTags []string
+	}{
+		{"IMG_1.jpg", "IMG_1", []string{}},
+		{"IMG_1 AC.jpg", "IMG_1", []string{"AC"}},
+		{"IMG_1 FURNACE DATA.jpg", "IMG_1", []string{"FURNACE DATA"}},
+		{"IMG_1 TANK DATA AC.jpg", "IMG_1", []string{"AC", "TANK DATA"}},
+		{"IMG_1 FURNACE FURNACE.jpg", "IMG_1", []string{"FURNACE"}},
+		{"IMG_1 AC 2.jpg", "IMG_1", []string{"AC"}},
+		{"IMG 2.jpg", "IMG 2", []string{}},
+		{"IMG_1 DATA.jpg", "IMG_1 DATA", []string{}},
+		{"ACME AC.png", "ACME", []string{"AC"}},
+		{"AC.jpg", "AC", []string{}},
+	}
+	for _, tt := range tests {
+		base, tags := ParseTags(tt.name, vocabulary)
+		if base != tt.wantBase || !reflect.DeepEqual(tags, tt.wantTags) {
+			t.Errorf("ParseTags(%q) = %q, %v, want %q, %v", tt.name, base, tags, tt.wantBase, tt.wantTags)
+		}
+	}
+}
+
+func TestOrderTags(t *testing.T) {
+	vocabulary := []string{"AC", "FURNACE", "TANK"}
+	tests := []struct {
+		tags []string
+		want []string
+	}{
+		{[]string{"TANK", "AC"}, []string{"AC", "TANK"}},
+		{[]string{"AC", "AC"}, []string{"AC"}},
+		{[]string{"OTHER", "TANK"}, []string{"TANK", "OTHER"}},
+		{nil, []string{}},
+	}
+	for _, tt := range tests {
+		if got := OrderTags(tt.tags, vocabulary); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("OrderTags(%v) = %v, want %v", tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestBuildName(t *testing.T) {
+	tests := []struct {
+		base string
+		tags []string
+		ext  string
+		want string
+	}{
+		{"IMG_1", []string{"AC", "FURNACE DATA"}, ".jpg", "IMG_1 AC FURNACE DATA.jpg"},
+		{"IMG_1", nil, ".jpg", "IMG_1.jpg"},
+		{"", []string{"AC"}, ".png", "AC.png"},
+	}
+	for _, tt := range tests {
+		if got := BuildName(tt.base, tt.tags, tt.ext); got != tt.want {
+			t.Errorf("BuildName(%q, %v, %q) = %q, want %q", tt.base, tt.tags, tt.ext, got, tt.want)
+		}
+	}
+}
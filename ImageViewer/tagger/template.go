@@ -0,0 +1,408 @@
+package tagger
+
+import (
+	"fmt"
+	"math/bits"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTemplate names images like before templates existed: the original stem followed by the tags
+const DefaultTemplate = "{stem} {tags}"
+
+// Template tokens. Any other token is the name of a job variable.
+const (
+	tokenTags     = "tags"
+	tokenSeq      = "seq"
+	tokenStem     = "stem"
+	tokenExifDate = "exif.date"
+	tokenFolder   = "folder"
+)
+
+// defaultDateLayout is used by {exif.date} without a layout
+const defaultDateLayout = "2006-01-02"
+
+// Fields are the values a template is rendered with
+type Fields struct {
+	// Stem is the original file name without extension
+	Stem string
+	Tags []string
+	// Seq is the sequence number, see Template.Render
+	Seq int
+	// Date is the capture date from the EXIF data
+	Date   time.Time
+	Folder string
+	// Vars are the job variables, e.g. "address"
+	Vars map[string]string
+}
+
+// segment is a literal text or a token of a template
+type segment struct {
+	literal string
+	token   string
+	// arg is the text after the colon, e.g. "03" in {seq:03}
+	arg string
+}
+
+func (s segment) isToken() bool {
+	return s.token != ""
+}
+
+// Template generates file names like "{address}_{tags}_{seq:03}".
+// The extension of the image is always appended.
+//
+// Tokens:
+//
+//	{tags}               the tags separated by spaces
+//	{seq} or {seq:03}    the lowest number >= 1 that makes the name unique, optionally zero padded to
+//	                     the width, {seq:3} is the same as {seq:03}
+//	{stem}               the original file name without extension
+//	{exif.date:layout}   the capture date in Go time layout, 2006-01-02 by default
+//	{folder}             the name of the image folder
+//	{name}               the job variable "name"
+type Template struct {
+	text     string
+	segments []segment
+
+	// patterns are the compiled expressions of Match by pattern text. The pattern
+	// depends on the vocabulary and the folder, so each one is compiled on first use.
+	mu       sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+// maxPatterns bounds the compiled expressions a template keeps
+const maxPatterns = 64
+
+// ParseTemplate parses and validates text. vars are the defined job variables.
+func ParseTemplate(text string, vars map[string]string) (*Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("template is empty")
+	}
+	if strings.ContainsAny(text, `/\`) {
+		return nil, fmt.Errorf("template can't contain path separators")
+	}
+
+	t := &Template{text: text}
+	rest := text
+	for rest != "" {
+		open := strings.IndexByte(rest, '{')
+		closing := strings.IndexByte(rest, '}')
+		if open < 0 {
+			if closing >= 0 {
+				return nil, fmt.Errorf("unexpected } in template")
+			}
+			t.segments = append(t.segments, segment{literal: rest})
+			break
+		}
+		if closing >= 0 && closing < open {
+			return nil, fmt.Errorf("unexpected } in template")
+		}
+		if open > 0 {
+			t.segments = append(t.segments, segment{literal: rest[:open]})
+		}
+		rest = rest[open+1:]
+		closing = strings.IndexByte(rest, '}')
+		if closing < 0 {
+			return nil, fmt.Errorf("missing } in template")
+		}
+		seg, err := parseToken(rest[:closing], vars)
+		if err != nil {
+			return nil, err
+		}
+		t.segments = append(t.segments, seg)
+		rest = rest[closing+1:]
+	}
+
+	hasToken := false
+	for _, seg := range t.segments {
+		hasToken = hasToken || seg.isToken()
+	}
+	if !hasToken {
+		return nil, fmt.Errorf("template needs at least one token, e.g. {tags}")
+	}
+	return t, nil
+}
+
+func parseToken(text string, vars map[string]string) (segment, error) {
+	name, arg := text, ""
+	if i := strings.IndexByte(text, ':'); i >= 0 {
+		name, arg = text[:i], text[i+1:]
+	}
+	name = strings.TrimSpace(name)
+	switch name {
+	case "":
+		return segment{}, fmt.Errorf("empty token {} in template")
+	case tokenSeq:
+		if arg != "" {
+			if width, err := strconv.Atoi(arg); err != nil || !isDigits(arg) || width < 1 {
+				return segment{}, fmt.Errorf("invalid width %q in {seq}, use e.g. {seq:03}", arg)
+			}
+		}
+	case tokenExifDate:
+		if arg == "" {
+			arg = defaultDateLayout
+		}
+		if strings.ContainsAny(time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC).Format(arg), `/\:`) {
+			return segment{}, fmt.Errorf("date layout %q gives characters not allowed in file names", arg)
+		}
+	case tokenTags, tokenStem, tokenFolder:
+		if arg != "" {
+			return segment{}, fmt.Errorf("{%s} takes no argument", name)
+		}
+	default:
+		if _, ok := vars[name]; !ok {
+			return segment{}, fmt.Errorf("unknown token {%s}, define it as a job variable", name)
+		}
+		if arg != "" {
+			return segment{}, fmt.Errorf("{%s} takes no argument", name)
+		}
+	}
+	return segment{token: name, arg: arg}, nil
+}
+
+// isDigits reports whether s only consists of ASCII digits
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// String returns the template text
+func (t *Template) String() string {
+	return t.text
+}
+
+// HasSeq reports whether the template contains {seq}
+func (t *Template) HasSeq() bool {
+	return t.has(tokenSeq)
+}
+
+func (t *Template) has(token string) bool {
+	for _, seg := range t.segments {
+		if seg.token == token {
+			return true
+		}
+	}
+	return false
+}
+
+// Render returns the name for f with ext appended. Separators next to a token
+// that renders empty are dropped, so "{stem} {tags}" without tags gives just the stem.
+// The stem and the other text are kept as they are.
+func (t *Template) Render(f Fields, ext string) string {
+	values := make([]string, len(t.segments))
+	for i, seg := range t.segments {
+		if seg.isToken() {
+			values[i] = f.value(seg)
+		} else {
+			values[i] = seg.literal
+		}
+	}
+	empty := make([]bool, len(values))
+	for i, seg := range t.segments {
+		empty[i] = seg.isToken() && values[i] == ""
+	}
+	name := ""
+	for i, keep := range t.kept(empty) {
+		if keep {
+			name += values[i]
+		}
+	}
+	return name + ext
+}
+
+// kept returns which segments are part of a name when the tokens marked in empty
+// render empty. Of the separators around empty tokens only the last one between
+// two other segments is kept, the ones at the start or end of the name are dropped.
+func (t *Template) kept(empty []bool) []bool {
+	kept := make([]bool, len(t.segments))
+	// sep is the last separator since the last kept segment, dropped reports
+	// whether an empty token came since then
+	sep, dropped, content := -1, false, false
+	for i, seg := range t.segments {
+		switch {
+		case empty[i]:
+			dropped = true
+		case !seg.isToken() && isSeparator(seg.literal):
+			sep = i
+		default:
+			if sep >= 0 && (content || !dropped) {
+				kept[sep] = true
+			}
+			kept[i] = true
+			sep, dropped, content = -1, false, true
+		}
+	}
+	if sep >= 0 && !dropped {
+		kept[sep] = true
+	}
+	return kept
+}
+
+func (f Fields) value(seg segment) string {
+	switch seg.token {
+	case tokenTags:
+		return strings.Join(f.Tags, " ")
+	case tokenSeq:
+		if seg.arg != "" {
+			width, _ := strconv.Atoi(seg.arg)
+			return fmt.Sprintf("%0*d", width, f.Seq)
+		}
+		return strconv.Itoa(f.Seq)
+	case tokenStem:
+		return f.Stem
+	case tokenExifDate:
+		if f.Date.IsZero() {
+			return ""
+		}
+		return f.Date.Format(seg.arg)
+	case tokenFolder:
+		return f.Folder
+	}
+	return f.Vars[seg.token]
+}
+
+// isSeparator reports whether s only consists of characters that separate tokens
+func isSeparator(s string) bool {
+	return s != "" && strings.Trim(s, " _-.,") == ""
+}
+
+// Match parses stem, a file name without extension generated by the template,
+// back into the original stem and the tags. It returns false if stem doesn't fit the template.
+func (t *Template) Match(stem string, f Fields, vocabulary []string) (string, []string, bool) {
+	// a counter appended by SequenceName only counts if there are tags before it,
+	// otherwise it is part of the stem like in "IMG 2"
+	for _, counter := range []bool{!t.HasSeq(), false} {
+		re, err := t.compile(t.pattern(f, vocabulary, counter))
+		if err != nil {
+			return "", nil, false
+		}
+		m := re.FindStringSubmatch(stem)
+		if m == nil {
+			continue
+		}
+		original, tags, numbered := "", []string{}, false
+		for i, group := range re.SubexpNames() {
+			switch group {
+			case tokenStem:
+				if m[i] != "" {
+					original = m[i]
+				}
+			case tokenTags:
+				if m[i] != "" {
+					_, parsed := trimTags(" "+m[i], vocabulary)
+					tags = append(tags, parsed...)
+				}
+			case "counter":
+				numbered = m[i] != ""
+			}
+		}
+		if numbered && len(tags) == 0 {
+			continue
+		}
+		return original, OrderTags(tags, vocabulary), true
+	}
+	return "", nil, false
+}
+
+// compile returns the compiled pattern, compiling it only the first time
+func (t *Template) compile(pattern string) (*regexp.Regexp, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if re, ok := t.patterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if t.patterns == nil || len(t.patterns) >= maxPatterns {
+		t.patterns = map[string]*regexp.Regexp{}
+	}
+	t.patterns[pattern] = re
+	return re, nil
+}
+
+// pattern returns a regular expression matching the names the template generates.
+// With counter, a number appended by SequenceName is allowed at the end.
+func (t *Template) pattern(f Fields, vocabulary []string, counter bool) string {
+	// longest first, so "TANK DATA" is preferred over "TANK"
+	known := []string{}
+	for _, v := range vocabulary {
+		if v != "" {
+			known = append(known, regexp.QuoteMeta(v))
+		}
+	}
+	sort.SliceStable(known, func(i, j int) bool { return len(known[i]) > len(known[j]) })
+	tag := "(?:" + strings.Join(known, "|") + ")"
+	if len(known) == 0 {
+		tag = "(?:$^)" // matches nothing
+	}
+
+	pieces := make([]string, len(t.segments))
+	// optional are the tokens that may render empty
+	optional := []int{}
+	for i, seg := range t.segments {
+		if !seg.isToken() {
+			pieces[i] = regexp.QuoteMeta(seg.literal)
+			continue
+		}
+		switch seg.token {
+		case tokenTags:
+			pieces[i] = "(?P<tags>" + tag + "(?: " + tag + ")*)"
+		case tokenSeq:
+			pieces[i] = `\d+`
+		case tokenStem, tokenExifDate:
+			pieces[i] = ".+?"
+		case tokenFolder:
+			pieces[i] = regexp.QuoteMeta(f.Folder)
+		default:
+			pieces[i] = regexp.QuoteMeta(f.Vars[seg.token])
+		}
+		if seg.token != tokenSeq && pieces[i] != "" {
+			optional = append(optional, i)
+		}
+	}
+
+	// one alternative for each combination of empty tokens, see Render,
+	// those with the fewest empty tokens first
+	masks := make([]int, 1<<len(optional))
+	for m := range masks {
+		masks[m] = m
+	}
+	sort.SliceStable(masks, func(i, j int) bool { return bits.OnesCount(uint(masks[i])) < bits.OnesCount(uint(masks[j])) })
+	alternatives := []string{}
+	for _, m := range masks {
+		empty := make([]bool, len(t.segments))
+		for i, seg := range t.segments {
+			empty[i] = seg.isToken() && pieces[i] == ""
+		}
+		for bit, i := range optional {
+			empty[i] = m&(1<<bit) != 0
+		}
+		alternative, stemUsed := "", false
+		for i, keep := range t.kept(empty) {
+			switch {
+			case !keep:
+			case t.segments[i].token == tokenStem && !stemUsed:
+				alternative += "(?P<stem>" + pieces[i] + ")"
+				stemUsed = true
+			default:
+				alternative += pieces[i]
+			}
+		}
+		alternatives = append(alternatives, alternative)
+	}
+	pattern := "^(?:" + strings.Join(alternatives, "|") + ")"
+	if counter {
+		pattern += `(?P<counter> \d+)?`
+	}
+	return pattern + "$"
+}
@@ -0,0 +1,241 @@
+package tagger
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTemplate(t *testing.T) {
+	vars := map[string]string{"address": "12 Main St"}
+	tests := []struct {
+		text    string
+		wantErr bool
+	}{
+		{DefaultTemplate, false},
+		{"{address}_{tags}_{seq:03}", false},
+		{"{exif.date:2006-01-02} {tags}", false},
+		{"{exif.date} {folder} {tags}", false},
+		{"", true},
+		{"no tokens", true},
+		{"{tags", true},
+		{"tags}", true},
+		{"{}", true},
+		{"{unknown} {tags}", true},
+		{"{seq:abc}", true},
+		{"{seq:-3}", true},
+		{"{seq:+3}", true},
+		{"{seq:0}", true},
+		{"{seq: 3}", true},
+		{"{exif.date:15:04} {tags}", true},
+		{"{exif.date:01/02} {tags}", true},
+		{"{tags:x}", true},
+		{"sub/{tags}", true},
+	}
+	for _, tt := range tests {
+		_, err := ParseTemplate(tt.text, vars)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTemplate(%q) error = %v, wantErr %v", tt.text, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTemplateRender(t *testing.T) {
+	vars := map[string]string{"address": "12 Main St"}
+	date := time.Date(2023, 5, 17, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		text   string
+		fields Fields
+		want   string
+	}{
+		{DefaultTemplate, Fields{Stem: "IMG_1", Tags: []string{"AC", "FURNACE DATA"}}, "IMG_1 AC FURNACE DATA.jpg"},
+		{DefaultTemplate, Fields{Stem: "IMG_1"}, "IMG_1.jpg"},
+		{"{address}_{tags}_{seq:03}", Fields{Tags: []string{"AC"}, Seq: 7, Vars: vars}, "12 Main St_AC_007.jpg"},
+		{"{address}_{tags}_{seq:03}", Fields{Seq: 2, Vars: vars}, "12 Main St_002.jpg"},
+		{"{exif.date:2006-01-02} {tags}", Fields{Tags: []string{"TANK"}, Date: date}, "2023-05-17 TANK.jpg"},
+		{"{exif.date} {tags}", Fields{Tags: []string{"TANK"}}, "TANK.jpg"},
+		{"{folder}-{seq}", Fields{Folder: "Smith", Seq: 3}, "Smith-3.jpg"},
+		{"{tags}_{stem}", Fields{Stem: "IMG_2"}, "IMG_2.jpg"},
+		{"{stem}_{tags}_{seq:03}", Fields{Stem: "IMG_2", Seq: 1}, "IMG_2_001.jpg"},
+		{"{stem}_{seq:3}", Fields{Stem: "IMG_2", Seq: 12}, "IMG_2_012.jpg"},
+		{"{exif.date} {tags}", Fields{}, ".jpg"},
+		{DefaultTemplate, Fields{Stem: "_DSC1234", Tags: []string{"ATTIC"}}, "_DSC1234 ATTIC.jpg"},
+		{DefaultTemplate, Fields{Stem: "My  photo "}, "My  photo .jpg"},
+		{"_{stem}", Fields{Stem: "IMG_2"}, "_IMG_2.jpg"},
+	}
+	for _, tt := range tests {
+		tmpl, err := ParseTemplate(tt.text, vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tmpl.Render(tt.fields, ".jpg"); got != tt.want {
+			t.Errorf("%q.Render(%+v) = %q, want %q", tt.text, tt.fields, got, tt.want)
+		}
+	}
+}
+
+func TestTemplateMatch(t *testing.T) {
+	vocabulary := []string{"AC", "TANK", "TANK DATA"}
+	vars := map[string]string{"address": "12 Main St"}
+	tests := []struct {
+		text     string
+		stem     string
+		wantOK   bool
+		wantStem string
+		wantTags []string
+	}{
+		{DefaultTemplate, "IMG_1 AC TANK DATA", true, "IMG_1", []string{"AC", "TANK DATA"}},
+		{DefaultTemplate, "IMG_1", true, "IMG_1", []string{}},
+		{DefaultTemplate, "IMG_1 AC 2", true, "IMG_1", []string{"AC"}},
+		{DefaultTemplate, "IMG 2", true, "IMG 2", []string{}},
+		{DefaultTemplate, "_DSC1234 AC", true, "_DSC1234", []string{"AC"}},
+		{"{tags}_{stem}", "AC_IMG_2", true, "IMG_2", []string{"AC"}},
+		{"{tags}_{stem}", "IMG_2", true, "IMG_2", []string{}},
+		{DefaultTemplate, "IMG_1 ACME", true, "IMG_1 ACME", []string{}},
+		{"{address}_{tags}_{seq:03}", "12 Main St_TANK AC_004", true, "", []string{"AC", "TANK"}},
+		{"{address}_{tags}_{seq:03}", "12 Main St_004", true, "", []string{}},
+		{"{address}_{tags}_{seq:03}", "IMG_1", false, "", nil},
+		{"{stem}-{tags}", "IMG_1-AC", true, "IMG_1", []string{"AC"}},
+		{"{stem}-{tags}", "IMG_1", true, "IMG_1", []string{}},
+		{"{tags}_{stem}", "AC_IMG_1", true, "IMG_1", []string{"AC"}},
+	}
+	for _, tt := range tests {
+		tmpl, err := ParseTemplate(tt.text, vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stem, tags, ok := tmpl.Match(tt.stem, Fields{Vars: vars}, vocabulary)
+		if ok != tt.wantOK {
+			t.Errorf("%q.Match(%q) ok = %v, want %v", tt.text, tt.stem, ok, tt.wantOK)
+			continue
+		}
+		if ok && (stem != tt.wantStem || !reflect.DeepEqual(tags, tt.wantTags)) {
+			t.Errorf("%q.Match(%q) = %q, %v, want %q, %v", tt.text, tt.stem, stem, tags, tt.wantStem, tt.wantTags)
+		}
+	}
+}
+
+func TestTemplateMatchCompilesOnce(t *testing.T) {
+	tmpl, err := ParseTemplate(DefaultTemplate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vocabulary := []string{"TANK", "AC"}
+	for _, stem := range []string{"IMG_1 TANK", "IMG_2 AC", "IMG_3"} {
+		if _, _, ok := tmpl.Match(stem, Fields{}, vocabulary); !ok {
+			t.Errorf("Match(%q) failed", stem)
+		}
+	}
+	if len(tmpl.patterns) != 1 {
+		t.Errorf("%d patterns compiled, want 1", len(tmpl.patterns))
+	}
+}
+
+func TestSessionTemplate(t *testing.T) {
+	vars := map[string]string{"address": "12 Main St"}
+	tmpl, err := ParseTemplate("{address}_{tags}_{seq:03}", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := makeDir(t, "12 Main St_AC_001.jpg", "IMG_1.jpg", "IMG_2.jpg")
+	s, err := Open(filepath.Join(dir, "IMG_1.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"AC", "TANK"})
+	s.SetTemplate(tmpl, vars)
+
+	// {seq} skips the number that is taken
+	if got := s.ToggleTag("AC"); got != "12 Main St_AC_002.jpg" {
+		t.Errorf("ToggleTag(AC) = %q", got)
+	}
+	if got := s.ToggleTag("TANK"); got != "12 Main St_AC TANK_001.jpg" {
+		t.Errorf("ToggleTag(TANK) = %q", got)
+	}
+	if got := s.ToggleTag("AC"); got != "12 Main St_TANK_001.jpg" {
+		t.Errorf("ToggleTag(AC) again = %q", got)
+	}
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.Tags(), []string{"TANK"}) {
+		t.Errorf("Tags() after commit = %v, want [TANK]", s.Tags())
+	}
+}
+
+func TestSessionChangeTemplate(t *testing.T) {
+	dir := makeDir(t, "IMG_1.jpg")
+	s, err := Open(filepath.Join(dir, "IMG_1.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVocabulary([]string{"AC"})
+	tmpl, err := ParseTemplate("{tags}_{stem}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing pending, the preview stays the current name
+	s.SetTemplate(tmpl, nil)
+	if got := s.Preview(); got != "IMG_1.jpg" {
+		t.Errorf("Preview() = %q, want IMG_1.jpg", got)
+	}
+
+	def, err := ParseTemplate(DefaultTemplate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetTemplate(def, nil)
+	s.ToggleTag("AC")
+	s.SetTemplate(tmpl, nil)
+	if got := s.Preview(); got != "AC_IMG_1.jpg" {
+		t.Errorf("Preview() after template change = %q, want AC_IMG_1.jpg", got)
+	}
+}
+
+func TestSessionStemFromJournal(t *testing.T) {
+	dir := makeDir(t, "IMG_1.jpg")
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "journal.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(filepath.Join(dir, "IMG_1.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetJournal(j)
+	s.SetVocabulary([]string{"AC"})
+	if err := s.Rename("2023-05-17_AC.jpg"); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := ParseTemplate("{stem}-{tags}-{seq}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetTemplate(tmpl, nil)
+	// the current name doesn't fit the template, so the stem is the name before the rename
+	if got := s.SetTags([]string{"AC"}); got != "IMG_1-AC-1.jpg" {
+		t.Errorf("SetTags() = %q, want %q", got, "IMG_1-AC-1.jpg")
+	}
+}
+
+func TestJournalOriginalName(t *testing.T) {
+	dir := makeDir(t, "IMG_1.jpg")
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "journal.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range [][2]string{{"IMG_1.jpg", "IMG_1 AC.jpg"}, {"IMG_1 AC.jpg", "IMG_1 HP.jpg"}} {
+		if err := j.Rename(dir, step[0], step[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := j.OriginalName(dir, "IMG_1 HP.jpg"); got != "IMG_1.jpg" {
+		t.Errorf("OriginalName() = %q, want IMG_1.jpg", got)
+	}
+	if got := j.OriginalName(dir, "other.jpg"); got != "other.jpg" {
+		t.Errorf("OriginalName() of an unknown file = %q", got)
+	}
+}
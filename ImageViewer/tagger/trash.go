@@ -0,0 +1,138 @@
+package tagger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/metadata"
+)
+
+// TrashDirName is the name of the trash folder created inside an image folder
+const TrashDirName = ".imagetagger-trash"
+
+// TrashItem is a deleted file waiting in the trash of its folder
+type TrashItem struct {
+	// Dir is the folder the file was deleted from
+	Dir string
+	// Name is the file name before it was deleted
+	Name string
+	// Path is the location of the file in the trash
+	Path    string
+	Deleted time.Time
+}
+
+// TrashDir returns the trash folder of dir
+func TrashDir(dir string) string {
+	return filepath.Join(dir, TrashDirName)
+}
+
+// MoveToTrash moves name from dir into the trash of dir and returns its path in the trash.
+// The XMP sidecar of name goes along. The deletion time is kept in the trash file name, e.g. "1700000000000000000-IMG_1.jpg".
+func MoveToTrash(dir, name string) (string, error) {
+	if err := os.MkdirAll(TrashDir(dir), os.ModePerm); err != nil {
+		return "", err
+	}
+	path := filepath.Join(TrashDir(dir), trashName(time.Now(), name))
+	if err := moveWithSidecar(filepath.Join(dir, name), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func trashName(deleted time.Time, name string) string {
+	return fmt.Sprintf("%d-%s", deleted.UnixNano(), name)
+}
+
+// ListTrash returns the deleted files of dir, newest first
+func ListTrash(dir string) ([]TrashItem, error) {
+	folder, err := os.Open(TrashDir(dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer folder.Close()
+	names, err := folder.Readdirnames(0)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []TrashItem{}
+	for _, v := range names {
+		if metadata.IsSidecar(v) {
+			// listed with its image
+			continue
+		}
+		parts := strings.SplitN(v, "-", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		nsec, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		items = append(items, TrashItem{
+			Dir:     dir,
+			Name:    parts[1],
+			Path:    filepath.Join(TrashDir(dir), v),
+			Deleted: time.Unix(0, nsec),
+		})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Deleted.After(items[j].Deleted) })
+	return items, nil
+}
+
+// Restore moves the item back into its folder. If the name is taken in the meantime,
+// the restored file is numbered like SequenceName. It returns the restored file name.
+func (item TrashItem) Restore() (string, error) {
+	name := SequenceName(item.Dir, item.Name)
+	if err := moveWithSidecar(item.Path, filepath.Join(item.Dir, name)); err != nil {
+		return "", err
+	}
+	removeEmptyTrash(item.Dir)
+	return name, nil
+}
+
+// Remove deletes the item permanently
+func (item TrashItem) Remove() error {
+	if err := removeWithSidecar(item.Path); err != nil {
+		return err
+	}
+	removeEmptyTrash(item.Dir)
+	return nil
+}
+
+// PurgeTrash permanently deletes the files that are in the trash of dir for longer
+// than maxAge. A maxAge of zero or less keeps all files. It returns the number of purged files.
+func PurgeTrash(dir string, maxAge time.Duration) (int, error) {
+	if maxAge <= 0 {
+		return 0, nil
+	}
+	items, err := ListTrash(dir)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, item := range items {
+		if time.Since(item.Deleted) <= maxAge {
+			continue
+		}
+		if err := removeWithSidecar(item.Path); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	removeEmptyTrash(dir)
+	return purged, nil
+}
+
+// removeEmptyTrash removes the trash folder of dir if it is empty, so it doesn't linger in job folders
+func removeEmptyTrash(dir string) {
+	os.Remove(TrashDir(dir)) // fails if not empty
+}
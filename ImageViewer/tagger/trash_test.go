@@ -0,0 +1,101 @@
+package tagger
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrashRestore(t *testing.T) {
+	tests := []struct {
+		name      string
+		taken     bool
+		wantName  string
+		wantFiles []string
+	}{
+		{"free name", false, "a.jpg", []string{"a.jpg", "b.jpg"}},
+		{"taken name", true, "a 2.jpg", []string{"a 2.jpg", "a.jpg", "b.jpg"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := makeDir(t, "a.jpg", "b.jpg")
+			if _, err := MoveToTrash(dir, "a.jpg"); err != nil {
+				t.Fatal(err)
+			}
+			if tt.taken {
+				makeFile(t, dir, "a.jpg")
+			}
+			items, err := ListTrash(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != 1 || items[0].Name != "a.jpg" || items[0].Dir != dir {
+				t.Fatalf("ListTrash() = %+v, want a.jpg", items)
+			}
+			name, err := items[0].Restore()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != tt.wantName {
+				t.Errorf("Restore() = %q, want %q", name, tt.wantName)
+			}
+			// the empty trash folder is removed again
+			if got := listDir(t, dir); !reflect.DeepEqual(got, tt.wantFiles) {
+				t.Errorf("files = %v, want %v", got, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestListTrashOrderAndMissing(t *testing.T) {
+	dir := makeDir(t, "a.jpg", "b.jpg")
+	items, err := ListTrash(dir)
+	if err != nil || len(items) != 0 {
+		t.Fatalf("ListTrash() without trash = %v, %v", items, err)
+	}
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		if _, err := MoveToTrash(dir, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// files that weren't put there by MoveToTrash are ignored
+	makeFile(t, TrashDir(dir), "stray.jpg")
+	items, err = ListTrash(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Name != "b.jpg" || items[1].Name != "a.jpg" {
+		t.Errorf("ListTrash() = %+v, want b.jpg, a.jpg", items)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	tests := []struct {
+		name       string
+		age        time.Duration
+		maxAge     time.Duration
+		wantPurged int
+	}{
+		{"old file purged", 48 * time.Hour, 24 * time.Hour, 1},
+		{"new file kept", time.Hour, 24 * time.Hour, 0},
+		{"purge disabled", 48 * time.Hour, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := makeDir(t)
+			if err := os.MkdirAll(TrashDir(dir), os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+			makeFile(t, TrashDir(dir), trashName(time.Now().Add(-tt.age), "x.jpg"))
+
+			purged, err := PurgeTrash(dir, tt.maxAge)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if purged != tt.wantPurged {
+				t.Errorf("PurgeTrash() = %d, want %d", purged, tt.wantPurged)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package tagger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/metadata"
+)
+
+// Watcher reports when images are created, removed or renamed in a set of folders
+type Watcher struct {
+	watcher *fsnotify.Watcher
+	delay   time.Duration
+	// recursive is set if new folders are watched too
+	recursive bool
+	errs      errorList
+}
+
+// WatchFolders calls onChange after images in dirs were created, removed or renamed.
+// Events are collected until the folders were quiet for delay, so copying a camera card
+// is reported once. Their subfolders aren't watched. onChange runs on the goroutine
+// of the watcher.
+func WatchFolders(dirs []string, delay time.Duration, onChange func()) (*Watcher, error) {
+	return watch(dirs, false, delay, onChange)
+}
+
+// watch is WatchFolders, with the folders created in dirs watched too if recursive is set
+func watch(dirs []string, recursive bool, delay time.Duration, onChange func()) (*Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if err := fw.Add(dir); err != nil {
+			fw.Close()
+			return nil, err
+		}
+	}
+	w := &Watcher{watcher: fw, delay: delay, recursive: recursive}
+	go w.run(onChange)
+	return w, nil
+}
+
+// WatchTree is WatchFolders for dir and all its folders, also the ones without images yet
+// and the ones created later. Hidden folders, like the trash, aren't watched.
+func WatchTree(dir string, delay time.Duration, onChange func()) (*Watcher, error) {
+	dirs := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			// an unreadable subfolder shouldn't stop watching the rest of the job
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return watch(dirs, true, delay, onChange)
+}
+
+// Errors returns the problems of the watcher since the last call. Folders
+// that can't be watched don't report their changes.
+func (w *Watcher) Errors() []error {
+	return w.errs.take()
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	return w.watcher.Close()
+}
+
+func (w *Watcher) run(onChange func()) {
+	var quiet <-chan time.Time
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if w.handle(event) {
+				quiet = time.After(w.delay)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.errs.add(fmt.Errorf("watching folders: %v", err))
+		case <-quiet:
+			quiet = nil
+			onChange()
+		}
+	}
+}
+
+// handle watches new folders of a tree and reports whether event changes the images
+func (w *Watcher) handle(event fsnotify.Event) bool {
+	name := filepath.Base(event.Name)
+	if event.Op&fsnotify.Create != 0 {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if strings.HasPrefix(name, ".") || !w.recursive {
+				return false
+			}
+			if err := w.watcher.Add(event.Name); err != nil {
+				w.errs.add(fmt.Errorf("watching %s: %v", event.Name, err))
+			}
+			return true
+		}
+	}
+	if !IsImage(name) {
+		// a removed folder can't be told from a file anymore, so only skip what is surely no folder of images
+		return event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 && !strings.HasPrefix(name, ".") && !metadata.IsSidecar(name)
+	}
+	// writes count too, so a copy in progress delays the report until it is done
+	return event.Op&(fsnotify.Create|fsnotify.Remove|fsnotify.Rename|fsnotify.Write) != 0
+}
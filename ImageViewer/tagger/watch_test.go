@@ -0,0 +1,139 @@
+package tagger
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUpdateKeepsPosition(t *testing.T) {
+	dir := makeDir(t, "b.jpg", "d.jpg")
+	s, err := NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Seek("d.jpg")
+	s.SetPreview("d FRONT.jpg")
+
+	makeFile(t, dir, "a.jpg")
+	makeFile(t, dir, "c.jpg")
+	makeFile(t, dir, "notes.txt")
+	added, err := s.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(added, []string{"a.jpg", "c.jpg"}) {
+		t.Errorf("Update() = %v", added)
+	}
+	if s.Current() != "d.jpg" || s.Index() != 3 || s.Preview() != "d FRONT.jpg" {
+		t.Errorf("current = %q at %d with preview %q", s.Current(), s.Index(), s.Preview())
+	}
+
+	// the current image removed by someone else, the next one takes its place
+	s.Seek("b.jpg")
+	if err := os.Remove(filepath.Join(dir, "b.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	if added, _ := s.Update(); len(added) != 0 || s.Current() != "c.jpg" {
+		t.Errorf("after remove: added %v, current %q", added, s.Current())
+	}
+}
+
+func TestUpdateEmptyFolder(t *testing.T) {
+	dir := makeDir(t)
+	s, err := NewSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	makeFile(t, dir, "a.jpg")
+	if _, err := s.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Current() != "a.jpg" {
+		t.Errorf("Current() = %q, want the first new image", s.Current())
+	}
+}
+
+func TestWatchFolders(t *testing.T) {
+	dir := makeDir(t)
+	changed := make(chan struct{}, 10)
+	w, err := WatchFolders([]string{dir}, 50*time.Millisecond, func() { changed <- struct{}{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	wait := func(what string) {
+		t.Helper()
+		select {
+		case <-changed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no change reported for %s", what)
+		}
+	}
+
+	makeFile(t, dir, "a.jpg")
+	makeFile(t, dir, "b.jpg")
+	wait("new images")
+
+	// subfolders aren't watched, also new ones
+	makeFile(t, dir, "notes.txt")
+	if err := os.Mkdir(filepath.Join(dir, "visit1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	makeFile(t, dir, filepath.Join("visit1", "c.jpg"))
+
+	select {
+	case <-changed:
+		t.Error("change reported for a subfolder")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWatchTree(t *testing.T) {
+	dir := makeDir(t)
+	for _, sub := range []string{"visit1", filepath.Join("visit1", "attic"), ".trash"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changed := make(chan struct{}, 10)
+	w, err := WatchTree(dir, 50*time.Millisecond, func() { changed <- struct{}{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// folders without images are watched from the start
+	makeFile(t, dir, filepath.Join("visit1", "attic", "a.jpg"))
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported for an image in an empty subfolder")
+	}
+
+	makeFile(t, dir, filepath.Join(".trash", "b.jpg"))
+	select {
+	case <-changed:
+		t.Error("change reported for the trash")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// new folders are watched
+	if err := os.Mkdir(filepath.Join(dir, "visit2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported for a new folder")
+	}
+	makeFile(t, dir, filepath.Join("visit2", "c.jpg"))
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported for an image in a new folder")
+	}
+}
@@ -0,0 +1,96 @@
+package thumbs
+
+import (
+	"image"
+	"sync"
+)
+
+// Pool creates thumbnails with a number of workers in the background
+type Pool struct {
+	cache *Cache
+	jobs  chan job
+	// quit is closed by Close to stop the workers
+	quit  chan struct{}
+	close sync.Once
+
+	mu sync.Mutex
+	// generation counts the requests, jobs of older requests are dropped
+	generation int
+}
+
+type job struct {
+	path       string
+	generation int
+	done       func(path string, img image.Image)
+}
+
+// NewPool starts workers that fill cache
+func NewPool(cache *Cache, workers int) *Pool {
+	p := &Pool{cache: cache, jobs: make(chan job), quit: make(chan struct{})}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Request creates the thumbnails of paths in order and calls done for each one on a worker.
+// The thumbnails of earlier requests that aren't done yet are dropped, e.g. when another folder is opened.
+// Images that can't be decoded are skipped, their errors are collected by the cache.
+func (p *Pool) Request(paths []string, done func(path string, img image.Image)) {
+	p.mu.Lock()
+	p.generation++
+	generation := p.generation
+	p.mu.Unlock()
+
+	go func() {
+		for _, path := range paths {
+			if !p.current(generation) {
+				return
+			}
+			select {
+			case p.jobs <- job{path: path, generation: generation, done: done}:
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the workers once they finished the thumbnail they are working on.
+// The thumbnails that aren't done yet are dropped, done isn't called anymore.
+func (p *Pool) Close() {
+	p.close.Do(func() {
+		p.mu.Lock()
+		p.generation++
+		p.mu.Unlock()
+		close(p.quit)
+	})
+}
+
+func (p *Pool) current(generation int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return generation == p.generation
+}
+
+func (p *Pool) work() {
+	for {
+		var j job
+		select {
+		case j = <-p.jobs:
+		case <-p.quit:
+			return
+		}
+		if !p.current(j.generation) {
+			continue
+		}
+		img, err := p.cache.Get(j.path)
+		if err != nil {
+			p.cache.fail(err)
+			continue
+		}
+		if p.current(j.generation) {
+			j.done(j.path, img)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Package thumbs creates small previews of images and caches them on disk,
+// so a folder that was opened before shows its thumbnails at once.
+package thumbs
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	// decoders of the supported image formats
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/disintegration/gift"
+	"github.com/disintegration/imageorient"
+)
+
+// Cache stores thumbnails as JPEG files in a directory. A thumbnail is
+// created again when its image was changed. When the files take more than
+// maxBytes, the least recently used thumbnails are deleted. A thumbnail
+// counts as used when it was stored or read, its modification time tells.
+type Cache struct {
+	dir      string
+	size     int
+	maxBytes int64
+
+	mu sync.Mutex
+	// errs are the thumbnails that couldn't be created or stored, see Errors
+	errs []error
+	// used is the size of the files in dir, -1 until the directory was read
+	used int64
+}
+
+// NewCache returns a cache in dir for up to maxBytes of thumbnails that fit into size x size pixels
+func NewCache(dir string, size int, maxBytes int64) *Cache {
+	return &Cache{dir: dir, size: size, maxBytes: maxBytes, used: -1}
+}
+
+// Size returns the maximum width and height of the thumbnails
+func (c *Cache) Size() int {
+	return c.size
+}
+
+// Path returns the file the thumbnail of the image at path is cached in.
+// It depends on the modification time and size of the image, so changed images get a new thumbnail.
+func (c *Cache) Path(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("%s|%d|%d|%d", abs, info.ModTime().UnixNano(), info.Size(), c.size)
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".jpg"), nil
+}
+
+// Get returns the thumbnail of the image at path from the cache, creating it if needed
+func (c *Cache) Get(path string) (image.Image, error) {
+	cached, err := c.Path(path)
+	if err != nil {
+		return nil, err
+	}
+	if img, err := readJPEG(cached); err == nil {
+		// keep it from being evicted before thumbnails that weren't looked at
+		now := time.Now()
+		os.Chtimes(cached, now, now)
+		return img, nil
+	}
+
+	img, err := Create(path, c.size)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.store(cached, img); err != nil {
+		// the thumbnail is still useful without the cache
+		c.fail(fmt.Errorf("caching the thumbnail of %s: %v", path, err))
+	}
+	return img, nil
+}
+
+// Errors returns the thumbnails that couldn't be created or stored since the last call
+func (c *Cache) Errors() []error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	errs := c.errs
+	c.errs = nil
+	return errs
+}
+
+func (c *Cache) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errs = append(c.errs, err)
+}
+
+// store writes img to the cache file path
+func (c *Cache) store(path string, img image.Image) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(c.dir, ".thumb-*")
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(tmp, img, &jpeg.Options{Quality: 80}); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return c.added(info.Size())
+}
+
+// added counts a stored thumbnail of n bytes and evicts thumbnails if the cache got too large
+func (c *Cache) added(n int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.used >= 0 {
+		c.used += n
+	}
+	if c.used >= 0 && c.used <= c.maxBytes {
+		return nil
+	}
+	return c.trim()
+}
+
+// trim deletes the least recently used thumbnails until they take no more than
+// 90% of maxBytes, so not every new thumbnail has to read the directory.
+// c.mu must be held.
+func (c *Cache) trim() error {
+	infos, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	files := []os.FileInfo{}
+	var used int64
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".jpg") {
+			continue
+		}
+		files = append(files, info)
+		used += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	limit := c.maxBytes / 10 * 9
+	for _, info := range files {
+		if used <= limit {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, info.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		used -= info.Size()
+	}
+	c.used = used
+	return nil
+}
+
+// Create decodes the image at path, turned upright by its EXIF orientation,
+// and scales it to fit into size x size pixels
+func Create(path string, size int) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	src, _, err := imageorient.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode %s: %v", path, err)
+	}
+	return Fit(src, size), nil
+}
+
+// Fit returns src scaled down to fit into size x size pixels, or src itself if it fits already
+func Fit(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	if b.Dx() <= size && b.Dy() <= size {
+		return src
+	}
+	g := gift.New(gift.ResizeToFit(size, size, gift.LinearResampling))
+	dst := image.NewRGBA(g.Bounds(b))
+	g.Draw(dst, src)
+	return dst
+}
+
+func readJPEG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return jpeg.Decode(f)
+}
@@ -0,0 +1,184 @@
+package thumbs
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writePNG creates a w x h PNG image at path
+func writePNG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, 0, color.RGBA{255, 0, 0, 255})
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheGet(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.png")
+	writePNG(t, path, 400, 200)
+	c := NewCache(filepath.Join(t.TempDir(), "thumbs"), 100, 1<<20)
+
+	img, err := c.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 50 {
+		t.Errorf("thumbnail is %v, want 100x50", b)
+	}
+	cached, err := c.Path(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cached); err != nil {
+		t.Fatalf("thumbnail wasn't cached: %v", err)
+	}
+
+	// a changed image gets a new thumbnail
+	writePNG(t, path, 200, 400)
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := c.Path(path); again == cached {
+		t.Error("changed image has the same cache file")
+	}
+	img, err = c.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 50 || b.Dy() != 100 {
+		t.Errorf("thumbnail of the changed image is %v, want 50x100", b)
+	}
+}
+
+func TestCacheGetInvalid(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "broken.jpg")
+	if err := ioutil.WriteFile(path, []byte("no image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCache(filepath.Join(t.TempDir(), "thumbs"), 100, 1<<20)
+	if _, err := c.Get(path); err == nil {
+		t.Error("Get returned no error for a broken image")
+	}
+}
+
+func TestCacheEvicts(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(t.TempDir(), "thumbs")
+	paths := map[string]string{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		paths[name] = filepath.Join(dir, name+".png")
+		writePNG(t, paths[name], 400, 200)
+	}
+	cached := func(name string) string {
+		path, err := NewCache(cacheDir, 100, 0).Path(paths[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	c := NewCache(cacheDir, 100, 1<<20)
+	for i, name := range []string{"a", "b", "c"} {
+		if _, err := c.Get(paths[name]); err != nil {
+			t.Fatal(err)
+		}
+		used := time.Now().Add(time.Duration(i-3) * time.Minute)
+		if err := os.Chtimes(cached(name), used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, err := os.Stat(cached("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// room for three and a half thumbnails: reading a keeps it, b is the least recently used
+	c = NewCache(cacheDir, 100, info.Size()*7/2)
+	if _, err := c.Get(paths["a"]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(paths["d"]); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		if _, err := os.Stat(cached(name)); (err == nil) != want {
+			t.Errorf("thumbnail of %s cached = %v, want %v", name, err == nil, want)
+		}
+	}
+	if errs := c.Errors(); len(errs) != 0 {
+		t.Errorf("Errors() = %v", errs)
+	}
+}
+
+func TestPoolRequest(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{}
+	for _, name := range []string{"a.png", "b.png", "c.png"} {
+		path := filepath.Join(dir, name)
+		writePNG(t, path, 64, 64)
+		paths = append(paths, path)
+	}
+	p := NewPool(NewCache(filepath.Join(t.TempDir(), "thumbs"), 32, 1<<20), 2)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(len(paths))
+	got := []string{}
+	p.Request(paths, func(path string, img image.Image) {
+		mu.Lock()
+		got = append(got, filepath.Base(path))
+		mu.Unlock()
+		wg.Done()
+	})
+	wg.Wait()
+	sort.Strings(got)
+	if len(got) != 3 || got[0] != "a.png" || got[2] != "c.png" {
+		t.Errorf("thumbnails done for %v", got)
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{}
+	for i := 0; i < 20; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("%d.png", i))
+		writePNG(t, path, 64, 64)
+		paths = append(paths, path)
+	}
+	before := runtime.NumGoroutine()
+	p := NewPool(NewCache(filepath.Join(t.TempDir(), "thumbs"), 32, 1<<20), 4)
+	p.Request(paths, func(path string, img image.Image) {})
+	p.Close()
+	p.Close()
+
+	// the workers and the request stop
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left running after Close, %d before the pool", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
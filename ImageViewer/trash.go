@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+)
+
+const (
+	deleteModeTrash     = "trash"
+	deleteModePermanent = "permanent"
+)
+
+// permanentDelete reports whether deletes bypass the trash
+func (a *App) permanentDelete() bool {
+	return a.config.GetString("deletemode") == deleteModePermanent
+}
+
+// trashMaxAge returns how long deleted files stay in the trash, zero keeps them forever
+func (a *App) trashMaxAge() time.Duration {
+	return time.Duration(a.config.GetInt("trashmaxagedays")) * 24 * time.Hour
+}
+
+// deleteDialog asks before deleting the current image
+func (a *App) deleteDialog() {
+	if !a.image.HasImage() {
+		return
+	}
+	message := "Do you really want to delete this image?\n It can be restored with Edit > Undo Rename/Delete\n or Edit > Restore Deleted."
+	if a.permanentDelete() {
+		message = "Do you really want to delete this image?\n This action can't be undone."
+	}
+	dialog.ShowConfirm("Delete file?", message, func(b bool) {
+		if b {
+			a.deleteFile()
+		}
+	}, a.mainWin)
+}
+
+// purgeTrash removes files from the trash of dir that are older than the configured age
+func (a *App) purgeTrash(dir string) {
+	maxAge := a.trashMaxAge()
+	go func() {
+		if _, err := tagger.PurgeTrash(dir, maxAge); err != nil {
+			fmt.Fprintf(os.Stderr, "Error purging trash of %s: %v\n", dir, err)
+		}
+	}()
+}
+
+// showTrash lists the deleted images of the current folder and restores them
+func (a *App) showTrash() {
+	if a.session == nil {
+		dialog.ShowError(fmt.Errorf("no folder opened"), a.mainWin)
+		return
+	}
+	dir := a.session.CurrentDir()
+	items, err := tagger.ListTrash(dir)
+	if err != nil {
+		dialog.ShowError(err, a.mainWin)
+		return
+	}
+
+	win := a.app.NewWindow("Restore Deleted")
+	selected := -1
+	list := widget.NewList(
+		func() int { return len(items) },
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(fmt.Sprintf("%s  %s", items[id].Deleted.Format("2006-01-02 15:04"), items[id].Name))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) { selected = id }
+	list.OnUnselected = func(widget.ListItemID) { selected = -1 }
+
+	reload := func() {
+		items, err = tagger.ListTrash(dir)
+		if err != nil {
+			dialog.ShowError(err, win)
+		}
+		selected = -1
+		list.UnselectAll()
+		list.Refresh()
+	}
+
+	// the journal and the session belong to the main window, which handles its input
+	// on another goroutine than this window, so they are changed over there
+	restoreBtn := widget.NewButton("Restore", func() {
+		if selected < 0 {
+			return
+		}
+		item := items[selected]
+		a.runOnUI(func() {
+			// with a journal, the delete of the image is dropped from the undo history
+			restore := tagger.TrashItem.Restore
+			if a.journal != nil {
+				restore = a.journal.Restore
+			}
+			name, err := restore(item)
+			runOn(win, func() {
+				if err != nil {
+					dialog.ShowError(err, win)
+				}
+				reload()
+			})
+			if err != nil || a.session == nil {
+				return
+			}
+			if rel, ok := a.session.Locate(dir, name); ok {
+				if err := a.session.Refresh(); err != nil {
+					dialog.ShowError(err, a.mainWin)
+					return
+				}
+				a.session.Seek(rel)
+				a.openCurrent()
+			}
+		})
+	})
+	removeBtn := widget.NewButton("Delete Permanently", func() {
+		if selected < 0 {
+			return
+		}
+		item := items[selected]
+		dialog.ShowConfirm("Delete file?", fmt.Sprintf("Permanently delete %s?\n This action can't be undone.", item.Name), func(b bool) {
+			if !b {
+				return
+			}
+			a.runOnUI(func() {
+				remove := tagger.TrashItem.Remove
+				if a.journal != nil {
+					remove = a.journal.Remove
+				}
+				err := remove(item)
+				runOn(win, func() {
+					if err != nil {
+						dialog.ShowError(err, win)
+					}
+					reload()
+				})
+			})
+		}, win)
+	})
+
+	win.SetContent(container.NewBorder(
+		widget.NewLabel(tagger.TrashDir(dir)),
+		container.NewHBox(restoreBtn, removeBtn),
+		nil, nil,
+		list,
+	))
+	win.Resize(fyne.NewSize(500, 400))
+	win.Show()
+}
@@ -2,14 +2,10 @@ package main
 
 import (
 	"fmt"
-	"os"
-    "path/filepath"
 	"runtime"
 	"strconv"
-    "strings"
 
 	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/driver/desktop"
@@ -18,6 +14,8 @@ import (
 	"fyne.io/fyne/v2/widget"
 	"github.com/disintegration/gift"
 //    "github.com/spf13/viper"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
 )
 
 func removeDuplicates(elements []string) []string {
@@ -36,41 +34,47 @@ func removeDuplicates(elements []string) []string {
 	return result
 }
 
-func (a *App) nextImage(forward, folder bool) {
-	if a.img.OriginalImage == nil || len(a.img.ImagesInFolder) < 2 {
+// runOnUI runs fn on the goroutine that handles the input of the main window.
+// The session and the widgets belong to it, so work done in the background
+// hands its results over with runOnUI instead of touching them itself.
+func (a *App) runOnUI(fn func()) {
+	runOn(a.mainWin, fn)
+}
+
+// runOn runs fn on the goroutine that handles the input of win. Every window has its own.
+func runOn(win fyne.Window, fn func()) {
+	if q, ok := win.(interface{ QueueEvent(func()) }); ok {
+		q.QueueEvent(fn)
+		return
+	}
+	// drivers without an event queue, like the test driver, handle input on the calling goroutine
+	fn()
+}
+
+func (a *App) nextImage(forward bool) {
+	if a.img.OriginalImage == nil || a.session == nil || a.session.Len() < 2 {
 		return
 	}
 
 	if forward {
-		if a.img.index == len(a.img.ImagesInFolder)-1 {
+		if !a.session.Next() {
 			return
 		}
-		a.img.index++
 	} else {
-		if a.img.index == 0 {
+		if !a.session.Prev() {
 			return
 		}
-		a.img.index--
-	}
-
-    fileName := a.img.ImagesInFolder[a.img.index]
-    fileFullName := a.img.Directory + "/" + fileName
-	file, err := os.Open(fileFullName)
-	if err != nil {
-		dialog.ShowError(err, a.mainWin)
-		return
 	}
-	if folder {
-		a.open(file, true)
-	} else {
-		a.open(file, false)
-	}
-    a.renamePreview.SetText(fileName)
+	a.openCurrent()
 }
 
 func (a *App) nextImageWithSave() {
-    a.renameImage(a.renamePreview.Text)
-    a.nextImage(true, false)
+    if a.session == nil {
+        return
+    }
+    a.renameImage(a.renamePreview.Text, func() {
+        a.nextImage(true)
+    })
 }
 
 func (a *App) fullscreenMode() {
@@ -84,9 +88,9 @@ func (a *App) fullscreenMode() {
 			case fyne.KeyF11:
 				a.fullscreenWin.Close()
 			case fyne.KeyRight:
-				a.nextImage(true, false)
+				a.nextImage(true)
 			case fyne.KeyLeft:
-				a.nextImage(false, false)
+				a.nextImage(false)
 			}
 		})
 		a.fullscreenWin.SetContent(a.image)
@@ -100,11 +104,11 @@ func (a *App) fullscreenMode() {
 
 func (a *App) loadBottomBar() *fyne.Container {
 	a.leftArrow = widget.NewButtonWithIcon("", theme.NavigateBackIcon(), func() {
-		a.nextImage(false, false)
+		a.nextImage(false)
 	})
 
 	a.rightArrow = widget.NewButtonWithIcon("", theme.NavigateNextIcon(), func() {
-		a.nextImage(true, false)
+		a.nextImage(true)
 	})
 
 	a.confirmArrow = widget.NewButtonWithIcon("", theme.ConfirmIcon(), func() {
@@ -116,6 +120,14 @@ func (a *App) loadBottomBar() *fyne.Container {
 
     a.renamePreview = widget.NewEntry()
     a.renamePreview.SetPlaceHolder("filename preview")
+    a.renamePreview.Validator = a.validateRenamePreview
+    a.renamePreview.OnChanged = func(s string) {
+        if a.session == nil {
+            return
+        }
+        a.session.SetPreview(s)
+        a.refreshTagButtons()
+    }
     helpLabel := widget.NewLabel("Arrows (arrow keys) move to next/prev image. Check button (return key) saves and moves to next.")
 
     a.bottomBar = container.NewVBox(
@@ -141,13 +153,7 @@ func (a *App) loadBottomBar() *fyne.Container {
 }
 
 func (a *App) loadStatusBar() *fyne.Container {
-	a.deleteBtn = widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
-		dialog.ShowConfirm("Delte file?", "Do you really want to delete this image?\n This action can't be undone.", func(b bool) {
-			if b {
-				a.deleteFile()
-			}
-		}, a.mainWin)
-	})
+	a.deleteBtn = widget.NewButtonWithIcon("", theme.DeleteIcon(), a.deleteDialog)
 	a.deleteBtn.Disable()
 
 	a.renameBtn = widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), a.renameDialog)
@@ -164,12 +170,20 @@ func (a *App) loadStatusBar() *fyne.Container {
 	a.resetZoomBtn = widget.NewButtonWithIcon("", theme.ZoomFitIcon(), a.resetZoom)
 	a.resetZoomBtn.Disable()
 
+	a.actualZoomBtn = widget.NewButton("1:1", a.zoomActual)
+	a.actualZoomBtn.Disable()
+
+	a.fillZoomBtn = widget.NewButton("Fill", a.image.Fill)
+	a.fillZoomBtn.Disable()
+
 	a.statusBar = container.NewVBox(
 		widget.NewSeparator(),
 		container.NewHBox(
 			layout.NewSpacer(),
 			a.zoomLabel,
 			a.resetZoomBtn,
+			a.fillZoomBtn,
+			a.actualZoomBtn,
 			a.zoomOut,
 			a.zoomIn,
 			a.renameBtn,
@@ -290,11 +304,13 @@ func (a *App) loadEditorTab() *container.TabItem {
 
 					width, _ := strconv.Atoi(widthEntry.Text)
 					height, _ := strconv.Atoi(heightEntry.Text)
-					if keepAspectRatio {
-						a.changeParameter(&a.img.resize, gift.ResizeToFit(width, height, gift.LinearResampling))
-					} else {
-						a.changeParameter(&a.img.resize, gift.ResizeToFill(width, height, gift.LinearResampling, gift.BottomAnchor))
-					}
+					a.changeScaledParameter(&a.img.resize, func(scale float64) gift.Filter {
+						w, h := int(float64(width)*scale), int(float64(height)*scale)
+						if keepAspectRatio {
+							return gift.ResizeToFit(w, h, gift.LinearResampling)
+						}
+						return gift.ResizeToFill(w, h, gift.LinearResampling, gift.BottomAnchor)
+					})
 					a.mainWin.Canvas().Overlays().Top().Hide()
 				}),
 			),
@@ -308,7 +324,9 @@ func (a *App) loadEditorTab() *container.TabItem {
 	editSepia := newEditingOption("Sepia: ", a.sliderSepia, 0)
 
 	a.sliderBlur = newEditingSlider(0, 100)
-	a.sliderBlur.dragEndFunc = func(f float64) { a.changeParameter(&a.img.blur, gift.GaussianBlur(float32(f))) }
+	a.sliderBlur.dragEndFunc = func(f float64) {
+		a.changeScaledParameter(&a.img.blur, func(scale float64) gift.Filter { return gift.GaussianBlur(float32(f * scale)) })
+	}
 	editBlur := newEditingOption("Blur: ", a.sliderBlur, 0)
 
 	a.resetBtn = widget.NewButtonWithIcon("Reset All", theme.ContentClearIcon(), a.reset)
@@ -364,46 +382,24 @@ func (a *App) loadInformationTab() *container.TabItem {
 	a.imgLastMod = widget.NewLabel("Last modified: ")
 
     a.tagBtnLabel = widget.NewLabel("Tag Buttons: ")
-    a.tagBtns = make([]*widget.Button, 0, tagBtnTotal)
-    a.tagBtnEntries = make([]*widget.Entry, 0, tagBtnTotal)
-    a.tagBtnGrid = container.New(layout.NewGridLayout(3))
-
-    //defaultButtonTags := DefaultButtonTags()
-    defaultButtonTags := a.config.GetStringSlice("buttontags")
-
-    for i := 0; i < tagBtnTotal; i++ {
-        //fmt.Fprintf(os.Stderr, "DEBUG tagBtnTotal loop i=%d \n", i)
-        index := i
+    a.tagBtnArea = container.NewVBox()
+    a.tagEditor = container.NewVBox()
+    a.tagEditor.Hide()
 
-        newTagButton := widget.NewButton(defaultButtonTags[i], func() {
-            delim := " "
-            if a.tagBtns[index].Text == "" {
-                delim = ""
-            }
-            fileName := a.img.ImagesInFolder[a.img.index]
-            fileExt := filepath.Ext(fileName)
-            fileName = strings.TrimSuffix(fileName, fileExt)
-            fileName = fileName + delim + a.tagBtns[index].Text + fileExt
-            a.renamePreview.SetText(fileName)
-        })
-        newTagButton.Disable()
-        a.tagBtns = append(a.tagBtns, newTagButton)
-        a.tagBtnGrid.Add(a.tagBtns[i])
-
-        a.tagBtnEntries = append(a.tagBtnEntries, widget.NewEntry())
-        a.tagBtnGrid.Add(a.tagBtnEntries[i])
-        a.tagBtnEntries[i].SetPlaceHolder("none")
-        a.tagBtnEntries[i].Hide()
-    }
+    a.loadProfiles()
+    a.setTagButtons(a.profiles[a.profile].Buttons)
 
     a.editTagsBtn = widget.NewButton("Edit Tags", func() {
             a.tagBtnLabel.SetText("Tag Buttons: (Editing)")
-            for i := 0; i < tagBtnTotal; i++ {
-                a.tagBtns[i].Hide()
-                a.tagBtnEntries[i].SetText(a.tagBtns[i].Text)
-                a.tagBtnEntries[i].Show()
+            a.tagBtnArea.Hide()
+            required := []bool{}
+            for _, t := range a.buttons {
+                required = append(required, a.profiles[a.profile].IsRequired(t.Name))
             }
+            a.showTagEditor(a.buttons, required)
+            a.tagEditor.Show()
 
+            a.profileSelector.Disable()
             a.editTagsBtn.Disable()
             a.editTagsBtn.Hide()
             a.saveTagsBtn.Enable()
@@ -411,18 +407,38 @@ func (a *App) loadInformationTab() *container.TabItem {
         })
 
     a.saveTagsBtn = widget.NewButton("Save Tag Buttons", func() { 
-            a.tagBtnLabel.SetText("Tag Buttons: ")
-            newTags := []string{}
-            for i := 0; i < tagBtnTotal; i++ {
-                a.tagBtns[i].SetText(a.tagBtnEntries[i].Text)
-                newTags = append(newTags, a.tagBtnEntries[i].Text)
-                a.tagBtns[i].Show()
-                a.tagBtnEntries[i].Hide()
+            // rows without a tag are dropped
+            newButtons := []tagger.Tag{}
+            newHotkeys := []string{}
+            newRequired := []string{}
+            editedRequired := a.editedRequired()
+            for i, t := range a.editedButtons() {
+                if t.Name != "" {
+                    newButtons = append(newButtons, t)
+                    newHotkeys = append(newHotkeys, t.Hotkey)
+                    if editedRequired[i] {
+                        newRequired = append(newRequired, t.Name)
+                    }
+                }
+            }
+            if err := tagger.ValidateButtons(newButtons); err != nil {
+                dialog.ShowError(err, a.mainWin)
+                return
+            }
+            if err := validateTagHotkeys(newHotkeys); err != nil {
+                dialog.ShowError(err, a.mainWin)
+                return
             }
 
-            a.config.Set("buttontags", newTags)
-            a.WriteConfig()
-
+            a.tagBtnLabel.SetText("Tag Buttons: ")
+            a.tagEditor.Hide()
+            a.profiles[a.profile].Buttons = newButtons
+            a.profiles[a.profile].Required = newRequired
+            a.setTagButtons(newButtons)
+            a.tagBtnArea.Show()
+            a.saveProfiles()
+
+            a.profileSelector.Enable()
             a.editTagsBtn.Enable()
             a.editTagsBtn.Show()
             a.saveTagsBtn.Disable()
@@ -442,10 +458,14 @@ func (a *App) loadInformationTab() *container.TabItem {
 			a.heightLabel,
 			a.imgSize,
 			a.imgLastMod,
+            a.loadProfileSelector(),
             a.tagBtnLabel,
-            a.tagBtnGrid,
+            a.tagBtnArea,
+            a.tagEditor,
             a.editTagsBtn,
             a.saveTagsBtn,
+            a.loadChecklist(),
+            a.loadTemplateEditor(),
 		),
 	))
 }
@@ -464,29 +484,41 @@ func (a *App) loadMainUI() fyne.CanvasObject {
 	mainMenu := fyne.NewMainMenu(
 		fyne.NewMenu("File",
 			fyne.NewMenuItem("Open", a.openFileDialog),
+			fyne.NewMenuItem("Open Folder", a.openFolderDialog),
 			fyne.NewMenuItem("Save As", a.saveFileDialog),
+			fyne.NewMenuItem("Export Manifest", a.exportManifestDialog),
+			fyne.NewMenuItem("HTML Gallery", a.galleryDialog),
 			// recent,
 		),
+		fyne.NewMenu("Export",
+			fyne.NewMenuItem("Photo Report", a.photoReportDialog),
+			fyne.NewMenuItem("Package", a.packageDialog),
+		),
 		fyne.NewMenu("Edit",
 			fyne.NewMenuItem("Undo", a.undo),
 			fyne.NewMenuItem("Redo", a.redo),
-			fyne.NewMenuItem("Delete Image", a.deleteFile),
+			fyne.NewMenuItem("Undo Rename/Delete", a.undoFileOperation),
+			fyne.NewMenuItem("Redo Rename/Delete", a.redoFileOperation),
+			fyne.NewMenuItem("History", a.showHistory),
+			fyne.NewMenuItem("Delete Image", a.deleteDialog),
+			fyne.NewMenuItem("Restore Deleted", a.showTrash),
 			fyne.NewMenuItem("Keyboard Shortucts", a.showShortcuts),
 			fyne.NewMenuItem("Preferences", a.loadSettingsUI),
 		),
 		fyne.NewMenu("View",
 			fyne.NewMenuItem("Fullscreen", func() {
-				if a.image.Image == nil {
+				if !a.image.HasImage() {
 					return
 				}
 				a.fullscreenMode()
 			}),
 			fyne.NewMenuItem("Next Image", func() {
-				a.nextImage(true, false)
+				a.nextImage(true)
 			}),
 			fyne.NewMenuItem("Last Image", func() {
-				a.nextImage(false, false)
+				a.nextImage(false)
 			}),
+			fyne.NewMenuItem("Grid", a.showGrid),
 		),
 		fyne.NewMenu("Help",
 			fyne.NewMenuItem("About", func() {
@@ -502,18 +534,25 @@ func (a *App) loadMainUI() fyne.CanvasObject {
 	a.loadKeyboardShortcuts()
 
 	// image canvas
-	a.image = &canvas.Image{}
-	a.image.FillMode = canvas.ImageFillContain
+	a.image = newZoomView()
+	// the view calls these while drawing, the render goroutine hands them over
+	a.image.OnZoom = func(zoom float64) {
+		a.runOnUI(func() { a.showZoom(zoom) })
+	}
+	a.image.OnNeedFull = func() {
+		a.runOnUI(a.renderFull)
+	}
 
     a.bottomBarSplit = container.NewVSplit(
 		a.image,
-        a.loadBottomBar(),
+        container.NewBorder(a.loadFilmstrip(), nil, nil, nil, a.loadBottomBar()),
     )
-	a.bottomBarSplit.SetOffset(0.85)
+	a.bottomBarSplit.SetOffset(0.7)
 
 	a.split = container.NewHSplit(
 		container.NewAppTabs(
 			a.loadInformationTab(),
+			a.loadFoldersTab(),
 			a.loadEditorTab(),
 		),
         a.bottomBarSplit,
@@ -522,3 +561,21 @@ func (a *App) loadMainUI() fyne.CanvasObject {
 	layout := container.NewBorder(nil, a.loadStatusBar(), nil, nil, a.split)
 	return layout
 }
+
+// refreshTagButtons highlights the tag buttons whose tag is pending for the current image
+func (a *App) refreshTagButtons() {
+	tags := []string{}
+	if a.session != nil {
+		tags = a.session.Tags()
+	}
+	for i, btn := range a.tagBtns {
+		importance := widget.MediumImportance
+		if a.buttonTags[i] != "" && tagger.HasTag(tags, a.buttonTags[i]) {
+			importance = widget.HighImportance
+		}
+		if btn.Importance != importance {
+			btn.Importance = importance
+			btn.Refresh()
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fyne.io/fyne/v2"
+
+	"github.com/jjwinters/image-tagger/ImageViewer/tagger"
+)
+
+// watchDelay is how long the folders must be quiet before changes are shown,
+// so copying a camera card updates the list once
+const watchDelay = 500 * time.Millisecond
+
+// watchSession updates the images of session whenever files are added, removed
+// or renamed in its folders, e.g. while photos are copied from a camera card
+func (a *App) watchSession(session *tagger.Session) {
+	if a.watcher != nil {
+		a.watcher.Close()
+		a.watcher = nil
+	}
+	changed := func() {
+		// the watcher has its own goroutine, the session is only touched where the input is handled
+		a.runOnUI(func() {
+			a.folderChanged(session)
+		})
+	}
+	var w *tagger.Watcher
+	var err error
+	if session.Recursive() {
+		// empty folders too, images may be copied into them later
+		w, err = tagger.WatchTree(session.Dir(), watchDelay, changed)
+	} else {
+		w, err = tagger.WatchFolders([]string{session.Dir()}, watchDelay, changed)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error watching %s: %v. The folder won't update automatically.\n", session.Dir(), err)
+		return
+	}
+	a.watcher = w
+}
+
+// folderChanged reads the folders of session again, keeping the current image
+// selected, and tells the user about new images. It runs on the UI goroutine.
+func (a *App) folderChanged(session *tagger.Session) {
+	if a.session != session {
+		return
+	}
+	current := session.CurrentPath()
+	added, err := session.Update()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", session.Dir(), err)
+		return
+	}
+	switch {
+	case session.Len() == 0:
+		a.clearImage()
+	case session.CurrentPath() != current:
+		// the current image is gone, or the folder was empty
+		a.openCurrent()
+	default:
+		a.refreshTagViews()
+		a.refreshFilmstrip()
+	}
+	a.reportErrors()
+	if len(added) > 0 {
+		a.app.SendNotification(fyne.NewNotification("New images",
+			fmt.Sprintf("%d new images in %s", len(added), filepath.Base(session.Dir()))))
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/disintegration/gift"
+)
+
+// zoomMode decides how the zoom follows the size of the view
+type zoomMode int
+
+const (
+	// zoomFit shows the whole image
+	zoomFit zoomMode = iota
+	// zoomFill fills the view, cutting off what doesn't fit
+	zoomFill
+	// zoomFree keeps the zoom the user chose
+	zoomFree
+)
+
+// maxZoom is the largest zoom, in screen pixels per image pixel
+const maxZoom = 32
+
+// zoomView shows an image that can be zoomed with the mouse wheel at the cursor
+// and panned by dragging. Zoom and position refer to the pixels of the full
+// resolution image, so 1:1 shows every pixel of the original.
+type zoomView struct {
+	widget.BaseWidget
+
+	// OnNeedFull is called when the zoom shows more detail than the image has and there
+	// is no full resolution image. It is called while drawing, on the render goroutine.
+	OnNeedFull func()
+	// OnZoom is called with the new zoom after it changed, also while drawing
+	OnZoom func(zoom float64)
+
+	raster *canvas.Raster
+
+	// mu guards the fields below, the raster draws on the render goroutine
+	mu sync.Mutex
+	// image is the image shown, usually the edited display image
+	image image.Image
+	// full is image in full resolution, nil until it was rendered
+	full image.Image
+	// ratio is the size of full divided by the size of image
+	ratio float64
+	mode  zoomMode
+	// zoom is in device pixels per full resolution pixel, center is in full resolution pixels
+	zoom   float64
+	center struct{ x, y float64 }
+	// scale is the number of device pixels per canvas unit
+	scale float64
+}
+
+func newZoomView() *zoomView {
+	z := &zoomView{ratio: 1, scale: 1}
+	z.raster = canvas.NewRaster(z.draw)
+	z.ExtendBaseWidget(z)
+	return z
+}
+
+func (z *zoomView) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(z.raster)
+}
+
+// MinSize lets the view shrink to nothing like a canvas.Image with ImageFillContain
+func (z *zoomView) MinSize() fyne.Size {
+	return fyne.NewSize(1, 1)
+}
+
+// SetImage shows img, with full as its full resolution version ratio times larger.
+// full may be nil, it is asked for with OnNeedFull.
+func (z *zoomView) SetImage(img, full image.Image, ratio float64) {
+	z.mu.Lock()
+	z.image, z.full, z.ratio = img, full, ratio
+	z.mu.Unlock()
+	z.Refresh()
+}
+
+// SetFull sets the full resolution version of the image shown
+func (z *zoomView) SetFull(full image.Image) {
+	z.mu.Lock()
+	z.full = full
+	z.mu.Unlock()
+	z.Refresh()
+}
+
+// HasImage reports whether an image is shown
+func (z *zoomView) HasImage() bool {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.image != nil
+}
+
+// fullSize returns the size of the image in full resolution pixels
+func (z *zoomView) fullSize() (float64, float64) {
+	b := z.image.Bounds()
+	return float64(b.Dx()) * z.ratio, float64(b.Dy()) * z.ratio
+}
+
+// viewSize returns the size of the view in device pixels
+func (z *zoomView) viewSize() (float64, float64) {
+	size := z.Size()
+	return float64(size.Width) * z.scale, float64(size.Height) * z.scale
+}
+
+// Fit shows the whole image
+func (z *zoomView) Fit() {
+	z.mu.Lock()
+	z.mode = zoomFit
+	z.mu.Unlock()
+	z.Refresh()
+}
+
+// Fill fills the view with the image, centered
+func (z *zoomView) Fill() {
+	z.mu.Lock()
+	z.mode = zoomFill
+	z.mu.Unlock()
+	z.Refresh()
+}
+
+// Actual shows one pixel of the full resolution image per screen pixel
+func (z *zoomView) Actual() {
+	z.setZoom(1, -1, -1)
+}
+
+// setZoom changes the zoom, keeping the image point at the view position x, y in place.
+// Negative positions mean the center of the view.
+func (z *zoomView) setZoom(zoom, x, y float64) {
+	z.zoomAt(func(float64) float64 { return zoom }, x, y)
+}
+
+// ZoomBy multiplies the zoom by factor, keeping the center of the view in place
+func (z *zoomView) ZoomBy(factor float64) {
+	z.zoomAt(func(zoom float64) float64 { return zoom * factor }, -1, -1)
+}
+
+// zoomAt changes the zoom to next(zoom) like setZoom
+func (z *zoomView) zoomAt(next func(zoom float64) float64, x, y float64) {
+	z.mu.Lock()
+	if z.image == nil {
+		z.mu.Unlock()
+		return
+	}
+	z.updateZoom()
+	if z.zoom <= 0 {
+		// not drawn yet
+		z.mu.Unlock()
+		return
+	}
+	zoom := next(z.zoom)
+	w, h := z.viewSize()
+	if x < 0 || y < 0 {
+		x, y = w/2, h/2
+	}
+	fw, fh := z.fullSize()
+	minZoom := math.Min(math.Min(w/fw, h/fh), 1)
+	zoom = math.Max(minZoom, math.Min(zoom, maxZoom))
+
+	// the image point under x, y stays there
+	px := z.center.x + (x-w/2)/z.zoom
+	py := z.center.y + (y-h/2)/z.zoom
+	z.zoom = zoom
+	z.center.x = px - (x-w/2)/zoom
+	z.center.y = py - (y-h/2)/zoom
+	z.mode = zoomFree
+	z.clampCenter()
+	z.mu.Unlock()
+	z.Refresh()
+	if z.OnZoom != nil {
+		z.OnZoom(zoom)
+	}
+}
+
+// updateZoom computes the zoom of the fit and fill modes for the current size of the view.
+// It reports whether the zoom changed. z.mu must be held.
+func (z *zoomView) updateZoom() bool {
+	if z.mode == zoomFree || z.image == nil {
+		return false
+	}
+	w, h := z.viewSize()
+	fw, fh := z.fullSize()
+	if w <= 0 || h <= 0 || fw <= 0 || fh <= 0 {
+		return false
+	}
+	zoom := math.Min(w/fw, h/fh)
+	if z.mode == zoomFill {
+		zoom = math.Max(w/fw, h/fh)
+	}
+	z.center.x, z.center.y = fw/2, fh/2
+	if zoom == z.zoom {
+		return false
+	}
+	z.zoom = zoom
+	return true
+}
+
+// clampCenter keeps the image in view: centered if it is smaller than the view, else without empty borders.
+// z.mu must be held.
+func (z *zoomView) clampCenter() {
+	w, h := z.viewSize()
+	fw, fh := z.fullSize()
+	clamp := func(c, view, full float64) float64 {
+		half := view / 2 / z.zoom
+		if full <= 2*half {
+			return full / 2
+		}
+		return math.Max(half, math.Min(c, full-half))
+	}
+	z.center.x = clamp(z.center.x, w, fw)
+	z.center.y = clamp(z.center.y, h, fh)
+}
+
+// Scrolled zooms in or out at the mouse cursor
+func (z *zoomView) Scrolled(e *fyne.ScrollEvent) {
+	if e.Scrolled.DY == 0 {
+		return
+	}
+	factor := math.Pow(1.1, float64(e.Scrolled.DY)/10)
+	z.mu.Lock()
+	scale := z.scale
+	z.mu.Unlock()
+	z.zoomAt(func(zoom float64) float64 { return zoom * factor }, float64(e.Position.X)*scale, float64(e.Position.Y)*scale)
+}
+
+// Dragged pans the image
+func (z *zoomView) Dragged(e *fyne.DragEvent) {
+	z.mu.Lock()
+	if z.image == nil || z.mode == zoomFit || z.zoom <= 0 {
+		z.mu.Unlock()
+		return
+	}
+	z.mode = zoomFree
+	z.center.x -= float64(e.Dragged.DX) * z.scale / z.zoom
+	z.center.y -= float64(e.Dragged.DY) * z.scale / z.zoom
+	z.clampCenter()
+	z.mu.Unlock()
+	z.Refresh()
+}
+
+func (z *zoomView) DragEnd() {}
+
+// DoubleTapped switches between fitting the image and 1:1 at the tapped point
+func (z *zoomView) DoubleTapped(e *fyne.PointEvent) {
+	z.mu.Lock()
+	empty, mode, scale := z.image == nil, z.mode, z.scale
+	z.mu.Unlock()
+	if empty {
+		return
+	}
+	if mode == zoomFit {
+		z.setZoom(1, float64(e.Position.X)*scale, float64(e.Position.Y)*scale)
+	} else {
+		z.Fit()
+	}
+}
+
+// draw renders the visible part of the image into a w x h device pixel image
+func (z *zoomView) draw(w, h int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if w <= 0 || h <= 0 {
+		return dst
+	}
+	z.mu.Lock()
+	if z.image == nil {
+		z.mu.Unlock()
+		return dst
+	}
+	if size := z.Size(); size.Width > 0 {
+		z.scale = float64(w) / float64(size.Width)
+	}
+	zoomChanged := z.updateZoom()
+
+	// the source with enough detail, and its size in full resolution pixels per pixel
+	src, s := z.image, z.ratio
+	needFull := false
+	if z.zoom*z.ratio > 1 {
+		if z.full != nil {
+			src, s = z.full, 1
+		} else {
+			needFull = true
+		}
+	}
+	zoom, center := z.zoom, z.center
+	z.mu.Unlock()
+
+	if zoomChanged && z.OnZoom != nil {
+		z.OnZoom(zoom)
+	}
+	if needFull && z.OnNeedFull != nil {
+		z.OnNeedFull()
+	}
+	if zoom <= 0 {
+		return dst
+	}
+
+	// the visible rectangle in full resolution pixels, then in pixels of src
+	x0 := center.x - float64(w)/2/zoom
+	y0 := center.y - float64(h)/2/zoom
+	x1 := center.x + float64(w)/2/zoom
+	y1 := center.y + float64(h)/2/zoom
+	b := src.Bounds()
+	r := image.Rect(
+		b.Min.X+int(math.Floor(x0/s)), b.Min.Y+int(math.Floor(y0/s)),
+		b.Min.X+int(math.Ceil(x1/s)), b.Min.Y+int(math.Ceil(y1/s)),
+	).Intersect(b)
+	if r.Empty() {
+		return dst
+	}
+
+	// where r is drawn in the view
+	px := int(math.Round((float64(r.Min.X-b.Min.X)*s - x0) * zoom))
+	py := int(math.Round((float64(r.Min.Y-b.Min.Y)*s - y0) * zoom))
+	pw := int(math.Round(float64(r.Dx()) * s * zoom))
+	ph := int(math.Round(float64(r.Dy()) * s * zoom))
+	if pw <= 0 || ph <= 0 {
+		return dst
+	}
+	// enlarged pixels stay sharp, so small print can be read
+	resampling := gift.Resampling(gift.LinearResampling)
+	if zoom*s >= 2 {
+		resampling = gift.NearestNeighborResampling
+	}
+	g := gift.New(gift.Resize(pw, ph, resampling))
+	g.DrawAt(dst, subImage(src, r), image.Pt(px, py), gift.CopyOperator)
+	return dst
+}
+
+// subImage returns the part r of img, copying it if img has no SubImage method
+func subImage(img image.Image, r image.Rectangle) image.Image {
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(r)
+	}
+	dst := image.NewRGBA(r)
+	draw.Draw(dst, r, img, r.Min, draw.Src)
+	return dst
+}